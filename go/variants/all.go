@@ -0,0 +1,207 @@
+package variants
+
+import "reflect"
+
+// AllFlagValues returns the value of every flag registered with the
+// DefaultRegistry for context.
+func AllFlagValues(context interface{}) map[string]interface{} {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.AllFlagValues(context)
+}
+
+// ClientFlagValues returns the value of every client-safe flag registered
+// with the DefaultRegistry for context.
+func ClientFlagValues(context interface{}) map[string]interface{} {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ClientFlagValues(context)
+}
+
+// EvaluateAll returns the value of every flag registered with the
+// DefaultRegistry for context.
+func EvaluateAll(context interface{}) map[string]interface{} {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.EvaluateAll(context)
+}
+
+// EvaluateAllMode returns the value of the flags registered with the
+// DefaultRegistry that mode selects for context.
+func EvaluateAllMode(context interface{}, mode EvaluationMode) map[string]interface{} {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.EvaluateAllMode(context, mode)
+}
+
+// An EvaluationMode selects the flags evaluated by EvaluateAllMode and
+// EvaluateAllDetailedMode.
+type EvaluationMode int
+
+const (
+	// ServerMode evaluates every flag.
+	ServerMode EvaluationMode = iota
+	// ClientMode evaluates only the flags marked ClientSafe, for payloads
+	// sent to untrusted clients, so that server-only flags are never
+	// exposed. Unknown modes are treated as ClientMode.
+	ClientMode
+)
+
+// includes reports whether f is evaluated in the receiver mode.
+func (mode EvaluationMode) includes(f Flag) bool {
+	return mode == ServerMode || f.ClientSafe
+}
+
+// EvaluateAll returns a snapshot of the value of every registered flag for
+// context, mapped by flag name. Each variant's conditions are evaluated
+// at most once, however many flags it modifies, which makes it cheaper
+// than calling FlagValueWithContext for each flag, for example when
+// rendering a page that needs many flags. It is the same as AllFlagValues,
+// and as EvaluateAllMode in ServerMode.
+func (r *Registry) EvaluateAll(context interface{}) map[string]interface{} {
+	return r.EvaluateAllMode(context, ServerMode)
+}
+
+// EvaluateAllMode is like EvaluateAll, but only evaluates the flags that
+// mode selects. In ClientMode it is the same as ClientFlagValues.
+func (r *Registry) EvaluateAllMode(context interface{}, mode EvaluationMode) map[string]interface{} {
+	return r.flagValues(context, mode)
+}
+
+// AllFlagValues returns the value of every registered flag for context,
+// mapped by flag name.
+func (r *Registry) AllFlagValues(context interface{}) map[string]interface{} {
+	return r.flagValues(context, ServerMode)
+}
+
+// ClientFlagValues is like AllFlagValues but only includes flags marked
+// ClientSafe. Use it to build payloads sent to untrusted clients, so
+// server-only flags are never exposed.
+func (r *Registry) ClientFlagValues(context interface{}) map[string]interface{} {
+	return r.flagValues(context, ClientMode)
+}
+
+// flagValues shares the results of variants, exclusion groups, and
+// buckets across the flags it evaluates, so that each is computed only
+// once for context. A flag whose middleware passes on a different
+// context is evaluated without them. Every flag is evaluated in the same
+// snapshot, so that a concurrent reload cannot mix two configs.
+func (r *Registry) flagValues(context interface{}, mode EvaluationMode) map[string]interface{} {
+	values := map[string]interface{}{}
+	s := r.load()
+	opts := &evalState{
+		snap:         s,
+		context:      context,
+		groupWinners: map[string]string{},
+		results:      map[string]bool{},
+		buckets:      bucketMemo{},
+		reasons:      map[string]string{},
+	}
+	for _, f := range s.flags {
+		if !mode.includes(f) {
+			continue
+		}
+		values[f.Name] = r.evaluate(f.Name, context, opts)
+	}
+	return values
+}
+
+// sameContext reports whether a and b are the same context: equal
+// values, or the same map, slice, or pointer. Maps with equal contents
+// are not the same, since middleware may have built one from the other.
+// Values that cannot be compared, such as structs holding maps, are not
+// the same either.
+func sameContext(a, b interface{}) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return va.Pointer() == vb.Pointer()
+	}
+	return va.Type().Comparable() && a == b
+}
+
+// Reasons of an Evaluation.
+const (
+	// EvaluationBase means the flag has its base value.
+	EvaluationBase = "base"
+	// EvaluationVariant means an active variant supplied the value.
+	EvaluationVariant = "variant"
+	// EvaluationDefault means middleware, such as Overrides, supplied the
+	// value without the flag being resolved.
+	EvaluationDefault = "default"
+)
+
+// An Evaluation is the value of a flag for a context, with the ID of the
+// variant that supplied it, if any, and the reason for the value.
+type Evaluation struct {
+	Value     interface{} `json:"value"`
+	VariantID string      `json:"variant_id,omitempty"`
+	Reason    string      `json:"reason"`
+}
+
+// EvaluateAllDetailed returns the evaluation of every flag registered
+// with the DefaultRegistry for context.
+func EvaluateAllDetailed(context interface{}) map[string]Evaluation {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.EvaluateAllDetailed(context)
+}
+
+// EvaluateAllDetailedMode returns the evaluation of the flags registered
+// with the DefaultRegistry that mode selects for context.
+func EvaluateAllDetailedMode(context interface{}, mode EvaluationMode) map[string]Evaluation {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.EvaluateAllDetailedMode(context, mode)
+}
+
+// EvaluateAllDetailed is like EvaluateAll, but maps each flag name to an
+// Evaluation attributing the flag's value, so that clients can log
+// exposures to every flag in one call.
+func (r *Registry) EvaluateAllDetailed(context interface{}) map[string]Evaluation {
+	return r.EvaluateAllDetailedMode(context, ServerMode)
+}
+
+// EvaluateAllDetailedMode is like EvaluateAllDetailed, but only evaluates
+// the flags that mode selects. Like EvaluateAll, it evaluates every flag
+// in the same snapshot.
+func (r *Registry) EvaluateAllDetailedMode(context interface{}, mode EvaluationMode) map[string]Evaluation {
+	evaluations := map[string]Evaluation{}
+	s := r.load()
+	opts := &evalState{
+		snap:         s,
+		context:      context,
+		groupWinners: map[string]string{},
+		results:      map[string]bool{},
+		buckets:      bucketMemo{},
+		reasons:      map[string]string{},
+	}
+	for _, f := range s.flags {
+		if !mode.includes(f) {
+			continue
+		}
+		opts.variantID, opts.resolved = "", false
+		e := Evaluation{Value: r.evaluate(f.Name, context, opts), VariantID: opts.variantID}
+		switch {
+		case !opts.resolved:
+			e.Reason = EvaluationDefault
+		case len(e.VariantID) > 0:
+			e.Reason = EvaluationVariant
+		default:
+			e.Reason = EvaluationBase
+		}
+		evaluations[f.Name] = e
+	}
+	return evaluations
+}
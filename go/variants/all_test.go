@@ -0,0 +1,197 @@
+package variants
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAllFlagValues(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "new_nav", "base_value": false, "client_safe": true},
+	    {"flag": "pricing_kill_switch", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "NewNav",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "new_nav", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	expected := map[string]interface{}{"new_nav": true, "pricing_kill_switch": false}
+	if v := r.AllFlagValues(nil); !reflect.DeepEqual(v, expected) {
+		t.Errorf("AllFlagValues: expected %v, got %v.", expected, v)
+	}
+	expected = map[string]interface{}{"new_nav": true}
+	if v := r.ClientFlagValues(nil); !reflect.DeepEqual(v, expected) {
+		t.Errorf("ClientFlagValues: expected %v, got %v.", expected, v)
+	}
+	if v := r.EvaluateAllMode(nil, ClientMode); !reflect.DeepEqual(v, expected) {
+		t.Errorf("EvaluateAllMode: expected %v in ClientMode, got %v.", expected, v)
+	}
+	if v := r.EvaluateAllMode(nil, EvaluationMode(7)); !reflect.DeepEqual(v, expected) {
+		t.Errorf("EvaluateAllMode: expected %v in an unknown mode, got %v.", expected, v)
+	}
+	detailed := r.EvaluateAllDetailedMode(nil, ClientMode)
+	if len(detailed) != 1 || detailed["new_nav"].Value != true {
+		t.Errorf("EvaluateAllDetailedMode: expected only new_nav in ClientMode, got %v.", detailed)
+	}
+	if detailed := r.EvaluateAllDetailed(nil); len(detailed) != 2 {
+		t.Errorf("EvaluateAllDetailed: expected every flag, got %v.", detailed)
+	}
+}
+
+func TestAllFlagValuesSharedBuckets(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "a", "base_value": false}, {"flag": "b", "base_value": false}],
+	  "variants": [{
+	    "id": "A",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 49]}],
+	    "mods": [{"flag": "a", "value": true}]
+	  }, {
+	    "id": "B",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 50, 99]}],
+	    "mods": [{"flag": "b", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	expected := map[string]interface{}{"a": false, "b": true}
+	if v := r.AllFlagValues(map[string]int{"user_id": 1275}); !reflect.DeepEqual(v, expected) {
+		t.Errorf("AllFlagValues: expected %v, got %v.", expected, v)
+	}
+}
+
+func TestEvaluateAllEvaluatesVariantsOnce(t *testing.T) {
+	r := NewRegistry()
+	calls := 0
+	r.RegisterConditionType("COUNTED", func(values ...interface{}) func(interface{}) bool {
+		return func(context interface{}) bool {
+			calls++
+			return true
+		}
+	})
+	config := `{
+	  "flag_defs": [{"flag": "a", "base_value": false}, {"flag": "b", "base_value": false}, {"flag": "c", "base_value": false}],
+	  "variants": [{
+	    "id": "Shared",
+	    "conditions": [{"type": "COUNTED"}],
+	    "mods": [{"flag": "a", "value": true}, {"flag": "b", "value": true}, {"flag": "c", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	expected := map[string]interface{}{"a": true, "b": true, "c": true}
+	if v := r.EvaluateAll(nil); !reflect.DeepEqual(v, expected) {
+		t.Errorf("EvaluateAll: expected %v, got %v.", expected, v)
+	}
+	if calls != 1 {
+		t.Errorf("EvaluateAll: expected the shared variant to be evaluated once, got %d evaluations.", calls)
+	}
+}
+
+func TestEvaluateAllDetailed(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "new_nav", "base_value": false},
+	    {"flag": "dark_mode", "base_value": false},
+	    {"flag": "pricing_kill_switch", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "NewNav",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "new_nav", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	r.Use(Overrides(map[string]interface{}{"pricing_kill_switch": true}))
+	expected := map[string]Evaluation{
+		"new_nav":             {Value: true, VariantID: "NewNav", Reason: EvaluationVariant},
+		"dark_mode":           {Value: false, Reason: EvaluationBase},
+		"pricing_kill_switch": {Value: true, Reason: EvaluationDefault},
+	}
+	if e := r.EvaluateAllDetailed(nil); !reflect.DeepEqual(e, expected) {
+		t.Errorf("EvaluateAllDetailed: expected %v, got %v.", expected, e)
+	}
+}
+
+func TestEvaluateAllMiddlewareContext(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "a", "base_value": false}, {"flag": "b", "base_value": false}],
+	  "variants": [{
+	    "id": "OnlyB",
+	    "conditions": [{"type": "WHITELIST", "values": ["flag", "b"]}],
+	    "mods": [{"flag": "a", "value": true}, {"flag": "b", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	// Tag the context with the name of the flag evaluated.
+	r.Use(func(next Evaluator) Evaluator {
+		return func(name string, context interface{}) interface{} {
+			tagged := map[string]interface{}{"flag": name}
+			for k, v := range context.(map[string]interface{}) {
+				tagged[k] = v
+			}
+			return next(name, tagged)
+		}
+	})
+	context := map[string]interface{}{"id": 1}
+	expected := map[string]interface{}{"a": false, "b": true}
+	for i := 0; i < 10; i++ {
+		if v := r.EvaluateAll(context); !reflect.DeepEqual(v, expected) {
+			t.Fatalf("EvaluateAll: expected %v, got %v.", expected, v)
+		}
+		for name, e := range r.EvaluateAllDetailed(context) {
+			if e.Value != expected[name] {
+				t.Fatalf("EvaluateAllDetailed: expected %s to be %v, got %v.", name, expected[name], e.Value)
+			}
+		}
+	}
+	for name, value := range expected {
+		if v := r.FlagValueWithContext(name, context); v != value {
+			t.Errorf("FlagValueWithContext: expected %s to be %v, got %v.", name, value, v)
+		}
+	}
+}
+
+func TestEvaluateAllOneSnapshot(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(`{"flag_defs": [{"flag": "a", "base_value": 1}, {"flag": "b", "base_value": 1}]}`)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	// Reload the config, incrementing every base value, midway through
+	// evaluating every flag.
+	base, reloaded := 1, false
+	r.Use(func(next Evaluator) Evaluator {
+		return func(name string, context interface{}) interface{} {
+			if !reloaded {
+				reloaded, base = true, base+1
+				config := fmt.Sprintf(`{"flag_defs": [{"flag": "a", "base_value": %d}, {"flag": "b", "base_value": %d}]}`, base, base)
+				if err := r.ReloadJSON([]byte(config)); err != nil {
+					t.Fatalf("ReloadJSON: expected no error, but got %q.", err.Error())
+				}
+			}
+			return next(name, context)
+		}
+	})
+	if v := r.EvaluateAll(nil); v["a"] != 1.0 || v["b"] != 1.0 {
+		t.Errorf("EvaluateAll: expected every flag from the config before the reload, got %v.", v)
+	}
+	reloaded = false
+	if e := r.EvaluateAllDetailed(nil); e["a"].Value != 2.0 || e["b"].Value != 2.0 {
+		t.Errorf("EvaluateAllDetailed: expected every flag from the config before the reload, got %v.", e)
+	}
+}
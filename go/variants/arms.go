@@ -0,0 +1,77 @@
+package variants
+
+import (
+	"fmt"
+	"math"
+)
+
+// An Arm is one of the weighted arms of a variant. Each mod of the
+// variant supplies the arm's value at the arm's index in its ArmValues.
+type Arm struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+// armWeightTotal is the sum of the weights of a variant's arms.
+const armWeightTotal = 100
+
+// checkArms returns an error if v's arms are not weighted in total by
+// armWeightTotal, or if its mods do not supply a value for each arm.
+func checkArms(v *Variant) error {
+	if len(v.Arms) == 0 {
+		for _, m := range v.Mods {
+			if len(m.ArmValues) > 0 {
+				return fmt.Errorf("Mod of flag %q in variant %q has arm values, but the variant has no arms.", m.FlagName, v.ID)
+			}
+		}
+		return nil
+	}
+	if len(v.ArmKey) == 0 {
+		return fmt.Errorf("Variant with ID %q has arms but no arm key.", v.ID)
+	}
+	total := 0.0
+	for i, a := range v.Arms {
+		if a.Weight < 0 {
+			return fmt.Errorf("Arm %d of variant %q has negative weight %v.", i, v.ID, a.Weight)
+		}
+		total += a.Weight
+	}
+	if math.Abs(total-armWeightTotal) > 1e-9 {
+		return fmt.Errorf("Arms of variant %q have weights summing to %v, not %d.", v.ID, total, armWeightTotal)
+	}
+	for _, m := range v.Mods {
+		if len(m.ArmValues) > 0 && len(m.ArmValues) != len(v.Arms) {
+			return fmt.Errorf("Mod of flag %q in variant %q has %d arm values for %d arms.", m.FlagName, v.ID, len(m.ArmValues), len(v.Arms))
+		}
+	}
+	return nil
+}
+
+// arm returns the index of the arm of the receiver selected by the
+// consistent hash of context[ArmKey], and whether one was selected. A
+// variant without arms has the single arm 0.
+func (v *Variant) arm(context interface{}) (int, bool) {
+	if len(v.Arms) == 0 {
+		return 0, true
+	}
+	// The arms are bucketed apart from the variant's conditions, so that
+	// a PERCENTAGE rollout of the variant is split between all its arms.
+	b, ok := percentBucket(context, v.salt()+"/arms", v.ArmKey)
+	if !ok {
+		return 0, false
+	}
+	cumulative := 0.0
+	for i, a := range v.Arms {
+		cumulative += a.Weight
+		if float64(b) < cumulative*percentBuckets/armWeightTotal {
+			return i, true
+		}
+	}
+	return len(v.Arms) - 1, true
+}
+
+// hasArm reports whether context selects an arm of the receiver.
+func (v *Variant) hasArm(context interface{}) bool {
+	_, ok := v.arm(context)
+	return ok
+}
@@ -0,0 +1,92 @@
+package variants
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestArms(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "checkout", "base_value": "control"}],
+	  "variants": [{
+	    "id": "CheckoutTest",
+	    "unconditional": true,
+	    "arm_key": "user_id",
+	    "arms": [{"name": "a", "weight": 50}, {"name": "b", "weight": 30}, {"name": "c", "weight": 20}],
+	    "mods": [{"flag": "checkout", "arm_values": ["one-page", "two-page", "express"]}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+
+	const n = 10000
+	counts := map[interface{}]int{}
+	for i := 0; i < n; i++ {
+		context := map[string]string{"user_id": fmt.Sprint(i)}
+		val := r.FlagValueWithContext("checkout", context)
+		counts[val]++
+		if again := r.FlagValueWithContext("checkout", context); again != val {
+			t.Fatalf("FlagValueWithContext: expected user %d to stay in arm %v, got %v.", i, val, again)
+		}
+	}
+	for val, weight := range map[string]float64{"one-page": 50, "two-page": 30, "express": 20} {
+		if share := float64(counts[val]) * 100 / n; math.Abs(share-weight) > 2 {
+			t.Errorf("FlagValueWithContext: expected about %v%% of users to get %q, got %v%%.", weight, val, share)
+		}
+	}
+
+	if val := r.FlagValueWithContext("checkout", map[string]string{}); val != "control" {
+		t.Errorf("FlagValueWithContext: expected the base value without the arm key, got %v.", val)
+	}
+	forced := map[string]bool{"CheckoutTest": true}
+	if val := r.FlagValueWithContextWithForcedVariants("checkout", map[string]string{}, forced); val != "control" {
+		t.Errorf("FlagValueWithContextWithForcedVariants: expected the base value of a forced variant without the arm key, got %v.", val)
+	}
+	if !r.RequiresContext("checkout") {
+		t.Error("RequiresContext: expected a flag split into arms to require a context.")
+	}
+}
+
+func TestArmsInvalid(t *testing.T) {
+	testCases := []struct {
+		Variant  string
+		Expected string
+	}{
+		{
+			`"arm_key": "user_id", "arms": [{"weight": 50}, {"weight": 40}], "mods": [{"flag": "checkout", "arm_values": ["a", "b"]}]`,
+			`Arms of variant "Armed" have weights summing to 90, not 100.`,
+		},
+		{
+			`"arm_key": "user_id", "arms": [{"weight": 150}, {"weight": -50}], "mods": [{"flag": "checkout", "arm_values": ["a", "b"]}]`,
+			`Arm 1 of variant "Armed" has negative weight -50.`,
+		},
+		{
+			`"arms": [{"weight": 50}, {"weight": 50}], "mods": [{"flag": "checkout", "arm_values": ["a", "b"]}]`,
+			`Variant with ID "Armed" has arms but no arm key.`,
+		},
+		{
+			`"arm_key": "user_id", "arms": [{"weight": 50}, {"weight": 50}], "mods": [{"flag": "checkout", "arm_values": ["a"]}]`,
+			`Mod of flag "checkout" in variant "Armed" has 1 arm values for 2 arms.`,
+		},
+		{
+			`"mods": [{"flag": "checkout", "arm_values": ["a", "b"]}]`,
+			`Mod of flag "checkout" in variant "Armed" has arm values, but the variant has no arms.`,
+		},
+		{
+			`"arm_key": "user_id", "arms": [{"weight": 50}, {"weight": 50}], "mods": [{"flag": "checkout", "arm_values": ["a", 2]}]`,
+			`Mod of flag "checkout" in variant "Armed" has arm value 2, not of type "string".`,
+		},
+	}
+	for _, tc := range testCases {
+		config := `{
+		  "flag_defs": [{"flag": "checkout", "base_value": "control", "value_type": "string"}],
+		  "variants": [{"id": "Armed", "unconditional": true, ` + tc.Variant + `}]
+		}`
+		if err := NewRegistry().LoadJSON([]byte(config)); err == nil || err.Error() != tc.Expected {
+			t.Errorf("LoadJSON: expected error %q, got %v.", tc.Expected, err)
+		}
+	}
+}
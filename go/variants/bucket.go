@@ -0,0 +1,195 @@
+package variants
+
+import (
+	"hash/fnv"
+	"reflect"
+	"sort"
+)
+
+// A Bucket describes the bucket a context was assigned to by one
+// bucketing condition, with everything needed to reproduce it.
+type Bucket struct {
+	VariantID     string
+	ConditionType string
+
+	// Key is the context key whose value was bucketed.
+	Key string
+
+	// Salt is mixed into the bucket computation, if the condition type
+	// uses one.
+	Salt string
+
+	// Value is the computed bucket, in [0, Buckets).
+	Value   int
+	Buckets int
+
+	// Active reports whether the bucket satisfies the condition.
+	Active bool
+}
+
+// bucketers compute the bucket of a context for the built-in bucketing
+// condition types, given the condition's arguments and its variant. They
+// return false if the context cannot be bucketed. Buckets already computed
+// during the same evaluation are reused from memo, which may be nil.
+var bucketers = map[string]func(args []interface{}, v *Variant, context interface{}, memo bucketMemo) (Bucket, bool){
+	conditionTypeModRange: func(args []interface{}, v *Variant, context interface{}, memo bucketMemo) (Bucket, bool) {
+		if len(args) != 3 {
+			return Bucket{}, false
+		}
+		key, ok := args[0].(string)
+		begin, beginOK := toInt(args[1])
+		end, endOK := toInt(args[2])
+		if !ok || !beginOK || !endOK {
+			return Bucket{}, false
+		}
+		value, ok := contextValue(context, key)
+		if !ok {
+			return Bucket{}, false
+		}
+		mod, ok := memo.bucket("", value, func() (int, bool) {
+			n, ok := toInt(value)
+			return n % 100, ok
+		})
+		if !ok {
+			return Bucket{}, false
+		}
+		return Bucket{
+			Key:     key,
+			Value:   mod,
+			Buckets: 100,
+			Active:  mod >= begin && mod <= end,
+		}, true
+	},
+	conditionTypePercent: func(args []interface{}, v *Variant, context interface{}, memo bucketMemo) (Bucket, bool) {
+		if len(args) != 2 {
+			return Bucket{}, false
+		}
+		key, ok := args[0].(string)
+		percent, percentOK := toFloat(args[1])
+		if !ok || !percentOK {
+			return Bucket{}, false
+		}
+		value, ok := contextValue(context, key)
+		if !ok {
+			return Bucket{}, false
+		}
+		salt := v.salt()
+		b, ok := memo.bucket(salt, value, func() (int, bool) {
+			return percentBucket(context, salt, key)
+		})
+		if !ok {
+			return Bucket{}, false
+		}
+		return Bucket{
+			Key:     key,
+			Salt:    salt,
+			Value:   b,
+			Buckets: percentBuckets,
+			Active:  float64(b) < percent*percentBuckets/100,
+		}, true
+	},
+}
+
+// The number of buckets of PERCENTAGE conditions, allowing percentages
+// with two decimal places.
+const percentBuckets = 10000
+
+// percentBucket returns the bucket of the ID context[key] for salt, a
+// hash of the two that is stable across processes.
+func percentBucket(context interface{}, salt, key string) (int, bool) {
+	v, ok := contextValue(context, key)
+	if !ok {
+		return 0, false
+	}
+	id, ok := toString(v)
+	if !ok {
+		return 0, false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(salt))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	return int(h.Sum32() % percentBuckets), true
+}
+
+// A bucketMemo caches the buckets computed during one evaluation, such as
+// one AllFlagValues call, keyed on the salt and the bucketed context value,
+// so that flags bucketing the same user compute the bucket only once.
+type bucketMemo map[bucketKey]memoizedBucket
+
+type bucketKey struct {
+	salt  string
+	value interface{}
+}
+
+type memoizedBucket struct {
+	bucket int
+	ok     bool
+}
+
+// bucket returns the bucket of value with the given salt, calling compute
+// only if it is not already cached.
+func (m bucketMemo) bucket(salt string, value interface{}, compute func() (int, bool)) (int, bool) {
+	if m == nil || value == nil || !reflect.TypeOf(value).Comparable() {
+		return compute()
+	}
+	k := bucketKey{salt: salt, value: value}
+	if b, found := m[k]; found {
+		return b.bucket, b.ok
+	}
+	b, ok := compute()
+	m[k] = memoizedBucket{bucket: b, ok: ok}
+	return b, ok
+}
+
+// modBucket returns the integer value of context[key] modulo 100.
+func modBucket(context interface{}, key string) (int, bool) {
+	v, ok := contextValue(context, key)
+	if !ok {
+		return 0, false
+	}
+	n, ok := toInt(v)
+	if !ok {
+		return 0, false
+	}
+	return n % 100, true
+}
+
+// BucketFor returns the buckets computed for context by the bucketing
+// conditions keyed on key within the DefaultRegistry.
+func BucketFor(key string, context interface{}) []Bucket {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.BucketFor(key, context)
+}
+
+// BucketFor returns, for every bucketing condition (such as MOD_RANGE)
+// of every registered variant that buckets on the context key key, the
+// bucket computed for context and whether it satisfies the condition.
+// This answers which bucket a user landed in for a rollout. Conditions
+// that cannot bucket context, for example because it lacks key, are
+// omitted. Results are ordered by variant ID.
+func (r *Registry) BucketFor(key string, context interface{}) []Bucket {
+	s := r.load()
+	context = s.mergeDefaultContext(context)
+	buckets := []Bucket{}
+	for _, v := range s.variants {
+		for _, c := range v.allConditions() {
+			fn, ok := bucketers[c.Type]
+			if !ok {
+				continue
+			}
+			b, ok := fn(c.args(), &v, context, nil)
+			if !ok || b.Key != key {
+				continue
+			}
+			b.VariantID, b.ConditionType = v.ID, c.Type
+			b.Active = b.Active != c.Negate
+			buckets = append(buckets, b)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].VariantID < buckets[j].VariantID
+	})
+	return buckets
+}
@@ -0,0 +1,49 @@
+package variants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBucketFor(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	expected := []Bucket{{
+		VariantID:     "ModRangeTest",
+		ConditionType: "MOD_RANGE",
+		Key:           "user_id",
+		Value:         42,
+		Buckets:       100,
+		Active:        false,
+	}}
+	if b := r.BucketFor("user_id", map[string]int{"user_id": 1242}); !reflect.DeepEqual(b, expected) {
+		t.Errorf("BucketFor: expected %+v, got %+v.", expected, b)
+	}
+	if b := r.BucketFor("user_id", map[string]int{}); len(b) != 0 {
+		t.Errorf("BucketFor: expected no buckets without a user_id, got %+v.", b)
+	}
+}
+
+func TestBucketMemo(t *testing.T) {
+	memo := bucketMemo{}
+	calls := 0
+	compute := func() (int, bool) {
+		calls++
+		return 7, true
+	}
+	for i := 0; i < 3; i++ {
+		if b, ok := memo.bucket("salt", 1234, compute); b != 7 || !ok {
+			t.Errorf("bucket: expected 7, got %d (%v).", b, ok)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("bucket: expected one computation, got %d.", calls)
+	}
+	memo.bucket("other", 1234, compute)
+	memo.bucket("salt", []int{1}, compute)
+	if calls != 3 {
+		t.Errorf("bucket: expected new salts and uncomparable values to be computed, got %d computations.", calls)
+	}
+}
@@ -0,0 +1,108 @@
+package variants
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// OnChange registers fn with the DefaultRegistry to be called with the
+// name of each flag changed by a reload.
+func OnChange(fn func(flagName string)) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.OnChange(fn)
+}
+
+// OnChange registers fn to be called with the name of each flag changed
+// by a reload, such as ReloadJSON or ReloadConfig, so that values cached
+// from the flag can be invalidated. Since a flag's value depends on the
+// context, a flag is considered changed when its definition, the set of
+// variants modifying it, or the definition of any of those variants
+// differs after the reload, whether or not its value for a particular
+// context does, or when the flag is removed by a Replace reload.
+// Callbacks are called in the order registered, once per changed flag in
+// name order, after the reload completes and without the receiver's lock
+// held, so they may call back into the registry.
+func (r *Registry) OnChange(fn func(flagName string)) {
+	r.Lock()
+	defer r.Unlock()
+	r.changeHandlers = append(r.changeHandlers, fn)
+}
+
+// reload applies fn to the receiver's snapshot like update, then notifies
+// the change handlers of the flags that changed.
+func (r *Registry) reload(fn func(s *snapshot) error) error {
+	r.Lock()
+	before := r.load()
+	if err := r.update(fn); err != nil {
+		r.Unlock()
+		return err
+	}
+	after := r.load()
+	handlers := r.changeHandlers
+	r.Unlock()
+
+	if len(handlers) == 0 {
+		return nil
+	}
+	for _, name := range changedFlags(before, after) {
+		for _, fn := range handlers {
+			fn(name)
+		}
+	}
+	return nil
+}
+
+// changedFlags returns the sorted names of the flags of after whose
+// definition, variants, or variant definitions differ from before, and of
+// the flags of before removed from after.
+func changedFlags(before, after *snapshot) []string {
+	var changed []string
+	for name, f := range after.flags {
+		old, found := before.flags[name]
+		if !found || !sameDefinition(old, f) || !sameVariants(before, after, name) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before.flags {
+		if _, found := after.flags[name]; !found {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// sameVariants reports whether the named flag is modified by the same
+// variants, with the same definitions, in before and after.
+func sameVariants(before, after *snapshot, name string) bool {
+	ids := after.flagToVariantIDMap[name]
+	if len(ids) != len(before.flagToVariantIDMap[name]) {
+		return false
+	}
+	for id := range ids {
+		if _, found := before.flagToVariantIDMap[name][id]; !found {
+			return false
+		}
+		if !sameDefinition(before.variants[id], after.variants[id]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDefinition reports whether a and b have the same JSON encoding,
+// which covers a definition but not the evaluators built from it.
+// Definitions that cannot be encoded are never the same.
+func sameDefinition(a, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
@@ -0,0 +1,71 @@
+package variants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOnChange(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "a", "base_value": 1},
+	    {"flag": "b", "base_value": 2},
+	    {"flag": "c", "base_value": 3}
+	  ],
+	  "variants": [{
+	    "id": "A",
+	    "conditions": [{"type": "RANDOM", "value": 0.5}],
+	    "mods": [{"flag": "a", "value": 10}]
+	  }, {
+	    "id": "B",
+	    "conditions": [{"type": "RANDOM", "value": 0.5}],
+	    "mods": [{"flag": "b", "value": 20}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	var changed []string
+	r.OnChange(func(name string) {
+		// Callbacks run without the lock held.
+		r.FlagValue(name)
+		changed = append(changed, name)
+	})
+
+	testCases := []struct {
+		Reload   func() error
+		Expected []string
+	}{
+		// Reloading identical definitions changes nothing.
+		{func() error { return r.ReloadJSON([]byte(config)) }, nil},
+		// A new flag, and a changed base value.
+		{func() error {
+			return r.ReloadJSON([]byte(`{"flag_defs": [{"flag": "c", "base_value": 4}, {"flag": "d", "base_value": 5}]}`))
+		}, []string{"c", "d"}},
+		// A changed condition of a variant.
+		{func() error {
+			return r.ReloadVariants([]byte(`{"variants": [{"id": "A", "conditions": [{"type": "RANDOM", "value": 0.7}], "mods": [{"flag": "a", "value": 10}]}]}`))
+		}, []string{"a"}},
+		// A variant moving from one flag to another.
+		{func() error {
+			return r.ReloadVariants([]byte(`{"variants": [{"id": "B", "conditions": [{"type": "RANDOM", "value": 0.5}], "mods": [{"flag": "c", "value": 20}]}]}`))
+		}, []string{"b", "c"}},
+		// A failed reload.
+		{func() error {
+			if err := r.ReloadJSON([]byte(`{"flag_defs": [{"flag": "a", "base_value": 0, "combine": "bogus"}]}`)); err == nil {
+				t.Error("ReloadJSON: expected an error for an unknown combine strategy, got none.")
+			}
+			return nil
+		}, nil},
+	}
+	for i, tc := range testCases {
+		changed = nil
+		if err := tc.Reload(); err != nil {
+			t.Fatalf("Reload %d: expected no error, but got %q.", i, err.Error())
+		}
+		if !reflect.DeepEqual(changed, tc.Expected) {
+			t.Errorf("Reload %d: expected changes to %v, got %v.", i, tc.Expected, changed)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package variants
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A clock holds the function returning the current time for conditions
+// that depend on it. It is read while the registry lock is held, so it
+// is replaced atomically instead.
+type clock struct {
+	now atomic.Value // func() time.Time
+}
+
+// SetClock sets the function used by the DefaultRegistry to tell the
+// current time.
+func SetClock(now func() time.Time) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetClock(now)
+}
+
+// SetClock sets the function used to tell the current time when
+// evaluating time-based conditions such as LAUNCH, for example to test a
+// schedule. A nil now restores time.Now.
+func (r *Registry) SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	r.clock.now.Store(now)
+}
+
+// now returns the current time according to the receiver's clock.
+func (r *Registry) now() time.Time {
+	if now, ok := r.clock.now.Load().(func() time.Time); ok {
+		return now()
+	}
+	return time.Now()
+}
+
+// parseBound parses a bound of a time window given as an RFC 3339 string.
+// A missing bound, given as null or the empty string, is the zero time.
+func parseBound(v interface{}) (time.Time, bool) {
+	if v == nil {
+		return time.Time{}, true
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	if len(s) == 0 {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+// contextTime returns the time stored under key in context, either as a
+// time.Time, as an RFC 3339 string, or as a number of seconds since the
+// Unix epoch.
+func contextTime(context interface{}, key string) (time.Time, bool) {
+	v, ok := contextValue(context, key)
+	if !ok {
+		return time.Time{}, false
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		return parsed, err == nil
+	}
+	if secs, ok := toInt(v); ok {
+		return time.Unix(int64(secs), 0), true
+	}
+	return time.Time{}, false
+}
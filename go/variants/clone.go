@@ -0,0 +1,111 @@
+package variants
+
+import "time"
+
+// Clone returns an independent copy of the DefaultRegistry.
+func Clone() *Registry {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.Clone()
+}
+
+// Clone returns a copy of the receiver for trying out changes, such as a
+// candidate config, and evaluating them without affecting the receiver.
+// The copy has the receiver's flags, variants, default context, condition
+// and mod types, flag decoders, middleware, token key, and clock, and
+// changes made to either registry afterwards leave the other alone.
+// Conditions are reconstructed by the copy's condition types, so that
+// those reading the registry, such as FLAG_EQUALS, read the copy.
+//
+// Observers, loggers, change callbacks, and event streams are not copied,
+// so evaluations of the copy are not reported through the receiver's. The
+// copy draws RANDOM conditions from the global source, and starts with no
+// subjects enrolled in capped variants.
+func (r *Registry) Clone() *Registry {
+	c := NewRegistry()
+	r.RLock()
+	for id, fn := range r.conditionSpecs {
+		if _, builtIn := c.conditionSpecs[id]; builtIn {
+			continue
+		}
+		if bind, contextual := r.contextualSpecs[id]; contextual {
+			c.conditionSpecs[id] = ignoreErr(func(values ...interface{}) func(interface{}) bool {
+				return bind(c, values...)
+			})
+			c.contextualSpecs[id] = bind
+			continue
+		}
+		c.conditionSpecs[id] = fn
+	}
+	for id, schema := range r.conditionSchemas {
+		c.conditionSchemas[id] = schema
+	}
+	for id, args := range r.conditionArgs {
+		c.conditionArgs[id] = args
+	}
+	for id, fn := range r.modSpecs {
+		c.modSpecs[id] = fn
+	}
+	for name, fn := range r.flagDecoders {
+		c.flagDecoders[name] = fn
+	}
+	c.specInits = append(c.specInits, r.specInits...)
+	c.hooks.Store(&hooks{middleware: r.loadHooks().middleware})
+	c.tokenKey = r.tokenKey
+	r.RUnlock()
+	if now, ok := r.clock.now.Load().(func() time.Time); ok {
+		c.SetClock(now)
+	}
+
+	s := r.load().clone()
+	s.exposures = NewMemoryExposureStore()
+	for id, v := range s.variants {
+		v.Conditions = c.rewireConditions(&v, v.Conditions)
+		v.ConditionGroups = c.rewireGroups(&v, v.ConditionGroups)
+		s.variants[id] = v
+	}
+	s.index()
+	c.snap.Store(s)
+	return c
+}
+
+// rewireConditions returns a copy of conditions, conditions of v, with
+// evaluators constructed by the receiver's condition types. A condition
+// whose type is not registered with the receiver, or which its type
+// rejects, keeps its evaluator.
+func (r *Registry) rewireConditions(v *Variant, conditions []Condition) []Condition {
+	if conditions == nil {
+		return nil
+	}
+	rewired := make([]Condition, len(conditions))
+	copy(rewired, conditions)
+	for i, c := range rewired {
+		fn, ok := r.conditionSpecs[c.Type]
+		if !ok {
+			continue
+		}
+		args := c.args()
+		if saltedConditionTypes[c.Type] {
+			args = append([]interface{}{v.salt()}, args...)
+		}
+		if eval, err := constructEvaluator(fn, args); err == nil {
+			rewired[i].Evaluator = eval
+		}
+	}
+	return rewired
+}
+
+// rewireGroups is like rewireConditions for the conditions of groups and
+// their nested groups.
+func (r *Registry) rewireGroups(v *Variant, groups []ConditionGroup) []ConditionGroup {
+	if groups == nil {
+		return nil
+	}
+	rewired := make([]ConditionGroup, len(groups))
+	for i, g := range groups {
+		rewired[i] = g
+		rewired[i].Conditions = r.rewireConditions(v, g.Conditions)
+		rewired[i].Groups = r.rewireGroups(v, g.Groups)
+	}
+	return rewired
+}
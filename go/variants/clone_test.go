@@ -0,0 +1,69 @@
+package variants
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "plan", "base_value": "free"},
+	    {"flag": "pro_feature", "base_value": false},
+	    {"flag": "launched", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "ProFeature",
+	    "conditions": [{"type": "FLAG_EQUALS", "values": ["plan", "pro"]}],
+	    "mods": [{"flag": "pro_feature", "value": true}]
+	  }, {
+	    "id": "Launch",
+	    "condition_groups": [{"conditions": [{"type": "TIME_RANGE", "values": ["2026-01-01T00:00:00Z", null]}]}],
+	    "group_operator": "AND",
+	    "mods": [{"flag": "launched", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	r.SetClock(func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) })
+
+	c := r.Clone()
+	if v := c.FlagValue("launched"); v != false {
+		t.Errorf("FlagValue: expected the clone to keep the receiver's clock, got %v.", v)
+	}
+	c.SetClock(func() time.Time { return time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC) })
+	if v := c.FlagValue("launched"); v != true {
+		t.Errorf("FlagValue: expected the clone to follow its own clock, got %v.", v)
+	}
+	if v := r.FlagValue("launched"); v != false {
+		t.Errorf("FlagValue: expected the receiver to ignore the clone's clock, got %v.", v)
+	}
+
+	if err := c.AddFlag(Flag{Name: "experimental", BaseValue: true}); err != nil {
+		t.Fatalf("AddFlag: expected no error, but got %q.", err.Error())
+	}
+	if err := c.AddVariant(Variant{ID: "Pro", Unconditional: true, Mods: []Mod{{FlagName: "plan", Value: "pro"}}}); err != nil {
+		t.Fatalf("AddVariant: expected no error, but got %q.", err.Error())
+	}
+	if v := c.FlagValue("pro_feature"); v != true {
+		t.Errorf("FlagValue: expected FLAG_EQUALS in the clone to read the clone, got %v.", v)
+	}
+	if v := r.FlagValue("pro_feature"); v != false {
+		t.Errorf("FlagValue: expected the receiver to be unaffected by the clone, got %v.", v)
+	}
+	if _, found := r.Flag("experimental"); found {
+		t.Error("Flag: expected a flag added to the clone not to be registered with the receiver.")
+	}
+	if len(r.Variants()) != 2 || len(c.Variants()) != 3 {
+		t.Errorf("Variants: expected 2 variants in the receiver and 3 in the clone, got %d and %d.", len(r.Variants()), len(c.Variants()))
+	}
+
+	if err := r.AddFlag(Flag{Name: "later", BaseValue: true}); err != nil {
+		t.Fatalf("AddFlag: expected no error, but got %q.", err.Error())
+	}
+	if _, found := c.Flag("later"); found {
+		t.Error("Flag: expected a flag added to the receiver afterwards not to be registered with the clone.")
+	}
+}
@@ -0,0 +1,84 @@
+package variants
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Strategies combining the values of a flag's active variants.
+const (
+	// CombineOverride uses the value of the highest-priority active
+	// variant. It is the default.
+	CombineOverride = "override"
+	// CombineAppend concatenates the values of every active variant.
+	CombineAppend = "append"
+	// CombineUnion is like CombineAppend, without duplicate elements.
+	CombineUnion = "union"
+)
+
+// checkCombine returns an error if f has an unknown combine strategy.
+func checkCombine(f Flag) error {
+	switch f.Combine {
+	case "", CombineOverride, CombineAppend, CombineUnion:
+		return nil
+	}
+	return fmt.Errorf("Flag %q has unknown combine strategy %q.", f.Name, f.Combine)
+}
+
+// combineValues returns the values of the named flag set by every active
+// variant of the receiver, combined by the flag's strategy, and the ID of
+// the highest-priority active variant.
+func (r *Registry) combineValues(name string, st *evalState) (interface{}, string) {
+	s := st.snap
+	f := s.flags[name]
+	var combined []interface{}
+	variantID := ""
+	for _, variant := range s.flagVariants[name] {
+		if !r.isActive(variant, st) {
+			continue
+		}
+		val, ok := variant.modValue(name, st.context)
+		if !ok {
+			continue
+		}
+		if len(variantID) == 0 {
+			variantID = variant.ID
+		}
+		for _, e := range elements(val) {
+			if f.Combine == CombineUnion && containsValue(combined, e) {
+				continue
+			}
+			combined = append(combined, e)
+		}
+	}
+	if len(variantID) == 0 {
+		return f.BaseValue, ""
+	}
+	if combined == nil {
+		combined = []interface{}{}
+	}
+	return combined, variantID
+}
+
+// elements returns the elements of v if it is a slice or an array, or v
+// itself as the only element otherwise.
+func elements(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{v}
+	}
+	result := make([]interface{}, rv.Len())
+	for i := range result {
+		result[i] = rv.Index(i).Interface()
+	}
+	return result
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package variants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "experiments", "base_value": [], "combine": "append"},
+	    {"flag": "features", "base_value": ["basic"], "combine": "union"}
+	  ],
+	  "variants": [{
+	    "id": "Search",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a", "b"]}],
+	    "priority": 1,
+	    "mods": [{"flag": "experiments", "value": ["search_v2"]}, {"flag": "features", "value": ["basic", "search"]}]
+	  }, {
+	    "id": "Nav",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "experiments", "value": "new_nav"}, {"flag": "features", "value": ["search", "nav"]}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		ID          string
+		Experiments interface{}
+		Features    interface{}
+	}{
+		{"a", []interface{}{"search_v2", "new_nav"}, []interface{}{"basic", "search", "nav"}},
+		{"b", []interface{}{"search_v2"}, []interface{}{"basic", "search"}},
+		{"c", []interface{}{}, []interface{}{"basic"}},
+	}
+	for _, tc := range testCases {
+		ctx := map[string]string{"id": tc.ID}
+		if v := r.FlagValueWithContext("experiments", ctx); !reflect.DeepEqual(v, tc.Experiments) {
+			t.Errorf("FlagValueWithContext: expected experiments of %q to be %v, got %v.", tc.ID, tc.Experiments, v)
+		}
+		if v := r.FlagValueWithContext("features", ctx); !reflect.DeepEqual(v, tc.Features) {
+			t.Errorf("FlagValueWithContext: expected features of %q to be %v, got %v.", tc.ID, tc.Features, v)
+		}
+	}
+
+	expected := `Flag "experiments" has unknown combine strategy "merge".`
+	err := NewRegistry().LoadJSON([]byte(`{"flag_defs": [{"flag": "experiments", "base_value": [], "combine": "merge"}]}`))
+	if err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+	}
+}
@@ -0,0 +1,700 @@
+package variants
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	conditionTypeRandom    = "RANDOM"
+	conditionTypeModRange  = "MOD_RANGE"
+	conditionTypeIDSet     = "ID_SET"
+	conditionTypeWhitelist = "WHITELIST"
+	conditionTypeInSet     = "IN_SET"
+	conditionTypeNotInSet  = "NOT_IN_SET"
+	conditionTypeNumeric   = "NUMERIC"
+	conditionTypeLocale    = "LOCALE"
+	conditionTypeLaunch    = "LAUNCH"
+	conditionTypePercent   = "PERCENTAGE"
+	conditionTypeTimeRange = "TIME_RANGE"
+	conditionTypeCohort    = "COHORT"
+	conditionTypeMatch     = "STRING_MATCH"
+	conditionTypeGeo       = "GEO"
+	conditionTypeFlagEq    = "FLAG_EQUALS"
+	conditionTypeVersion   = "VERSION"
+	conditionTypeRamp      = "RAMP"
+	conditionTypeHasKey    = "HAS_KEY"
+	conditionTypeCIDR      = "CIDR"
+	conditionTypeSchedule  = "SCHEDULE"
+)
+
+// defaultWhitelistKey is the context key read by WHITELIST conditions
+// given a single ID.
+const defaultWhitelistKey = "id"
+
+// Modes of STRING_MATCH conditions, given the pattern, returning a
+// function matching strings.
+var stringMatchModes = map[string]func(pattern string) func(s string) bool{
+	"PREFIX": func(pattern string) func(s string) bool {
+		return func(s string) bool { return strings.HasPrefix(s, pattern) }
+	},
+	"SUFFIX": func(pattern string) func(s string) bool {
+		return func(s string) bool { return strings.HasSuffix(s, pattern) }
+	},
+	"CONTAINS": func(pattern string) func(s string) bool {
+		return func(s string) bool { return strings.Contains(s, pattern) }
+	},
+	"EXACT": func(pattern string) func(s string) bool {
+		return func(s string) bool { return s == pattern }
+	},
+	"REGEX": func(pattern string) func(s string) bool {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil
+		}
+		return re.MatchString
+	},
+}
+
+// setCondition returns the condition spec of a set membership condition
+// type, whose values are a context key followed by the members of the set.
+// Members and context values are compared as text, so that the number 42
+// is a member of a set containing "42". If negate is true, the condition
+// is met by a context value that is not a member. A context without the
+// key never meets the condition.
+func setCondition(negate bool) func(values ...interface{}) func(interface{}) bool {
+	return func(values ...interface{}) func(interface{}) bool {
+		if len(values) < 2 {
+			return nil
+		}
+		key, ok := values[0].(string)
+		if !ok {
+			return nil
+		}
+		members := make(map[string]struct{}, len(values)-1)
+		for _, v := range values[1:] {
+			m, ok := toString(v)
+			if !ok {
+				return nil
+			}
+			members[m] = struct{}{}
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			if !ok {
+				return false
+			}
+			m, ok := toString(v)
+			if !ok {
+				return false
+			}
+			_, found := members[m]
+			return found != negate
+		}
+	}
+}
+
+// saltedConditionTypes are the condition types whose values are prefixed
+// with the salt of their variant, by default its ID, when their evaluators are
+// constructed, so that they bucket users independently of other variants.
+var saltedConditionTypes = map[string]bool{
+	conditionTypePercent: true,
+	conditionTypeRamp:    true,
+}
+
+// Comparison operators of NUMERIC conditions.
+var numericComparisons = map[string]func(a, b float64) bool{
+	"GT":  func(a, b float64) bool { return a > b },
+	"GTE": func(a, b float64) bool { return a >= b },
+	"LT":  func(a, b float64) bool { return a < b },
+	"LTE": func(a, b float64) bool { return a <= b },
+	"EQ":  func(a, b float64) bool { return a == b },
+}
+
+func (r *Registry) registerBuiltInConditionTypes() {
+	// Register the RANDOM condition type.
+	r.registerSpec(conditionTypeRandom, &ConditionSchema{
+		Args: []ConditionArg{{Name: "probability", Type: ContextTypeNumber}},
+	}, func(values ...interface{}) (func(interface{}) bool, error) {
+		if len(values) != 1 {
+			return nil, fmt.Errorf("RANDOM takes one value, got %d.", len(values))
+		}
+		v, ok := values[0].(float64)
+		if !ok || v < 0 || v > 1 {
+			return nil, fmt.Errorf("RANDOM value must be a number in [0, 1], got %v.", values[0])
+		}
+		return func(context interface{}) bool {
+			if rc, ok := context.(RandContext); ok {
+				if rng := rc.Rand(); rng != nil {
+					return rng.Float64() <= v
+				}
+			}
+			return r.rng.float64() <= v
+		}, nil
+	})
+
+	// Register the MOD_RANGE condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeModRange, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeInt}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "begin", Type: ContextTypeInt}, {Name: "end", Type: ContextTypeInt}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 3 {
+			return nil
+		}
+
+		key, ok := values[0].(string)
+		if !ok {
+			return nil
+		}
+		rangeBegin, beginOK := toInt(values[1])
+		rangeEnd, endOK := toInt(values[2])
+		if !beginOK || !endOK || rangeBegin > rangeEnd {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			mod, ok := modBucket(context, key)
+			return ok && mod >= rangeBegin && mod <= rangeEnd
+		}
+	})
+
+	// Register the PERCENTAGE condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypePercent, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeAny}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "percent", Type: ContextTypeNumber}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 3 {
+			return nil
+		}
+		salt, saltOK := values[0].(string)
+		key, keyOK := values[1].(string)
+		percent, percentOK := toFloat(values[2])
+		if !saltOK || !keyOK || !percentOK || percent < 0 || percent > 100 {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			b, ok := percentBucket(context, salt, key)
+			return ok && float64(b) < percent*percentBuckets/100
+		}
+	})
+
+	// Register the ID_SET condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeIDSet, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeAny}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "path", Type: ContextTypeString}, {Name: "false_positive_rate", Type: ContextTypeNumber, Optional: true}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 2 && len(values) != 3 {
+			return nil
+		}
+		key, ok := values[0].(string)
+		if !ok {
+			return nil
+		}
+		path, ok := values[1].(string)
+		if !ok {
+			return nil
+		}
+		falsePositiveRate := 0.0
+		if len(values) == 3 {
+			if falsePositiveRate, ok = values[2].(float64); !ok || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+				return nil
+			}
+		}
+		set, err := r.loadIDSet(path, falsePositiveRate)
+		if err != nil {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			if !ok {
+				return false
+			}
+			return set.contains(fmt.Sprint(v))
+		}
+	})
+
+	// Register the IN_SET and NOT_IN_SET condition types, and WHITELIST as
+	// an alias of IN_SET for string IDs.
+	setArgs := []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "member", Type: ContextTypeAny, Repeated: true}}
+	r.RegisterConditionTypeWithSchema(conditionTypeInSet, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeAny}},
+		Args:    setArgs,
+	}, setCondition(false))
+	r.RegisterConditionTypeWithSchema(conditionTypeNotInSet, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeAny}},
+		Args:    setArgs,
+	}, setCondition(true))
+	r.RegisterConditionTypeWithSchema(conditionTypeWhitelist, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeString}},
+		Args:    []ConditionArg{setArgs[0], {Name: "id", Type: ContextTypeAny, Repeated: true}},
+	}, setCondition(false))
+
+	// Register the NUMERIC condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeNumeric, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeNumber}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "operator", Type: ContextTypeString}, {Name: "threshold", Type: ContextTypeNumber}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 3 {
+			return nil
+		}
+		key, ok := values[0].(string)
+		if !ok {
+			return nil
+		}
+		op, ok := values[1].(string)
+		if !ok {
+			return nil
+		}
+		compare, ok := numericComparisons[strings.ToUpper(op)]
+		if !ok {
+			return nil
+		}
+		threshold, ok := toFloat(values[2])
+		if !ok {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			if !ok {
+				return false
+			}
+			n, ok := toFloat(v)
+			return ok && compare(n, threshold)
+		}
+	})
+
+	// Register the LOCALE condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeLocale, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeString}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "tag", Type: ContextTypeString, Repeated: true}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) < 2 {
+			return nil
+		}
+		key, ok := values[0].(string)
+		if !ok {
+			return nil
+		}
+		rules := make([]languageTag, len(values)-1)
+		for i, v := range values[1:] {
+			s, ok := v.(string)
+			if !ok {
+				return nil
+			}
+			if rules[i], ok = parseLanguageTag(s); !ok {
+				return nil
+			}
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			if !ok {
+				return false
+			}
+			s, ok := v.(string)
+			if !ok {
+				return false
+			}
+			tag, ok := parseLanguageTag(s)
+			if !ok {
+				return false
+			}
+			for _, rule := range rules {
+				if tag.matches(rule) {
+					return true
+				}
+			}
+			return false
+		}
+	})
+	// Register the LAUNCH condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeLaunch, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeString}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "start", Type: ContextTypeString}, {Name: "id", Type: ContextTypeAny, Optional: true, Repeated: true}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		l, ok := parseLaunch(values)
+		if !ok {
+			return nil
+		}
+		return func(context interface{}) bool {
+			return l.reason(context, r.now()) != ""
+		}
+	})
+	// Register the TIME_RANGE condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeTimeRange, ConditionSchema{
+		Context: []ContextAttr{{Key: "now", Type: ContextTypeAny, Optional: true}},
+		Args:    []ConditionArg{{Name: "start", Type: ContextTypeAny}, {Name: "end", Type: ContextTypeAny}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 2 {
+			return nil
+		}
+		start, startOK := parseBound(values[0])
+		end, endOK := parseBound(values[1])
+		if !startOK || !endOK || (start.IsZero() && end.IsZero()) {
+			return nil
+		}
+		if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			now, ok := contextTime(context, "now")
+			if !ok {
+				now = r.now()
+			}
+			return (start.IsZero() || !now.Before(start)) && (end.IsZero() || now.Before(end))
+		}
+	})
+	// Register the COHORT condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeCohort, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeAny}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "direction", Type: ContextTypeString}, {Name: "cutoff", Type: ContextTypeString}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 3 {
+			return nil
+		}
+		key, keyOK := values[0].(string)
+		op, opOK := values[1].(string)
+		cutoff, cutoffOK := values[2].(string)
+		if !keyOK || !opOK || !cutoffOK || (op != "AFTER" && op != "BEFORE") {
+			return nil
+		}
+		at, err := time.Parse(time.RFC3339, cutoff)
+		relative, relErr := time.ParseDuration(cutoff)
+		if err != nil && relErr != nil {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			t, ok := contextTime(context, key)
+			if !ok {
+				return false
+			}
+			at := at
+			if err != nil {
+				at = r.now().Add(relative)
+			}
+			if op == "AFTER" {
+				return t.After(at)
+			}
+			return t.Before(at)
+		}
+	})
+	// Register the STRING_MATCH condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeMatch, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeString}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "mode", Type: ContextTypeString}, {Name: "pattern", Type: ContextTypeString}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 3 {
+			return nil
+		}
+		key, keyOK := values[0].(string)
+		mode, modeOK := values[1].(string)
+		pattern, patternOK := values[2].(string)
+		if !keyOK || !modeOK || !patternOK {
+			return nil
+		}
+		newMatch, ok := stringMatchModes[mode]
+		if !ok {
+			return nil
+		}
+		match := newMatch(pattern)
+		if match == nil {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			if !ok {
+				return false
+			}
+			s, ok := v.(string)
+			return ok && match(s)
+		}
+	})
+	// Register the GEO condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeGeo, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeString}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "region", Type: ContextTypeString, Repeated: true}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) < 2 {
+			return nil
+		}
+		key, ok := values[0].(string)
+		if !ok {
+			return nil
+		}
+		regions := make([]string, len(values)-1)
+		for i, v := range values[1:] {
+			region, ok := v.(string)
+			if !ok || len(region) == 0 {
+				return nil
+			}
+			regions[i] = strings.ToLower(region)
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			if !ok {
+				return false
+			}
+			s, ok := v.(string)
+			if !ok {
+				return false
+			}
+			s = strings.ToLower(s)
+			for _, region := range regions {
+				if s == region || strings.HasPrefix(s, region+"-") {
+					return true
+				}
+			}
+			return false
+		}
+	})
+
+	// Register the FLAG_EQUALS condition type. It declares no schema,
+	// since it reads whatever the compared flag's conditions read. Loaded
+	// conditions resolve the compared flag within the evaluation that
+	// reads them, as evaluateCondition does; the evaluator serves callers
+	// of Condition.Evaluate, resolving it as a dry run without hooks.
+	r.RegisterContextualConditionType(conditionTypeFlagEq, func(r *Registry, values ...interface{}) func(interface{}) bool {
+		if len(values) != 2 {
+			return nil
+		}
+		name, ok := values[0].(string)
+		if !ok || len(name) == 0 {
+			return nil
+		}
+		expected := values[1]
+
+		return func(context interface{}) bool {
+			val, _ := r.resolve(name, context, nil)
+			return flagValuesEqual(val, expected)
+		}
+	})
+
+	// Register the VERSION condition type.
+	r.RegisterConditionTypeWithSchema(conditionTypeVersion, ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 0, Type: ContextTypeString}},
+		Args:    []ConditionArg{{Name: "key", Type: ContextTypeString}, {Name: "constraint", Type: ContextTypeString}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		if len(values) != 2 {
+			return nil
+		}
+		key, ok := values[0].(string)
+		if !ok {
+			return nil
+		}
+		constraint, ok := values[1].(string)
+		if !ok {
+			return nil
+		}
+		rng, ok := parseVersionRange(constraint)
+		if !ok {
+			return nil
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			if !ok {
+				return false
+			}
+			s, ok := v.(string)
+			if !ok {
+				return false
+			}
+			ver, ok := parseVersion(s)
+			return ok && rng.contains(ver)
+		}
+	})
+
+	// Register the RAMP condition type.
+	r.registerSpec(conditionTypeRamp, &ConditionSchema{
+		Context: []ContextAttr{{KeyArg: 2, Type: ContextTypeAny}},
+		Args:    []ConditionArg{{Name: "start", Type: ContextTypeString}, {Name: "end", Type: ContextTypeString}, {Name: "key", Type: ContextTypeString}},
+	}, func(values ...interface{}) (func(interface{}) bool, error) {
+		if len(values) != 4 {
+			return nil, fmt.Errorf("RAMP takes a start time, an end time, and a key, got %d values.", len(values)-1)
+		}
+		salt, _ := values[0].(string)
+		var bounds [2]time.Time
+		for i := range bounds {
+			s, ok := values[i+1].(string)
+			if !ok {
+				return nil, fmt.Errorf("RAMP time %v is not an RFC 3339 string.", values[i+1])
+			}
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("RAMP time %q is not an RFC 3339 time.", s)
+			}
+			bounds[i] = t
+		}
+		start, end := bounds[0], bounds[1]
+		if !start.Before(end) {
+			return nil, fmt.Errorf("RAMP start %s is not before its end %s.", start.Format(time.RFC3339), end.Format(time.RFC3339))
+		}
+		key, ok := values[3].(string)
+		if !ok {
+			return nil, fmt.Errorf("RAMP key %v is not a string.", values[3])
+		}
+
+		return func(context interface{}) bool {
+			b, ok := percentBucket(context, salt, key)
+			return ok && float64(b) < rampFraction(start, end, r.now())*percentBuckets
+		}, nil
+	})
+
+	// Register the HAS_KEY condition type. It declares no schema, since
+	// its key is not required of contexts.
+	r.RegisterConditionTypeErr(conditionTypeHasKey, func(values ...interface{}) (func(interface{}) bool, error) {
+		if len(values) != 1 {
+			return nil, fmt.Errorf("HAS_KEY takes one key, got %d values.", len(values))
+		}
+		key, ok := values[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("HAS_KEY key %v is not a string.", values[0])
+		}
+
+		return func(context interface{}) bool {
+			v, ok := contextValue(context, key)
+			return ok && v != nil
+		}, nil
+	})
+
+	// Register the CIDR condition type.
+	r.registerSpec(conditionTypeCIDR, &ConditionSchema{
+		Context: []ContextAttr{{Key: "ip", Type: ContextTypeAny}},
+		Args:    []ConditionArg{{Name: "range", Type: ContextTypeString, Repeated: true}},
+	}, func(values ...interface{}) (func(interface{}) bool, error) {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("CIDR takes at least one range, got none.")
+		}
+		nets := make([]*net.IPNet, len(values))
+		for i, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("CIDR range %v is not a string.", v)
+			}
+			_, ipNet, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf("CIDR range %q is not in CIDR notation.", s)
+			}
+			nets[i] = ipNet
+		}
+
+		return func(context interface{}) bool {
+			v, _ := contextValue(context, "ip")
+			var ip net.IP
+			switch v := v.(type) {
+			case net.IP:
+				ip = v
+			case string:
+				ip = net.ParseIP(v)
+			}
+			if ip == nil {
+				return false
+			}
+			for _, ipNet := range nets {
+				if ipNet.Contains(ip) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	})
+
+	// Register the SCHEDULE condition type.
+	r.registerSpec(conditionTypeSchedule, &ConditionSchema{
+		Context: []ContextAttr{{Key: "now", Type: ContextTypeAny, Optional: true}},
+		Args: []ConditionArg{
+			{Name: "time_zone", Type: ContextTypeString},
+			{Name: "days", Type: ContextTypeAny},
+			{Name: "start", Type: ContextTypeString},
+			{Name: "end", Type: ContextTypeString},
+		},
+	}, func(values ...interface{}) (func(interface{}) bool, error) {
+		s, err := parseSchedule(values)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(context interface{}) bool {
+			now, ok := contextTime(context, "now")
+			if !ok {
+				now = r.now()
+			}
+			return s.active(now)
+		}, nil
+	})
+
+	r.Lock()
+	defer r.Unlock()
+	// HAS_KEY and FLAG_EQUALS declare no schema, so their values are
+	// described apart from one.
+	r.conditionArgs[conditionTypeHasKey] = []ConditionArg{{Name: "key", Type: ContextTypeString}}
+	r.conditionArgs[conditionTypeFlagEq] = []ConditionArg{{Name: "flag", Type: ContextTypeString}, {Name: "value", Type: ContextTypeAny}}
+	for id, fn := range r.conditionSpecs {
+		if !nilContextConditionTypes[id] {
+			r.conditionSpecs[id] = guardNilContext(fn)
+		}
+	}
+}
+
+// nilContextConditionTypes are the built-in condition types whose
+// evaluators may be met without a context: they read the clock, the
+// random source or other flags rather than the context.
+var nilContextConditionTypes = map[string]bool{
+	conditionTypeRandom:    true,
+	conditionTypeLaunch:    true,
+	conditionTypeTimeRange: true,
+	conditionTypeFlagEq:    true,
+	conditionTypeSchedule:  true,
+}
+
+// guardNilContext returns the spec of a built-in condition type reading
+// the context whose evaluators are not met when passed a nil context,
+// without being called.
+func guardNilContext(fn specFunc) specFunc {
+	return func(values ...interface{}) (func(interface{}) bool, error) {
+		evaluator, err := fn(values...)
+		if evaluator == nil || err != nil {
+			return evaluator, err
+		}
+		return func(context interface{}) bool {
+			if context == nil {
+				return false
+			}
+			return evaluator(context)
+		}, nil
+	}
+}
+
+// rampFraction returns the fraction of a rollout ramping up linearly from
+// none at start to all at end that is reached at now.
+func rampFraction(start, end, now time.Time) float64 {
+	switch {
+	case now.Before(start):
+		return 0
+	case !now.Before(end):
+		return 1
+	}
+	return float64(now.Sub(start)) / float64(end.Sub(start))
+}
+
+// flagValuesEqual reports whether the flag values a and b are equal.
+// Numbers are compared by value whatever their types.
+func flagValuesEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
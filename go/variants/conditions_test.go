@@ -0,0 +1,1270 @@
+package variants
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWhitelist(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "BetaTesters",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "andybons", "pupius", 42]}],
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{map[string]string{"id": "andybons"}, true},
+		{map[string]string{"id": "tessr"}, false},
+		{map[string]string{"user_id": "andybons"}, false},
+		{map[string]interface{}{"id": 42.0}, true},
+		{map[string]int{"id": 42}, true},
+		{"andybons", false},
+		{nil, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("beta", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected context %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+
+	// A single ID is read from the "id" key.
+	config = `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "BetaTester",
+	    "conditions": [{"type": "WHITELIST", "value": "andybons"}],
+	    "mods": [{"flag": "beta", "value": true}]
+	  }, {
+	    "id": "OtherBetaTester",
+	    "conditions": [{"type": "WHITELIST", "values": ["pupius"]}],
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	r = NewRegistry()
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases = []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{map[string]string{"id": "andybons"}, true},
+		{map[string]string{"id": "pupius"}, true},
+		{map[string]string{"id": "tessr"}, false},
+		{map[string]string{"andybons": "andybons"}, false},
+		{nil, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("beta", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected context %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+	if err := r.ValidateContext("beta", map[string]string{"id": "tessr"}); err != nil {
+		t.Errorf("ValidateContext: expected the default key to be validated, but got %q.", err.Error())
+	}
+}
+
+func TestNumeric(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "adult", "base_value": false}],
+	  "variants": [{
+	    "id": "Adult",
+	    "conditions": [{"type": "NUMERIC", "values": ["age", %q, 18]}],
+	    "mods": [{"flag": "adult", "value": true}]
+	  }]
+	}`
+	testCases := map[string]map[float64]bool{
+		"GT":  {17: false, 18: false, 19: true},
+		"GTE": {17: false, 18: true, 19: true},
+		"LT":  {17: true, 18: false, 19: false},
+		"LTE": {17: true, 18: true, 19: false},
+		"EQ":  {17: false, 18: true, 19: false},
+	}
+	for op, ages := range testCases {
+		r := NewRegistry()
+		if err := r.LoadJSON([]byte(fmt.Sprintf(config, op))); err != nil {
+			t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+		}
+		for age, expected := range ages {
+			if v := r.FlagValueWithContext("adult", map[string]float64{"age": age}); v != expected {
+				t.Errorf("FlagValueWithContext: expected age %v %s 18 to return %t, got %v.", age, op, expected, v)
+			}
+			if v := r.FlagValueWithContext("adult", map[string]int{"age": int(age)}); v != expected {
+				t.Errorf("FlagValueWithContext: expected int age %v %s 18 to return %t, got %v.", age, op, expected, v)
+			}
+		}
+		if v := r.FlagValueWithContext("adult", map[string]string{"age": "19"}); v != false {
+			t.Errorf("FlagValueWithContext: expected a string age to return false, got %v.", v)
+		}
+		if v := r.FlagValueWithContext("adult", nil); v != false {
+			t.Errorf("FlagValueWithContext: expected a nil context to return false, got %v.", v)
+		}
+	}
+
+	if err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(config, "NEQ"))); err == nil {
+		t.Error("LoadJSON: expected invalid operator error, but got nil.")
+	}
+}
+
+func TestLocale(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "new_copy", "base_value": false}],
+	  "variants": [{
+	    "id": "NewCopy",
+	    "conditions": [{"type": "LOCALE", "values": ["locale", "en", "pt-BR", "zh-Hant"]}],
+	    "mods": [{"flag": "new_copy", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := map[string]bool{
+		"en":         true,
+		"en-GB":      true,
+		"EN_us":      true,
+		"pt-BR":      true,
+		"pt-PT":      false,
+		"pt":         false,
+		"zh-Hant-TW": true,
+		"zh-Hans-CN": false,
+		"fr-CA":      false,
+		"":           false,
+		"e":          false,
+	}
+	for locale, expected := range testCases {
+		if v := r.FlagValueWithContext("new_copy", map[string]string{"locale": locale}); v != expected {
+			t.Errorf("FlagValueWithContext: expected locale %q to return %t, got %v.", locale, expected, v)
+		}
+	}
+	if v := r.FlagValueWithContext("new_copy", map[string]string{}); v != false {
+		t.Errorf("FlagValueWithContext: expected a missing locale to return false, got %v.", v)
+	}
+}
+
+func TestMalformedConditionValues(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "f", "base_value": false}],
+	  "variants": [{
+	    "id": "Malformed",
+	    "conditions": [{"type": %q, "values": %s}],
+	    "mods": [{"flag": "f", "value": true}]
+	  }]
+	}`
+	testCases := map[string]string{
+		"MOD_RANGE": `[123, "foo", true]`,
+		"RANDOM":    `[1.5]`,
+		"WHITELIST": `[{}]`,
+		"NUMERIC":   `["age", "GT", "eighteen"]`,
+		"LOCALE":    `["locale", "not a tag"]`,
+		"ID_SET":    `["id", "testdata/missing.txt"]`,
+		"PANICS":    `[1]`,
+	}
+	for conditionType, values := range testCases {
+		r := NewRegistry()
+		r.RegisterConditionType("PANICS", func(values ...interface{}) func(interface{}) bool {
+			s := values[0].(string)
+			return func(interface{}) bool { return len(s) > 0 }
+		})
+		if err := r.LoadJSON([]byte(fmt.Sprintf(config, conditionType, values))); err == nil {
+			t.Errorf("LoadJSON: expected an error for %s values %s, but got nil.", conditionType, values)
+		}
+	}
+}
+
+func TestRegisterConditionTypeErr(t *testing.T) {
+	r := NewRegistry()
+	errShort := errors.New("Password is too short.")
+	r.RegisterConditionTypeErr("PASSWORD", func(values ...interface{}) (func(interface{}) bool, error) {
+		password, ok := values[0].(string)
+		if !ok || len(password) < 6 {
+			return nil, errShort
+		}
+		return func(context interface{}) bool { return context == password }, nil
+	})
+	config := `{
+	  "flag_defs": [{"flag": "f", "base_value": false}],
+	  "variants": [{
+	    "id": "Secret",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}, {"type": "PASSWORD", "value": %q}],
+	    "condition_operator": "AND",
+	    "mods": [{"flag": "f", "value": true}]
+	  }]
+	}`
+	err := r.LoadJSON([]byte(fmt.Sprintf(config, "abc")))
+	invalid, ok := err.(*InvalidConditionError)
+	if !ok {
+		t.Fatalf("LoadJSON: expected an *InvalidConditionError, got %v.", err)
+	}
+	if invalid.VariantID != "Secret" || invalid.Index != 1 || invalid.Type != "PASSWORD" || invalid.Err != errShort {
+		t.Errorf("LoadJSON: expected the error of condition 1 of variant Secret, got %+v.", invalid)
+	}
+	if expected := `Condition 1 (PASSWORD) of variant "Secret" has invalid values [abc]: Password is too short.`; err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q, got %q.", expected, err.Error())
+	}
+
+	if err := r.LoadJSON([]byte(fmt.Sprintf(config, "abcdef"))); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValueWithContext("f", "abcdef"); v != true {
+		t.Errorf("FlagValueWithContext: expected true for the password, got %v.", v)
+	}
+
+	err = NewRegistry().LoadJSON([]byte(`{
+	  "flag_defs": [{"flag": "f", "base_value": false}],
+	  "variants": [{"id": "Random", "conditions": [{"type": "RANDOM", "value": 1.5}], "mods": [{"flag": "f", "value": true}]}]
+	}`))
+	if expected := `Condition 0 (RANDOM) of variant "Random" has invalid values [1.5]: RANDOM value must be a number in [0, 1], got 1.5.`; err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+	}
+}
+
+func TestLaunch(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "new_editor", "base_value": false}],
+	  "variants": [{
+	    "id": "NewEditor",
+	    "conditions": [{"type": "LAUNCH", "values": ["id", "2026-03-02T00:00:00Z", "andybons", 42]}],
+	    "mods": [{"flag": "new_editor", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	events := r.EventStream(10)
+	testCases := []struct {
+		Now      string
+		Context  interface{}
+		Expected bool
+		Reason   string
+	}{
+		{"2026-03-01T12:00:00Z", map[string]string{"id": "andybons"}, true, ReasonAllowlist},
+		{"2026-03-01T12:00:00Z", map[string]int{"id": 42}, true, ReasonAllowlist},
+		{"2026-03-01T12:00:00Z", map[string]string{"id": "tessr"}, false, ""},
+		{"2026-03-01T12:00:00Z", nil, false, ""},
+		{"2026-03-02T00:00:00Z", map[string]string{"id": "tessr"}, true, ReasonSchedule},
+		{"2026-03-09T00:00:00Z", nil, true, ReasonSchedule},
+		{"2026-03-09T00:00:00Z", map[string]string{"id": "andybons"}, true, ReasonAllowlist},
+	}
+	for _, tc := range testCases {
+		now, _ := time.Parse(time.RFC3339, tc.Now)
+		r.SetClock(func() time.Time { return now })
+		if v := r.FlagValueWithContext("new_editor", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected context %v at %s to return %t, got %v.", tc.Context, tc.Now, tc.Expected, v)
+		}
+		if e := <-events; e.Reason != tc.Reason {
+			t.Errorf("EvalEvent: expected context %v at %s to have reason %q, got %q.", tc.Context, tc.Now, tc.Reason, e.Reason)
+		}
+	}
+	if c := r.load().variants["NewEditor"].Conditions[0]; c.reasoner == nil {
+		t.Error("LoadJSON: expected the LAUNCH condition to be parsed when loaded.")
+	}
+
+	if err := NewRegistry().LoadJSON([]byte(`{
+	  "flag_defs": [{"flag": "new_editor", "base_value": false}],
+	  "variants": [{
+	    "id": "NewEditor",
+	    "conditions": [{"type": "LAUNCH", "values": ["id", "next monday"]}],
+	    "mods": [{"flag": "new_editor", "value": true}]
+	  }]
+	}`)); err == nil {
+		t.Error("LoadJSON: expected an error for an invalid launch time.")
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "rollout", "base_value": false}, {"flag": "other", "base_value": false}],
+	  "variants": [{
+	    "id": "Rollout",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", 25]}],
+	    "mods": [{"flag": "rollout", "value": true}]
+	  }, {
+	    "id": "Other",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", 25]}],
+	    "mods": [{"flag": "other", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+
+	active, differ := 0, 0
+	for id := 0; id < 10000; id++ {
+		ctx := map[string]int{"user_id": id}
+		v := r.FlagValueWithContext("rollout", ctx)
+		for i := 0; i < 3; i++ {
+			if again := r.FlagValueWithContext("rollout", ctx); again != v {
+				t.Fatalf("FlagValueWithContext: expected user %d to be bucketed consistently.", id)
+			}
+		}
+		if r.FlagValueWithContext("rollout", map[string]interface{}{"user_id": float64(id)}) != v {
+			t.Errorf("FlagValueWithContext: expected user %d to be bucketed the same from JSON.", id)
+		}
+		if v == true {
+			active++
+		}
+		if r.FlagValueWithContext("other", ctx) != v {
+			differ++
+		}
+	}
+	if active < 2300 || active > 2700 {
+		t.Errorf("PERCENTAGE: expected about 2500 of 10000 users to be active, got %d.", active)
+	}
+	if differ < 3000 {
+		t.Errorf("PERCENTAGE: expected variants to bucket independently, got %d differing users.", differ)
+	}
+
+	expected := []Bucket{{
+		VariantID:     "Rollout",
+		ConditionType: "PERCENTAGE",
+		Key:           "user_id",
+		Salt:          "Rollout",
+		Value:         2956,
+		Buckets:       10000,
+		Active:        false,
+	}}
+	if b := r.BucketFor("user_id", map[string]string{"user_id": "42"}); len(b) != 2 || b[1] != expected[0] {
+		t.Errorf("BucketFor: expected %+v, got %+v.", expected, b)
+	}
+}
+
+func TestRamp(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "rollout", "base_value": false}],
+	  "variants": [{
+	    "id": "Rollout",
+	    "conditions": [{"type": "RAMP", "values": ["2026-03-01T00:00:00Z", "2026-03-08T00:00:00Z", "user_id"]}],
+	    "mods": [{"flag": "rollout", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	testCases := []struct {
+		Now      time.Time
+		Min, Max int
+	}{
+		{start.Add(-time.Hour), 0, 0},
+		{start, 0, 0},
+		{start.Add(7 * day / 4), 2300, 2700},
+		{start.Add(7 * day / 2), 4800, 5200},
+		{start.Add(7 * day), 10000, 10000},
+		{start.Add(30 * day), 10000, 10000},
+	}
+	previous := map[int]bool{}
+	for _, tc := range testCases {
+		r.SetClock(func() time.Time { return tc.Now })
+		active := map[int]bool{}
+		for id := 0; id < 10000; id++ {
+			if r.FlagValueWithContext("rollout", map[string]int{"user_id": id}) == true {
+				active[id] = true
+			}
+		}
+		if len(active) < tc.Min || len(active) > tc.Max {
+			t.Errorf("RAMP: expected between %d and %d of 10000 users to be active at %s, got %d.", tc.Min, tc.Max, tc.Now, len(active))
+		}
+		for id := range previous {
+			if !active[id] {
+				t.Errorf("RAMP: expected user %d to stay active as the ramp progresses to %s.", id, tc.Now)
+				break
+			}
+		}
+		previous = active
+	}
+
+	malformed := map[string]string{
+		`["2026-03-01T00:00:00Z", "user_id"]`:                         "RAMP takes a start time, an end time, and a key, got 2 values.",
+		`["yesterday", "2026-03-08T00:00:00Z", "user_id"]`:            `RAMP time "yesterday" is not an RFC 3339 time.`,
+		`["2026-03-08T00:00:00Z", "2026-03-01T00:00:00Z", "user_id"]`: "RAMP start 2026-03-08T00:00:00Z is not before its end 2026-03-01T00:00:00Z.",
+		`["2026-03-01T00:00:00Z", "2026-03-08T00:00:00Z", 42]`:        "RAMP key 42 is not a string.",
+	}
+	for values, expected := range malformed {
+		err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(`{
+		  "flag_defs": [{"flag": "rollout", "base_value": false}],
+		  "variants": [{"id": "Rollout", "conditions": [{"type": "RAMP", "values": %s}], "mods": [{"flag": "rollout", "value": true}]}]
+		}`, values)))
+		if invalid, ok := err.(*InvalidConditionError); !ok || invalid.Err.Error() != expected {
+			t.Errorf("LoadJSON: expected error %q for RAMP values %s, got %v.", expected, values, err)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "support_chat", "base_value": false}, {"flag": "night_batch", "base_value": false}, {"flag": "sunday_chat", "base_value": false}],
+	  "variants": [{
+	    "id": "BusinessHours",
+	    "conditions": [{"type": "SCHEDULE", "values": ["America/New_York", ["MON", "TUE", "WED", "THU", "FRI"], "09:00", "17:00"]}],
+	    "mods": [{"flag": "support_chat", "value": true}]
+	  }, {
+	    "id": "NightBatch",
+	    "conditions": [{"type": "SCHEDULE", "values": ["UTC", ["fri"], "22:00", "06:00"]}],
+	    "mods": [{"flag": "night_batch", "value": true}]
+	  }, {
+	    "id": "SundayHours",
+	    "conditions": [{"type": "SCHEDULE", "values": ["America/New_York", ["SUN"], "09:00", "17:00"]}],
+	    "mods": [{"flag": "sunday_chat", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation: expected no error, but got %q.", err.Error())
+	}
+
+	// 2026-03-06 is a Friday.
+	testCases := []struct {
+		Flag     string
+		Now      time.Time
+		Expected bool
+	}{
+		{"support_chat", time.Date(2026, 3, 6, 9, 0, 0, 0, newYork), true},
+		{"support_chat", time.Date(2026, 3, 6, 16, 59, 0, 0, newYork), true},
+		{"support_chat", time.Date(2026, 3, 6, 17, 0, 0, 0, newYork), false},
+		{"support_chat", time.Date(2026, 3, 6, 8, 59, 0, 0, newYork), false},
+		{"support_chat", time.Date(2026, 3, 7, 12, 0, 0, 0, newYork), false},
+		// 14:00 UTC is 09:00 in New York on March 6, before daylight saving time.
+		{"support_chat", time.Date(2026, 3, 6, 14, 0, 0, 0, time.UTC), true},
+		{"support_chat", time.Date(2026, 3, 6, 13, 59, 0, 0, time.UTC), false},
+		{"night_batch", time.Date(2026, 3, 6, 23, 0, 0, 0, time.UTC), true},
+		{"night_batch", time.Date(2026, 3, 7, 5, 59, 0, 0, time.UTC), true},
+		{"night_batch", time.Date(2026, 3, 7, 6, 0, 0, 0, time.UTC), false},
+		{"night_batch", time.Date(2026, 3, 6, 5, 0, 0, 0, time.UTC), false},
+		{"night_batch", time.Date(2026, 3, 7, 23, 0, 0, 0, time.UTC), false},
+		// Daylight saving time begins on 2026-03-08 and ends on 2026-11-01.
+		{"sunday_chat", time.Date(2026, 3, 8, 9, 30, 0, 0, newYork), true},
+		{"sunday_chat", time.Date(2026, 3, 8, 8, 59, 0, 0, newYork), false},
+		{"sunday_chat", time.Date(2026, 3, 8, 17, 0, 0, 0, newYork), false},
+		{"sunday_chat", time.Date(2026, 11, 1, 16, 30, 0, 0, newYork), true},
+		{"sunday_chat", time.Date(2026, 11, 1, 17, 0, 0, 0, newYork), false},
+	}
+	for _, tc := range testCases {
+		r.SetClock(func() time.Time { return tc.Now })
+		if v := r.FlagValue(tc.Flag); v != tc.Expected {
+			t.Errorf("FlagValue: expected %s to be %t at %s, got %v.", tc.Flag, tc.Expected, tc.Now, v)
+		}
+	}
+	r.SetClock(func() time.Time { return time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC) })
+	ctx := map[string]interface{}{"now": "2026-03-06T12:00:00-05:00"}
+	if v := r.FlagValueWithContext("support_chat", ctx); v != true {
+		t.Errorf("FlagValueWithContext: expected context time to take precedence over the clock, got %v.", v)
+	}
+	if !r.RequiresContext("support_chat") {
+		t.Error("RequiresContext: expected support_chat to require a context.")
+	}
+
+	malformed := map[string]string{
+		`["UTC", ["MON"], "09:00"]`:                   "SCHEDULE takes a time zone, days, a start time, and an end time, got 3 values.",
+		`["Mars/Olympus", ["MON"], "09:00", "17:00"]`: `SCHEDULE time zone "Mars/Olympus" is unknown.`,
+		`["UTC", "MON", "09:00", "17:00"]`:            "SCHEDULE days MON are not a list of days.",
+		`["UTC", ["MONDAY"], "09:00", "17:00"]`:       `SCHEDULE day "MONDAY" is not one of SUN, MON, TUE, WED, THU, FRI, or SAT.`,
+		`["UTC", ["MON"], "9am", "17:00"]`:            `SCHEDULE time "9am" is not a time of day such as 09:30.`,
+		`["UTC", ["MON"], "09:00", "09:00"]`:          "SCHEDULE start and end are both 09:00.",
+	}
+	for values, expected := range malformed {
+		err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(`{
+		  "flag_defs": [{"flag": "support_chat", "base_value": false}],
+		  "variants": [{"id": "BusinessHours", "conditions": [{"type": "SCHEDULE", "values": %s}], "mods": [{"flag": "support_chat", "value": true}]}]
+		}`, values)))
+		if invalid, ok := err.(*InvalidConditionError); !ok || invalid.Err.Error() != expected {
+			t.Errorf("LoadJSON: expected error %q for SCHEDULE values %s, got %v.", expected, values, err)
+		}
+	}
+}
+
+func TestHasKey(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "BetaToken",
+	    "conditions": [{"type": "HAS_KEY", "value": "beta_token"}],
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{map[string]interface{}{"beta_token": "abc"}, true},
+		{map[string]interface{}{"beta_token": false}, true},
+		{map[string]interface{}{"beta_token": nil}, false},
+		{map[string]interface{}{"other": "abc"}, false},
+		{map[string]string{"beta_token": ""}, true},
+		{map[string]string{}, false},
+		{map[string]int{"beta_token": 0}, true},
+		{map[string]int{"other": 1}, false},
+		{nil, false},
+		{"beta_token", false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("beta", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+	if !r.RequiresContext("beta") {
+		t.Error("RequiresContext: expected a flag with a HAS_KEY condition to require context.")
+	}
+	if err := r.ValidateContext("beta", map[string]interface{}{}); err != nil {
+		t.Errorf("ValidateContext: expected a context without the key to be valid, got %q.", err.Error())
+	}
+
+	malformed := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{"id": "BetaToken", "conditions": [{"type": "HAS_KEY", "values": ["a", "b"]}], "mods": [{"flag": "beta", "value": true}]}]
+	}`
+	if err := NewRegistry().LoadJSON([]byte(malformed)); err == nil {
+		t.Error("LoadJSON: expected an error for a HAS_KEY condition with two keys, but got nil.")
+	}
+}
+
+func TestPercentageSalt(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "a", "base_value": false}, {"flag": "b", "base_value": false}, {"flag": "c", "base_value": false}],
+	  "variants": [{
+	    "id": "A",
+	    "salt": "checkout-2024",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", 50]}],
+	    "mods": [{"flag": "a", "value": true}]
+	  }, {
+	    "id": "B",
+	    "salt": "checkout-2024",
+	    "condition_groups": [{"conditions": [{"type": "PERCENTAGE", "values": ["user_id", 50]}]}],
+	    "mods": [{"flag": "b", "value": true}]
+	  }, {
+	    "id": "checkout-2024",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", 50]}],
+	    "mods": [{"flag": "c", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	for id := 0; id < 1000; id++ {
+		ctx := map[string]int{"user_id": id}
+		a := r.FlagValueWithContext("a", ctx)
+		if b := r.FlagValueWithContext("b", ctx); b != a {
+			t.Fatalf("FlagValueWithContext: expected variants with the same salt to bucket user %d alike.", id)
+		}
+		if c := r.FlagValueWithContext("c", ctx); c != a {
+			t.Fatalf("FlagValueWithContext: expected the salt to replace the variant ID for user %d.", id)
+		}
+	}
+	for _, b := range r.BucketFor("user_id", map[string]int{"user_id": 42}) {
+		if b.Salt != "checkout-2024" {
+			t.Errorf("BucketFor: expected salt %q for %s, got %q.", "checkout-2024", b.VariantID, b.Salt)
+		}
+	}
+}
+
+func TestTimeRange(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "promo", "base_value": false}, {"flag": "archive", "base_value": false}],
+	  "variants": [{
+	    "id": "HolidayPromo",
+	    "conditions": [{"type": "TIME_RANGE", "values": ["2026-12-01T00:00:00Z", "2026-12-26T00:00:00Z"]}],
+	    "mods": [{"flag": "promo", "value": true}]
+	  }, {
+	    "id": "Archive",
+	    "conditions": [{"type": "TIME_RANGE", "values": ["2027-01-01T00:00:00-08:00", null]}],
+	    "mods": [{"flag": "archive", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Now     string
+		Context interface{}
+		Promo   bool
+		Archive bool
+	}{
+		{"2026-11-30T23:59:59Z", nil, false, false},
+		{"2026-12-01T00:00:00Z", nil, true, false},
+		{"2026-12-25T23:59:59Z", nil, true, false},
+		{"2026-12-26T00:00:00Z", nil, false, false},
+		{"2027-01-01T08:00:00Z", nil, false, true},
+		{"2030-01-01T00:00:00Z", nil, false, true},
+		{"2026-01-01T00:00:00Z", map[string]interface{}{"now": "2026-12-24T12:00:00Z"}, true, false},
+		{"2026-01-01T00:00:00Z", map[string]interface{}{"now": time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC)}, false, true},
+	}
+	for _, tc := range testCases {
+		now, _ := time.Parse(time.RFC3339, tc.Now)
+		r.SetClock(func() time.Time { return now })
+		if v := r.FlagValueWithContext("promo", tc.Context); v != tc.Promo {
+			t.Errorf("FlagValueWithContext: expected promo at %s with %v to be %t, got %v.", tc.Now, tc.Context, tc.Promo, v)
+		}
+		if v := r.FlagValueWithContext("archive", tc.Context); v != tc.Archive {
+			t.Errorf("FlagValueWithContext: expected archive at %s with %v to be %t, got %v.", tc.Now, tc.Context, tc.Archive, v)
+		}
+	}
+
+	// Conditions reading "now" from the context depend on it.
+	if !r.RequiresContext("promo") {
+		t.Error("RequiresContext: expected promo to require a context.")
+	}
+	if err := r.ValidateContext("promo", nil); err != nil {
+		t.Errorf("ValidateContext: expected no error without now, but got %q.", err.Error())
+	}
+	_, during := r.FlagValueWithETag("promo", map[string]interface{}{"now": "2026-12-24T12:00:00Z"})
+	_, after := r.FlagValueWithETag("promo", map[string]interface{}{"now": "2031-01-01T00:00:00Z"})
+	if during == after {
+		t.Errorf("FlagValueWithETag: expected different ETags for different times, got %s for both.", during)
+	}
+
+	for _, values := range []string{
+		`["2026-12-01", "2026-12-26"]`,
+		`[null, null]`,
+		`["2026-12-26T00:00:00Z", "2026-12-01T00:00:00Z"]`,
+		`["2026-12-01T00:00:00Z"]`,
+	} {
+		config := `{
+		  "flag_defs": [{"flag": "promo", "base_value": false}],
+		  "variants": [{
+		    "id": "HolidayPromo",
+		    "conditions": [{"type": "TIME_RANGE", "values": ` + values + `}],
+		    "mods": [{"flag": "promo", "value": true}]
+		  }]
+		}`
+		if err := NewRegistry().LoadJSON([]byte(config)); err == nil {
+			t.Errorf("LoadJSON: expected an error for TIME_RANGE values %s.", values)
+		}
+	}
+}
+
+func TestCohort(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "new_users", "base_value": false}, {"flag": "recent", "base_value": false}],
+	  "variants": [{
+	    "id": "NewUsers",
+	    "conditions": [{"type": "COHORT", "values": ["signed_up", "AFTER", "2026-03-01T00:00:00Z"]}],
+	    "mods": [{"flag": "new_users", "value": true}]
+	  }, {
+	    "id": "Recent",
+	    "conditions": [{"type": "COHORT", "values": ["signed_up", "AFTER", "-720h"]}],
+	    "mods": [{"flag": "recent", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	now := time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)
+	r.SetClock(func() time.Time { return now })
+	testCases := []struct {
+		Context  interface{}
+		NewUsers bool
+		Recent   bool
+	}{
+		{map[string]interface{}{"signed_up": "2026-03-02T00:00:00Z"}, true, true},
+		{map[string]interface{}{"signed_up": "2026-02-10T00:00:00Z"}, false, false},
+		{map[string]interface{}{"signed_up": "2026-02-25T00:00:00Z"}, false, true},
+		{map[string]interface{}{"signed_up": time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)}, true, true},
+		{map[string]int{"signed_up": int(time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC).Unix())}, true, true},
+		{map[string]interface{}{"signed_up": "yesterday"}, false, false},
+		{map[string]interface{}{}, false, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("new_users", tc.Context); v != tc.NewUsers {
+			t.Errorf("FlagValueWithContext: expected new_users for %v to be %t, got %v.", tc.Context, tc.NewUsers, v)
+		}
+		if v := r.FlagValueWithContext("recent", tc.Context); v != tc.Recent {
+			t.Errorf("FlagValueWithContext: expected recent for %v to be %t, got %v.", tc.Context, tc.Recent, v)
+		}
+	}
+
+	config = `{
+	  "flag_defs": [{"flag": "new_users", "base_value": false}],
+	  "variants": [{
+	    "id": "NewUsers",
+	    "conditions": [{"type": "COHORT", "values": ["signed_up", "SINCE", "2026-03-01T00:00:00Z"]}],
+	    "mods": [{"flag": "new_users", "value": true}]
+	  }]
+	}`
+	if err := NewRegistry().LoadJSON([]byte(config)); err == nil {
+		t.Error("LoadJSON: expected an error for an unknown COHORT operator.")
+	}
+}
+
+func TestStringMatch(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "prefix", "base_value": false},
+	    {"flag": "suffix", "base_value": false},
+	    {"flag": "contains", "base_value": false},
+	    {"flag": "exact", "base_value": false},
+	    {"flag": "regex", "base_value": false}
+	  ],
+	  "variants": [
+	    {"id": "Prefix", "conditions": [{"type": "STRING_MATCH", "values": ["path", "PREFIX", "/beta"]}], "mods": [{"flag": "prefix", "value": true}]},
+	    {"id": "Suffix", "conditions": [{"type": "STRING_MATCH", "values": ["email", "SUFFIX", "@corp.com"]}], "mods": [{"flag": "suffix", "value": true}]},
+	    {"id": "Contains", "conditions": [{"type": "STRING_MATCH", "values": ["path", "CONTAINS", "/admin/"]}], "mods": [{"flag": "contains", "value": true}]},
+	    {"id": "Exact", "conditions": [{"type": "STRING_MATCH", "values": ["email", "EXACT", "a@corp.com"]}], "mods": [{"flag": "exact", "value": true}]},
+	    {"id": "Regex", "conditions": [{"type": "STRING_MATCH", "values": ["email", "REGEX", "^[a-z]+@(corp|labs)\\.com$"]}], "mods": [{"flag": "regex", "value": true}]}
+	  ]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Flag     string
+		Context  interface{}
+		Expected bool
+	}{
+		{"prefix", map[string]string{"path": "/beta/editor"}, true},
+		{"prefix", map[string]string{"path": "/editor/beta"}, false},
+		{"suffix", map[string]string{"email": "a@corp.com"}, true},
+		{"suffix", map[string]string{"email": "a@corp.com.evil"}, false},
+		{"contains", map[string]string{"path": "/x/admin/y"}, true},
+		{"contains", map[string]string{"path": "/administrator"}, false},
+		{"exact", map[string]string{"email": "a@corp.com"}, true},
+		{"exact", map[string]string{"email": "b@corp.com"}, false},
+		{"regex", map[string]string{"email": "bob@labs.com"}, true},
+		{"regex", map[string]string{"email": "Bob@labs.com"}, false},
+		{"regex", map[string]interface{}{"email": 42}, false},
+		{"regex", map[string]int{"email": 42}, false},
+		{"regex", nil, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext(tc.Flag, tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %s with %v to return %t, got %v.", tc.Flag, tc.Context, tc.Expected, v)
+		}
+	}
+
+	for _, values := range []string{`["email", "REGEX", "("]`, `["email", "GLOB", "*"]`, `["email", "EXACT"]`} {
+		config := `{
+		  "flag_defs": [{"flag": "exact", "base_value": false}],
+		  "variants": [{"id": "Exact", "conditions": [{"type": "STRING_MATCH", "values": ` + values + `}], "mods": [{"flag": "exact", "value": true}]}]
+		}`
+		if err := NewRegistry().LoadJSON([]byte(config)); err == nil {
+			t.Errorf("LoadJSON: expected an error for STRING_MATCH values %s.", values)
+		}
+	}
+}
+
+type request struct {
+	userID int
+	email  string
+}
+
+func (r *request) Get(key string) (interface{}, bool) {
+	switch key {
+	case "user_id":
+		return r.userID, true
+	case "email":
+		return r.email, r.email != ""
+	}
+	return nil, false
+}
+
+func TestStructContext(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "mod", "base_value": false}, {"flag": "corp", "base_value": false}],
+	  "variants": [{
+	    "id": "Mod",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 9]}],
+	    "mods": [{"flag": "mod", "value": true}]
+	  }, {
+	    "id": "Corp",
+	    "conditions": [{"type": "STRING_MATCH", "values": ["email", "SUFFIX", "@corp.com"]}],
+	    "mods": [{"flag": "corp", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	req := &request{userID: 1203, email: "a@corp.com"}
+	if v := r.FlagValueWithContext("mod", req); v != true {
+		t.Errorf("FlagValueWithContext: expected mod to be true, got %v.", v)
+	}
+	if v := r.FlagValueWithContext("corp", req); v != true {
+		t.Errorf("FlagValueWithContext: expected corp to be true, got %v.", v)
+	}
+	req = &request{userID: 1213}
+	if v := r.FlagValueWithContext("mod", req); v != false {
+		t.Errorf("FlagValueWithContext: expected mod to be false, got %v.", v)
+	}
+	if err := r.ValidateContext("corp", req); err == nil {
+		t.Error("ValidateContext: expected an error for a request without an email.")
+	}
+}
+
+func TestInSet(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "in", "base_value": false}, {"flag": "not_in", "base_value": false}],
+	  "variants": [{
+	    "id": "In",
+	    "conditions": [{"type": "IN_SET", "values": ["country", "NZ", "AU"]}],
+	    "mods": [{"flag": "in", "value": true}]
+	  }, {
+	    "id": "NotIn",
+	    "conditions": [{"type": "NOT_IN_SET", "values": ["tier", 1, 2]}],
+	    "mods": [{"flag": "not_in", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Flag     string
+		Context  interface{}
+		Expected bool
+	}{
+		{"in", map[string]string{"country": "NZ"}, true},
+		{"in", map[string]string{"country": "US"}, false},
+		{"in", map[string]string{}, false},
+		{"not_in", map[string]int{"tier": 1}, false},
+		{"not_in", map[string]interface{}{"tier": 2.0}, false},
+		{"not_in", map[string]string{"tier": "2"}, false},
+		{"not_in", map[string]int{"tier": 3}, true},
+		{"not_in", map[string]int{}, false},
+		{"not_in", nil, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext(tc.Flag, tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %s with %v to return %t, got %v.", tc.Flag, tc.Context, tc.Expected, v)
+		}
+	}
+}
+
+func TestGeo(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "promo", "base_value": false}],
+	  "variants": [{
+	    "id": "Promo",
+	    "conditions": [{"type": "GEO", "values": ["region", "us", "eu-west-1"]}],
+	    "mods": [{"flag": "promo", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{map[string]string{"region": "us"}, true},
+		{map[string]string{"region": "us-west"}, true},
+		{map[string]string{"region": "US-East-2"}, true},
+		{map[string]string{"region": "usa"}, false},
+		{map[string]string{"region": "eu-west-1"}, true},
+		{map[string]string{"region": "eu-west-2"}, false},
+		{map[string]string{"region": "eu"}, false},
+		{map[string]interface{}{"region": 1}, false},
+		{map[string]string{}, false},
+		{nil, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("promo", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected context %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+}
+
+func TestFlagEquals(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "plan", "base_value": "free"},
+	    {"flag": "seats", "base_value": 1},
+	    {"flag": "export", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "Pro",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a", "b"]}],
+	    "mods": [{"flag": "plan", "value": "pro"}, {"flag": "seats", "value": 10}]
+	  }, {
+	    "id": "ProExport",
+	    "conditions": [{"type": "FLAG_EQUALS", "values": ["plan", "pro"]}, {"type": "WHITELIST", "values": ["id", "a", "c"]}],
+	    "condition_operator": "AND",
+	    "mods": [{"flag": "export", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := map[string]bool{"a": true, "b": false, "c": false}
+	for id, expected := range testCases {
+		if v := r.FlagValueWithContext("export", map[string]string{"id": id}); v != expected {
+			t.Errorf("FlagValueWithContext: expected export of %q to be %t, got %v.", id, expected, v)
+		}
+	}
+
+	// Reloaded conditions compare the flags of the receiver, and numbers
+	// are compared by value.
+	reload := `{
+	  "flag_defs": [{"flag": "bulk_export", "base_value": false}],
+	  "variants": [{
+	    "id": "BulkExport",
+	    "conditions": [{"type": "FLAG_EQUALS", "values": ["seats", 10]}],
+	    "mods": [{"flag": "bulk_export", "value": true}]
+	  }]
+	}`
+	if err := r.ReloadJSON([]byte(reload)); err != nil {
+		t.Fatalf("ReloadJSON: expected no error, but got %q.", err.Error())
+	}
+	r.AddVariant(Variant{
+		ID:         "TenSeats",
+		Conditions: []Condition{{Type: "WHITELIST", Values: []interface{}{"id", "d"}}},
+		Mods:       []Mod{{FlagName: "seats", Value: int64(10)}},
+	})
+	testCases = map[string]bool{"a": true, "c": false, "d": false}
+	for id, expected := range testCases {
+		ctx := map[string]interface{}{"id": id}
+		if v := r.FlagValueWithContext("bulk_export", ctx); v != expected {
+			t.Errorf("FlagValueWithContext: expected bulk_export of %q to be %t, got %v.", id, expected, v)
+		}
+	}
+
+	// Comparisons may not form a cycle.
+	cycle := `{
+	  "variants": [{
+	    "id": "ExportersArePro",
+	    "conditions": [{"type": "FLAG_EQUALS", "values": ["export", true]}],
+	    "mods": [{"flag": "plan", "value": "pro"}]
+	  }]
+	}`
+	expected := "Flags form a dependency cycle: export -> plan -> export."
+	if err := r.ReloadVariants([]byte(cycle)); err == nil || err.Error() != expected {
+		t.Errorf("ReloadVariants: expected error %q, got %v.", expected, err)
+	}
+	if v := r.FlagValueWithContext("plan", map[string]string{"id": "c"}); v != "free" {
+		t.Errorf("FlagValueWithContext: expected the failed reload to leave plan alone, got %v.", v)
+	}
+
+	err := NewRegistry().LoadJSON([]byte(`{
+	  "flag_defs": [{"flag": "export", "base_value": false}],
+	  "variants": [{"id": "X", "conditions": [{"type": "FLAG_EQUALS", "value": "plan"}], "mods": [{"flag": "export", "value": true}]}]
+	}`))
+	if err == nil {
+		t.Error("LoadJSON: expected an error for FLAG_EQUALS without an expected value, but got nil.")
+	}
+}
+
+// exposureLog is a Logger recording the flags of exposures.
+type exposureLog struct {
+	sync.Mutex
+	flags []string
+}
+
+func (l *exposureLog) LogExposure(flagName, variantID string, value interface{}, context interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.flags = append(l.flags, flagName)
+}
+
+func TestFlagEqualsWithinEvaluation(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "plan", "base_value": "free"}, {"flag": "export", "base_value": false}],
+	  "variants": [{
+	    "id": "Pro",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a", "b"]}],
+	    "mods": [{"flag": "plan", "value": "pro"}],
+	    "max_exposures": 1,
+	    "exposure_key": "id"
+	  }, {
+	    "id": "ProExport",
+	    "conditions": [{"type": "FLAG_EQUALS", "values": ["plan", "pro"]}],
+	    "mods": [{"flag": "export", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	log := &exposureLog{}
+	r.SetLogger(log)
+
+	// Dry runs do not enroll subjects through compared flags.
+	if vs := r.ActiveVariants(map[string]string{"id": "a"}); len(vs) != 0 {
+		t.Errorf("ActiveVariants: expected no variants to be active before enrollment, got %v.", vs)
+	}
+	if len(log.flags) != 0 {
+		t.Errorf("ActiveVariants: expected no exposures to be logged, got %v.", log.flags)
+	}
+	if v := r.FlagValueWithContext("export", map[string]string{"id": "b"}); v != true {
+		t.Errorf("FlagValueWithContext: expected b to be enrolled first, got %v.", v)
+	}
+	if fmt.Sprint(log.flags) != "[export]" {
+		t.Errorf("FlagValueWithContext: expected only the exposure to export to be logged, got %v.", log.flags)
+	}
+
+	// ImpactReport compares the flags of the new config.
+	newConfig := `{
+	  "flag_defs": [{"flag": "plan", "base_value": "pro"}, {"flag": "export", "base_value": false}],
+	  "variants": [{
+	    "id": "ProExport",
+	    "conditions": [{"type": "FLAG_EQUALS", "values": ["plan", "pro"]}],
+	    "mods": [{"flag": "export", "value": true}]
+	  }]
+	}`
+	report, err := r.ImpactReport([]byte(newConfig), []interface{}{map[string]string{"id": "c"}})
+	if err != nil {
+		t.Fatalf("ImpactReport: expected no error, but got %q.", err.Error())
+	}
+	if d := report["export"]; d.Changed != 1 || d.Transitions["false -> true"] != 1 {
+		t.Errorf("ImpactReport: expected export of c to change to true, got %+v.", d)
+	}
+}
+
+func TestRegisterContextualConditionType(t *testing.T) {
+	r := NewRegistry()
+	err := r.RegisterContextualConditionType("FLAG_SET", func(r *Registry, values ...interface{}) func(interface{}) bool {
+		name := values[0].(string)
+		return func(context interface{}) bool {
+			return r.FlagValueWithContext(name, context) != nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("RegisterContextualConditionType: expected no error, but got %q.", err.Error())
+	}
+	config := `{
+	  "flag_defs": [{"flag": "theme", "base_value": null}, {"flag": "themed", "base_value": false}],
+	  "variants": [{
+	    "id": "Dark",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "theme", "value": "dark"}]
+	  }, {
+	    "id": "Themed",
+	    "conditions": [{"type": "FLAG_SET", "value": "theme"}],
+	    "mods": [{"flag": "themed", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValueWithContext("themed", map[string]string{"id": "a"}); v != true {
+		t.Errorf("FlagValueWithContext: expected themed to be true, got %v.", v)
+	}
+	if v := r.FlagValueWithContext("themed", map[string]string{"id": "b"}); v != false {
+		t.Errorf("FlagValueWithContext: expected themed to be false, got %v.", v)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "new_editor", "base_value": false}, {"flag": "legacy", "base_value": false}],
+	  "variants": [{
+	    "id": "NewEditor",
+	    "conditions": [{"type": "VERSION", "values": ["app_version", ">=2.3.0 <3.0.0"]}],
+	    "mods": [{"flag": "new_editor", "value": true}]
+	  }, {
+	    "id": "Legacy",
+	    "conditions": [{"type": "VERSION", "values": ["app_version", "<1.0.0 || 1.5.0"]}],
+	    "mods": [{"flag": "legacy", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Flag     string
+		Context  interface{}
+		Expected bool
+	}{
+		{"new_editor", map[string]string{"app_version": "2.3.0"}, true},
+		{"new_editor", map[string]string{"app_version": "v2.10.1"}, true},
+		{"new_editor", map[string]string{"app_version": "2.9.9+build.7"}, true},
+		{"new_editor", map[string]string{"app_version": "2.2.9"}, false},
+		{"new_editor", map[string]string{"app_version": "2.3.0-beta.1"}, false},
+		{"new_editor", map[string]string{"app_version": "3.0.0-rc.1"}, true},
+		{"new_editor", map[string]string{"app_version": "3.0.0"}, false},
+		{"new_editor", map[string]string{"app_version": "2.3"}, false},
+		{"new_editor", map[string]string{"app_version": "latest"}, false},
+		{"new_editor", map[string]interface{}{"app_version": 2.3}, false},
+		{"new_editor", map[string]string{}, false},
+		{"legacy", map[string]string{"app_version": "0.9.0"}, true},
+		{"legacy", map[string]string{"app_version": "1.5.0"}, true},
+		{"legacy", map[string]string{"app_version": "1.5.1"}, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext(tc.Flag, tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %s with %v to return %t, got %v.", tc.Flag, tc.Context, tc.Expected, v)
+		}
+	}
+
+	for _, constraint := range []string{"", ">=2.3", "~2.3.0", ">=2.3.0 ||", "=>2.3.0", "01.2.3"} {
+		config := `{
+		  "flag_defs": [{"flag": "f", "base_value": false}],
+		  "variants": [{"id": "V", "conditions": [{"type": "VERSION", "values": ["app_version", "` + constraint + `"]}], "mods": [{"flag": "f", "value": true}]}]
+		}`
+		if err := NewRegistry().LoadJSON([]byte(config)); err == nil {
+			t.Errorf("LoadJSON: expected an error for constraint %q, but got nil.", constraint)
+		}
+	}
+}
+
+func TestCIDR(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "new_proxy", "base_value": false}],
+	  "variants": [{
+	    "id": "InternalProxy",
+	    "conditions": [{"type": "CIDR", "values": ["10.0.0.0/8", "192.168.1.0/24", "2001:db8::/32"]}],
+	    "mods": [{"flag": "new_proxy", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{map[string]string{"ip": "10.1.2.3"}, true},
+		{map[string]string{"ip": "192.168.1.200"}, true},
+		{map[string]string{"ip": "192.168.2.1"}, false},
+		{map[string]string{"ip": "2001:db8::1"}, true},
+		{map[string]string{"ip": "2001:db9::1"}, false},
+		{map[string]string{"ip": "::ffff:10.0.0.1"}, true},
+		{map[string]interface{}{"ip": net.ParseIP("10.0.0.1")}, true},
+		{map[string]string{"ip": "not an ip"}, false},
+		{map[string]interface{}{"ip": 10}, false},
+		{map[string]string{}, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("new_proxy", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+
+	malformed := `{
+	  "flag_defs": [{"flag": "new_proxy", "base_value": false}],
+	  "variants": [{
+	    "id": "InternalProxy",
+	    "conditions": [{"type": "CIDR", "values": %s}],
+	    "mods": [{"flag": "new_proxy", "value": true}]
+	  }]
+	}`
+	for _, values := range []string{`[]`, `["10.0.0.0"]`, `["10.0.0.0/33"]`, `[10]`} {
+		if err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(malformed, values))); err == nil {
+			t.Errorf("LoadJSON: expected an error for CIDR values %s, but got nil.", values)
+		}
+	}
+}
+
+func TestNilContext(t *testing.T) {
+	f, err := ioutil.TempFile("", "idset")
+	if err != nil {
+		t.Fatalf("TempFile: expected no error, but got %q.", err.Error())
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	writeIDFile(t, f.Name(), "1001")
+
+	// The values of a condition of each built-in type, and whether the
+	// condition is met without a context. Only conditions reading no
+	// context may be met.
+	testCases := map[string]struct {
+		Values   string
+		Expected bool
+	}{
+		"RANDOM":       {`[1.0]`, true},
+		"MOD_RANGE":    {`["id", 0, 99]`, false},
+		"PERCENTAGE":   {`["id", 100]`, false},
+		"RAMP":         {`["2000-01-01T00:00:00Z", "2000-01-02T00:00:00Z", "id"]`, false},
+		"ID_SET":       {fmt.Sprintf(`["id", %q]`, f.Name()), false},
+		"WHITELIST":    {`["id", "1001"]`, false},
+		"GEO":          {`["region", "us"]`, false},
+		"STRING_MATCH": {`["email", "SUFFIX", "@medium.com"]`, false},
+		"HAS_KEY":      {`["token"]`, false},
+		"IN_SET":       {`["plan", "pro"]`, false},
+		"NOT_IN_SET":   {`["plan", "pro"]`, false},
+		"LOCALE":       {`["locale", "en"]`, false},
+		"LAUNCH":       {`["id", "2000-01-01T00:00:00Z", "1001"]`, true},
+		"TIME_RANGE":   {`["2000-01-01T00:00:00Z", null]`, true},
+		"COHORT":       {`["signup", "BEFORE", "2100-01-01T00:00:00Z"]`, false},
+		"NUMERIC":      {`["age", "GTE", 0]`, false},
+		"VERSION":      {`["app_version", ">=0.0.0"]`, false},
+		"FLAG_EQUALS":  {`["other", false]`, true},
+		"CIDR":         {`["10.0.0.0/8"]`, false},
+		"SCHEDULE":     {`["UTC", ["SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"], "09:00", "17:00"]`, true},
+	}
+	for conditionType := range NewRegistry().conditionSpecs {
+		if _, ok := testCases[conditionType]; !ok {
+			t.Errorf("Expected a nil context test case for built-in condition type %s.", conditionType)
+		}
+	}
+
+	for conditionType, tc := range testCases {
+		r := NewRegistry()
+		r.SetClock(func() time.Time { return time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC) })
+		config := fmt.Sprintf(`{
+		  "flag_defs": [{"flag": "f", "base_value": false}, {"flag": "other", "base_value": false}],
+		  "variants": [{
+		    "id": "NilContext",
+		    "conditions": [{"type": %q, "values": %s}],
+		    "mods": [{"flag": "f", "value": true}]
+		  }]
+		}`, conditionType, tc.Values)
+		if err := r.LoadJSON([]byte(config)); err != nil {
+			t.Fatalf("LoadJSON: expected no error for %s, but got %q.", conditionType, err.Error())
+		}
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					t.Errorf("FlagValue: expected %s not to panic with a nil context, but got %v.", conditionType, p)
+				}
+			}()
+			if v := r.FlagValue("f"); v != tc.Expected {
+				t.Errorf("FlagValue: expected %t for %s with a nil context, got %v.", tc.Expected, conditionType, v)
+			}
+		}()
+	}
+}
+
+func TestGuardNilContext(t *testing.T) {
+	spec := guardNilContext(func(values ...interface{}) (func(interface{}) bool, error) {
+		return func(context interface{}) bool {
+			panic("evaluated")
+		}, nil
+	})
+	evaluator, err := spec()
+	if err != nil {
+		t.Fatalf("guardNilContext: expected no error, but got %q.", err.Error())
+	}
+	if evaluator(nil) {
+		t.Error("guardNilContext: expected a nil context not to be met.")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("guardNilContext: expected a panic with a context to propagate.")
+		}
+	}()
+	evaluator(map[string]string{})
+}
@@ -0,0 +1,341 @@
+package variants
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// A RandContext is a context carrying its own source of randomness.
+// RANDOM conditions draw from it instead of the global source, so that
+// seeding it, for example from a request ID, makes every random decision
+// for the request reproducible.
+type RandContext interface {
+	Rand() *rand.Rand
+}
+
+// A Context is an evaluation context read by key, such as a request
+// object, that built-in condition types accept in place of a map. The
+// map types map[string]interface{}, map[string]string, map[string]int,
+// and map[string]float64 are accepted as well.
+type Context interface {
+	// Get returns the attribute stored under key, and whether it is set.
+	Get(key string) (interface{}, bool)
+}
+
+// contextValue returns the value stored under key when context is a
+// Context or one of the map types accepted by the built-in condition types.
+func contextValue(context interface{}, key string) (interface{}, bool) {
+	switch c := context.(type) {
+	case Context:
+		return c.Get(key)
+	case map[string]interface{}:
+		v, ok := c[key]
+		return v, ok
+	case map[string]string:
+		v, ok := c[key]
+		return v, ok
+	case map[string]int:
+		v, ok := c[key]
+		return v, ok
+	case map[string]float64:
+		v, ok := c[key]
+		return v, ok
+	}
+	return nil, false
+}
+
+// toInt converts a numeric context value to an int, truncating any
+// fractional part.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case float32:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			f, err := n.Float64()
+			if err != nil {
+				return 0, false
+			}
+			return int(f), true
+		}
+		return int(i), true
+	}
+	return 0, false
+}
+
+// SetDefaultContext sets the default context of the DefaultRegistry.
+func SetDefaultContext(ctx map[string]interface{}) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetDefaultContext(ctx)
+}
+
+// SetDefaultContext sets attributes, such as region or app version, that
+// are available to conditions during every evaluation without being
+// passed with each call. They are merged under contexts of type
+// map[string]interface{}, with the per-call context winning on
+// conflicting keys, and used as the context when none is given. Other
+// context types are passed to conditions unchanged.
+func (r *Registry) SetDefaultContext(ctx map[string]interface{}) {
+	defaults := make(map[string]interface{}, len(ctx))
+	for k, v := range ctx {
+		defaults[k] = v
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.update(func(s *snapshot) error {
+		s.defaultContext = defaults
+		return nil
+	})
+}
+
+// SetCaseInsensitiveContext sets whether the DefaultRegistry reads map
+// contexts with case-insensitive keys.
+func SetCaseInsensitiveContext(on bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetCaseInsensitiveContext(on)
+}
+
+// SetCaseInsensitiveContext sets whether the keys of map contexts, such
+// as those built from HTTP headers, are matched regardless of case, so
+// that a condition reading "User-Id" finds the key "user-id". It is off
+// by default, since each evaluation then copies the context, after
+// merging the default context, into a map of lower-cased keys, which
+// costs an allocation and a pass over the context per flag. Keys
+// differing only in case collide, and which value is kept is undefined.
+// Conditions and mods are given the folded map as a Context rather than
+// as the map type passed in; contexts that are not maps are unchanged.
+func (r *Registry) SetCaseInsensitiveContext(on bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.update(func(s *snapshot) error {
+		s.caseInsensitiveContext = on
+		return nil
+	})
+}
+
+// A foldedContext is a map context whose keys are lower-cased, read with
+// lower-cased keys.
+type foldedContext map[string]interface{}
+
+// Get returns the value stored under key, regardless of case.
+func (c foldedContext) Get(key string) (interface{}, bool) {
+	v, ok := c[strings.ToLower(key)]
+	return v, ok
+}
+
+// foldContext returns context as a foldedContext if it is one of the map
+// types accepted by the built-in condition types, and unchanged otherwise.
+func foldContext(context interface{}) interface{} {
+	folded := foldedContext{}
+	switch c := context.(type) {
+	case map[string]interface{}:
+		for k, v := range c {
+			folded[strings.ToLower(k)] = v
+		}
+	case map[string]string:
+		for k, v := range c {
+			folded[strings.ToLower(k)] = v
+		}
+	case map[string]int:
+		for k, v := range c {
+			folded[strings.ToLower(k)] = v
+		}
+	case map[string]float64:
+		for k, v := range c {
+			folded[strings.ToLower(k)] = v
+		}
+	default:
+		return context
+	}
+	return folded
+}
+
+// mergeDefaultContext returns context merged over the receiver's default
+// context, with its keys folded to lower case if the receiver's context is
+// case-insensitive.
+func (s *snapshot) mergeDefaultContext(context interface{}) interface{} {
+	if s.caseInsensitiveContext {
+		return foldContext(s.mergeDefaults(context))
+	}
+	return s.mergeDefaults(context)
+}
+
+// mergeDefaults returns context merged over the receiver's default
+// context.
+func (s *snapshot) mergeDefaults(context interface{}) interface{} {
+	if len(s.defaultContext) == 0 {
+		return context
+	}
+	var ctx map[string]interface{}
+	switch c := context.(type) {
+	case nil:
+	case map[string]interface{}:
+		ctx = c
+	default:
+		return context
+	}
+	merged := make(map[string]interface{}, len(s.defaultContext)+len(ctx))
+	for k, v := range s.defaultContext {
+		merged[k] = v
+	}
+	for k, v := range ctx {
+		merged[k] = v
+	}
+	return merged
+}
+
+// RequiresContext reports whether the named flag of the DefaultRegistry
+// may resolve differently with different contexts.
+func RequiresContext(flagName string) bool {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RequiresContext(flagName)
+}
+
+// RequiresContext reports whether the value of the named flag may depend
+// on the evaluation context, because a condition of one of its variants,
+// of the variants they are grouped with, or of the variants of its
+// prerequisites reads it, or because one of these variants is capped by
+// subject or split into arms, or because one of its variants computes its
+// value from the context. Otherwise, the flag may be resolved once without a context,
+// for example at startup, although conditions such as RANDOM may still
+// resolve it differently each time.
+func (r *Registry) RequiresContext(flagName string) bool {
+	s := r.load()
+	seen := map[string]bool{}
+	var requires func(name string) bool
+	requires = func(name string) bool {
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+		for _, v := range s.sortedVariants(s.flagToVariantIDMap[name]) {
+			if v.readsContext() || v.computesValue(name) {
+				return true
+			}
+			for id := range s.exclusionGroups[v.ExclusionGroup] {
+				if g := s.variants[id]; g.readsContext() {
+					return true
+				}
+			}
+		}
+		for _, dep := range s.flags[name].DependsOn {
+			if requires(dep) {
+				return true
+			}
+		}
+		return false
+	}
+	return requires(flagName)
+}
+
+// readsContext reports whether evaluating v may read the context,
+// including the arm key of a variant with arms.
+func (v *Variant) readsContext() bool {
+	if v.MaxExposures > 0 || len(v.Arms) > 0 {
+		return true
+	}
+	for _, c := range v.allConditions() {
+		if !c.IgnoresContext() {
+			return true
+		}
+	}
+	return false
+}
+
+// computesValue reports whether v computes the value of the named flag
+// from the context, with a mod's ValueFunc or mod type.
+func (v *Variant) computesValue(flagName string) bool {
+	for _, m := range v.Mods {
+		if m.FlagName == flagName && (m.ValueFunc != nil || len(m.Type) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// toString converts a string or numeric context value to a string.
+// Numbers are formatted without exponents or trailing zeros, so that 42
+// and 42.0 both become "42".
+func toString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(s), 'f', -1, 32), true
+	case json.Number:
+		return s.String(), true
+	}
+	if n, ok := toInt(v); ok {
+		return strconv.Itoa(n), true
+	}
+	return "", false
+}
+
+// toFloat converts a numeric context value to a float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	if n, ok := toInt(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// FlagValueWithJSONContext returns the value of the flag with the given
+// name from the DefaultRegistry, given a JSON-encoded context object.
+func FlagValueWithJSONContext(name string, contextJSON []byte) (interface{}, error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.FlagValueWithJSONContext(name, contextJSON)
+}
+
+// FlagValueWithJSONContext returns the value of a flag given a context
+// encoded as a JSON object, as received by an RPC server. The object is
+// decoded into a map[string]interface{}, with numbers decoded as float64
+// just as they are in configs, and passed to FlagValueWithContext. An
+// error is returned if contextJSON is not a valid JSON object.
+func (r *Registry) FlagValueWithJSONContext(name string, contextJSON []byte) (interface{}, error) {
+	var context map[string]interface{}
+	if err := json.Unmarshal(contextJSON, &context); err != nil {
+		return nil, fmt.Errorf("Invalid JSON context: %v", err)
+	}
+	return r.FlagValueWithContext(name, context), nil
+}
@@ -0,0 +1,130 @@
+package variants
+
+import "sort"
+
+// A Range is an inclusive range of buckets.
+type Range struct {
+	Begin int
+	End   int
+}
+
+// RangeCoverage describes how the MOD_RANGE conditions bucketing on a
+// single context key cover the buckets 0 through 99.
+type RangeCoverage struct {
+	Key string
+
+	// Covered is the number of buckets covered by at least one variant.
+	Covered int
+
+	// Overlaps are the ranges covered by more than one variant.
+	Overlaps []Range
+
+	// Gaps are the ranges covered by no variant.
+	Gaps []Range
+}
+
+// A RangeReport describes the bucket coverage of the variants modifying
+// a flag, per context key bucketed on.
+type RangeReport struct {
+	Flag string
+	Keys []RangeCoverage
+}
+
+// CheckRangeCoverage reports the bucket coverage of the named flag's
+// variants within the DefaultRegistry.
+func CheckRangeCoverage(flagName string) RangeReport {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.CheckRangeCoverage(flagName)
+}
+
+// CheckRangeCoverage reports, for each context key that the named flag's
+// variants bucket on with MOD_RANGE conditions, how many of the 100
+// buckets are covered and which ranges are covered more than once or not
+// at all. A variant covers the buckets for which its conditions, combined
+// by its operators and groups, are met; conditions other than the
+// MOD_RANGE conditions on the key are assumed to be met.
+func (r *Registry) CheckRangeCoverage(flagName string) RangeReport {
+	s := r.load()
+	counts := map[string]*[100]int{}
+	for _, v := range s.flagVariants[flagName] {
+		for _, key := range rangeKeys(&v) {
+			if counts[key] == nil {
+				counts[key] = &[100]int{}
+			}
+			for i := range counts[key] {
+				covered := v.evaluateWith(func(c *Condition) bool {
+					k, begin, end, ok := modRange(c)
+					if !ok || k != key {
+						return true
+					}
+					return (i >= begin && i <= end) != c.Negate
+				})
+				if covered {
+					counts[key][i]++
+				}
+			}
+		}
+	}
+
+	report := RangeReport{Flag: flagName, Keys: []RangeCoverage{}}
+	for key, buckets := range counts {
+		cov := RangeCoverage{Key: key}
+		for i, n := range buckets {
+			if n > 0 {
+				cov.Covered++
+			}
+			if n > 1 {
+				cov.Overlaps = extendRanges(cov.Overlaps, i)
+			}
+			if n == 0 {
+				cov.Gaps = extendRanges(cov.Gaps, i)
+			}
+		}
+		report.Keys = append(report.Keys, cov)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool {
+		return report.Keys[i].Key < report.Keys[j].Key
+	})
+	return report
+}
+
+// extendRanges adds bucket i to ranges, extending the last range when i
+// immediately follows it.
+func extendRanges(ranges []Range, i int) []Range {
+	if n := len(ranges); n > 0 && ranges[n-1].End == i-1 {
+		ranges[n-1].End = i
+		return ranges
+	}
+	return append(ranges, Range{Begin: i, End: i})
+}
+
+// rangeKeys returns the distinct context keys bucketed on by the MOD_RANGE
+// conditions of v.
+func rangeKeys(v *Variant) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, c := range v.allConditions() {
+		if key, _, _, ok := modRange(&c); ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// modRange returns the context key and inclusive bucket bounds of c, and
+// whether c is a well-formed MOD_RANGE condition.
+func modRange(c *Condition) (key string, begin, end int, ok bool) {
+	if c.Type != conditionTypeModRange {
+		return "", 0, 0, false
+	}
+	args := c.args()
+	if len(args) != 3 {
+		return "", 0, 0, false
+	}
+	key, ok = args[0].(string)
+	begin, beginOK := toInt(args[1])
+	end, endOK := toInt(args[2])
+	return key, begin, end, ok && beginOK && endOK
+}
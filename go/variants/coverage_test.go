@@ -0,0 +1,71 @@
+package variants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckRangeCoverage(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "rollout", "base_value": "control"}],
+	  "variants": [{
+	    "id": "ArmA",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 49]}],
+	    "mods": [{"flag": "rollout", "value": "a"}]
+	  }, {
+	    "id": "ArmB",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 40, 89]}],
+	    "mods": [{"flag": "rollout", "value": "b"}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	expected := RangeReport{
+		Flag: "rollout",
+		Keys: []RangeCoverage{{
+			Key:      "user_id",
+			Covered:  90,
+			Overlaps: []Range{{40, 49}},
+			Gaps:     []Range{{90, 99}},
+		}},
+	}
+	if report := r.CheckRangeCoverage("rollout"); !reflect.DeepEqual(report, expected) {
+		t.Errorf("CheckRangeCoverage: expected %+v, got %+v.", expected, report)
+	}
+}
+
+func TestCheckRangeCoverageOperators(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "rollout", "base_value": "control"}],
+	  "variants": [{
+	    "id": "Both",
+	    "condition_operator": "AND",
+	    "conditions": [
+	      {"type": "MOD_RANGE", "values": ["user_id", 0, 49]},
+	      {"type": "MOD_RANGE", "values": ["user_id", 25, 99]}
+	    ],
+	    "mods": [{"flag": "rollout", "value": "both"}]
+	  }, {
+	    "id": "Rest",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 49], "negate": true}],
+	    "mods": [{"flag": "rollout", "value": "rest"}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	expected := RangeReport{
+		Flag: "rollout",
+		Keys: []RangeCoverage{{
+			Key:     "user_id",
+			Covered: 75,
+			Gaps:    []Range{{0, 24}},
+		}},
+	}
+	if report := r.CheckRangeCoverage("rollout"); !reflect.DeepEqual(report, expected) {
+		t.Errorf("CheckRangeCoverage: expected %+v, got %+v.", expected, report)
+	}
+}
@@ -0,0 +1,141 @@
+package variants
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// UnmarshalJSON implements json.Unmarshaler. The raw encoding of the base
+// value is retained for any decoder registered for the flag.
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	type flag Flag
+	aux := struct {
+		*flag
+		BaseValue json.RawMessage `json:"base_value"`
+	}{flag: (*flag)(f)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	f.raw = aux.BaseValue
+	f.BaseValue, f.rawValue = nil, nil
+	if len(aux.BaseValue) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(aux.BaseValue, &f.BaseValue); err != nil {
+		return err
+	}
+	f.rawValue = f.BaseValue
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. A base value loaded from JSON is
+// encoded as it was loaded, so that its decoder accepts it again, unless
+// it has since been changed.
+func (f Flag) MarshalJSON() ([]byte, error) {
+	type flag Flag
+	aux := struct {
+		flag
+		BaseValue interface{} `json:"base_value"`
+	}{flag: flag(f), BaseValue: f.BaseValue}
+	if len(f.raw) > 0 && reflect.DeepEqual(f.BaseValue, f.rawValue) {
+		aux.BaseValue = f.raw
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The raw encoding of the
+// value is retained for any decoder registered for the mod's flag.
+func (m *Mod) UnmarshalJSON(data []byte) error {
+	type mod Mod
+	aux := struct {
+		*mod
+		Value json.RawMessage
+	}{mod: (*mod)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	m.raw = aux.Value
+	m.Value, m.rawValue = nil, nil
+	if len(aux.Value) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(aux.Value, &m.Value); err != nil {
+		return err
+	}
+	m.rawValue = m.Value
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. A value loaded from JSON is
+// encoded as it was loaded, so that its decoder accepts it again, unless
+// it has since been changed.
+func (m Mod) MarshalJSON() ([]byte, error) {
+	type mod Mod
+	aux := struct {
+		mod
+		Value interface{}
+	}{mod: mod(m), Value: m.Value}
+	if len(m.raw) > 0 && reflect.DeepEqual(m.Value, m.rawValue) {
+		aux.Value = m.raw
+	}
+	return json.Marshal(aux)
+}
+
+// RegisterFlagDecoder registers fn as the value decoder for the named flag
+// with the DefaultRegistry.
+func RegisterFlagDecoder(flagName string, fn func(json.RawMessage) (interface{}, error)) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterFlagDecoder(flagName, fn)
+}
+
+// RegisterFlagDecoder registers fn to decode the base value and mod values
+// of the named flag when they are loaded from JSON, so that FlagValue
+// returns the concrete type fn produces rather than a generic one. An
+// error from fn fails the load. Decoders must be registered before the
+// config using the flag is loaded.
+func (r *Registry) RegisterFlagDecoder(flagName string, fn func(json.RawMessage) (interface{}, error)) error {
+	r.Lock()
+	defer r.Unlock()
+	if _, found := r.flagDecoders[flagName]; found {
+		return fmt.Errorf("Decoder for flag %q already registered.", flagName)
+	}
+	r.flagDecoders[flagName] = fn
+	return nil
+}
+
+// decodeFlag decodes the base value of f with its registered decoder.
+func (r *Registry) decodeFlag(f *Flag) error {
+	r.RLock()
+	fn, ok := r.flagDecoders[f.Name]
+	r.RUnlock()
+	if !ok || len(f.raw) == 0 {
+		return nil
+	}
+	val, err := fn(f.raw)
+	if err != nil {
+		return fmt.Errorf("Base value of flag %q: %v", f.Name, err)
+	}
+	f.BaseValue, f.rawValue = val, val
+	return nil
+}
+
+// decodeMods decodes the values of v's mods with their flags' registered
+// decoders.
+func (r *Registry) decodeMods(v *Variant) error {
+	r.RLock()
+	defer r.RUnlock()
+	for i, m := range v.Mods {
+		fn, ok := r.flagDecoders[m.FlagName]
+		if !ok || len(m.raw) == 0 {
+			continue
+		}
+		val, err := fn(m.raw)
+		if err != nil {
+			return fmt.Errorf("Mod of flag %q in variant %q: %v", m.FlagName, v.ID, err)
+		}
+		v.Mods[i].Value, v.Mods[i].rawValue = val, val
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package variants
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type timeouts struct {
+	ConnectMS int `json:"connect_ms"`
+	ReadMS    int `json:"read_ms"`
+}
+
+func decodeTimeouts(data json.RawMessage) (interface{}, error) {
+	t := timeouts{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	if t.ConnectMS <= 0 {
+		return nil, errors.New("connect_ms must be positive")
+	}
+	return t, nil
+}
+
+func TestFlagDecoder(t *testing.T) {
+	r := NewRegistry()
+	if err := r.RegisterFlagDecoder("timeouts", decodeTimeouts); err != nil {
+		t.Fatalf("RegisterFlagDecoder: expected no error, but got %q.", err.Error())
+	}
+	if err := r.RegisterFlagDecoder("timeouts", decodeTimeouts); err == nil {
+		t.Error("RegisterFlagDecoder: expected duplicate decoder error, but got nil.")
+	}
+	config := `{
+	  "flag_defs": [{"flag": "timeouts", "base_value": {"connect_ms": 100, "read_ms": 500}}],
+	  "variants": [{
+	    "id": "SlowNetwork",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "timeouts", "value": {"connect_ms": 1000, "read_ms": 5000}}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	expected := timeouts{ConnectMS: 1000, ReadMS: 5000}
+	if v := r.FlagValue("timeouts"); v != expected {
+		t.Errorf("FlagValue: expected %+v, got %#v.", expected, v)
+	}
+	if f := r.Flags()[0]; f.BaseValue != (timeouts{ConnectMS: 100, ReadMS: 500}) {
+		t.Errorf("Flags: expected decoded base value, got %#v.", f.BaseValue)
+	}
+
+	r = NewRegistry()
+	r.RegisterFlagDecoder("timeouts", decodeTimeouts)
+	bad := `{
+	  "flag_defs": [{"flag": "timeouts", "base_value": {"connect_ms": 100}}],
+	  "variants": [{
+	    "id": "Broken",
+	    "mods": [{"flag": "timeouts", "value": {"connect_ms": 0}}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(bad)); err == nil {
+		t.Error("LoadJSON: expected decoder error, but got nil.")
+	}
+}
@@ -0,0 +1,165 @@
+package variants
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dependencyCycle returns an error naming the cycle of flag dependencies
+// that registering f with the receiver would close, if any. A flag
+// depends on its prerequisites and on the flags compared by FLAG_EQUALS
+// conditions of its variants.
+func (s *snapshot) dependencyCycle(f Flag) error {
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		for i, seen := range chain {
+			if seen == name {
+				cycle := append(chain[i:], name)
+				return fmt.Errorf("Flags form a dependency cycle: %s.", strings.Join(cycle, " -> "))
+			}
+		}
+		deps := s.flagDeps(name)
+		if name == f.Name {
+			deps = append(append([]string(nil), f.DependsOn...), s.comparedFlags(name)...)
+		}
+		for _, dep := range deps {
+			if err := visit(dep, append(chain, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(f.Name, nil)
+}
+
+// checkDependencies returns an error if any of the named flags of the
+// receiver depends on a flag registered neither with the receiver nor,
+// if not nil, with target, the snapshot a reload is merged into.
+func (s *snapshot) checkDependencies(names []string, target *snapshot) error {
+	for _, name := range names {
+		for _, dep := range s.flags[name].DependsOn {
+			if _, found := s.flags[dep]; found {
+				continue
+			}
+			if target != nil {
+				if _, found := target.flags[dep]; found {
+					continue
+				}
+			}
+			return fmt.Errorf("Flag %q depends on flag %q, which has not been registered.", name, dep)
+		}
+	}
+	return nil
+}
+
+// flagNames returns the sorted names of the receiver's flags.
+func (s *snapshot) flagNames() []string {
+	names := make([]string, 0, len(s.flags))
+	for name := range s.flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagNames returns the names of fs.
+func flagNames(fs []Flag) []string {
+	names := make([]string, len(fs))
+	for i, f := range fs {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// targetSnapshot returns the snapshot of the registry that the receiver
+// was made to reload into, or nil if it was not.
+func (r *Registry) targetSnapshot() *snapshot {
+	if r.target == nil {
+		return nil
+	}
+	return r.target.load()
+}
+
+// dependencyCycles returns an error naming a cycle of flag dependencies
+// among the receiver's flags, if any, for checking a reload as a whole
+// before it is swapped in.
+func (s *snapshot) dependencyCycles() error {
+	for _, name := range s.flagNames() {
+		if err := s.dependencyCycle(s.flags[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagDeps returns the names of the flags that the named flag depends on.
+func (s *snapshot) flagDeps(name string) []string {
+	return append(append([]string(nil), s.flags[name].DependsOn...), s.comparedFlags(name)...)
+}
+
+// comparedFlags returns the names of the flags compared by FLAG_EQUALS
+// conditions of the variants modifying the named flag.
+func (s *snapshot) comparedFlags(name string) []string {
+	var names []string
+	for _, v := range s.sortedVariants(s.flagToVariantIDMap[name]) {
+		names = append(names, v.comparedFlags()...)
+	}
+	return names
+}
+
+// comparedFlags returns the names of the flags compared by the receiver's
+// FLAG_EQUALS conditions.
+func (v *Variant) comparedFlags() []string {
+	var names []string
+	for _, c := range v.allConditions() {
+		if c.Type != conditionTypeFlagEq {
+			continue
+		}
+		if name, ok := c.args()[0].(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// prerequisitesMet reports whether every flag that the named flag depends
+// on resolves to a truthy value with st's context. A dependency cycle,
+// which registration rejects, counts as unmet.
+func (r *Registry) prerequisitesMet(name string, st *evalState) bool {
+	deps := st.snap.flags[name].DependsOn
+	if len(deps) == 0 {
+		return true
+	}
+	if st.resolving[name] {
+		return false
+	}
+	if st.resolving == nil {
+		st.resolving = map[string]bool{}
+	}
+	st.resolving[name] = true
+	defer delete(st.resolving, name)
+	for _, dep := range deps {
+		if val, _ := r.resolveSnapshot(dep, st); !truthy(val) {
+			return false
+		}
+	}
+	return true
+}
+
+// truthy reports whether v is a value other than nil, false, zero, or the
+// empty string.
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return len(t) > 0
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package variants
+
+import "testing"
+
+func TestDependsOn(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "enable_cart_v2", "base_value": false},
+	    {"flag": "enable_new_checkout", "base_value": false, "depends_on": ["enable_cart_v2"]}
+	  ],
+	  "variants": [{
+	    "id": "CartV2",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "enable_cart_v2", "value": true}]
+	  }, {
+	    "id": "NewCheckout",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a", "b"]}],
+	    "mods": [{"flag": "enable_new_checkout", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		ID       string
+		Expected bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("enable_new_checkout", map[string]string{"id": tc.ID}); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %q to return %t, got %v.", tc.ID, tc.Expected, v)
+		}
+	}
+}
+
+func TestDependsOnCycle(t *testing.T) {
+	config := `{
+	  "flag_defs": [
+	    {"flag": "a", "base_value": true, "depends_on": ["b"]},
+	    {"flag": "b", "base_value": true, "depends_on": ["c"]},
+	    {"flag": "c", "base_value": true, "depends_on": ["a"]}
+	  ]
+	}`
+	expected := "Flags form a dependency cycle: c -> a -> b -> c."
+	if err := NewRegistry().LoadJSON([]byte(config)); err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+	}
+
+	// Reloads may not form a cycle either.
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(`{"flag_defs": [{"flag": "a", "base_value": true, "depends_on": ["b"]}, {"flag": "b", "base_value": true}]}`)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	expected = "Flags form a dependency cycle: a -> b -> a."
+	reload := []byte(`{"flag_defs": [{"flag": "b", "base_value": true, "depends_on": ["a"]}]}`)
+	if err := r.ReloadJSON(reload); err == nil || err.Error() != expected {
+		t.Errorf("ReloadJSON: expected error %q, got %v.", expected, err)
+	}
+	if err := r.ReloadFlags(reload); err == nil || err.Error() != expected {
+		t.Errorf("ReloadFlags: expected error %q, got %v.", expected, err)
+	}
+	if f, _ := r.Flag("b"); len(f.DependsOn) != 0 {
+		t.Errorf("Flag: expected failed reloads to leave b alone, got %+v.", f)
+	}
+}
+
+func TestDependsOnUnregistered(t *testing.T) {
+	expected := `Flag "b" depends on flag "missing", which has not been registered.`
+	config := `{"flag_defs": [{"flag": "a", "base_value": true}, {"flag": "b", "base_value": true, "depends_on": ["a", "missing"]}]}`
+	if err := NewRegistry().LoadJSON([]byte(config)); err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+	}
+	if err := NewRegistry().AddFlag(Flag{Name: "b", DependsOn: []string{"missing"}}); err == nil || err.Error() != expected {
+		t.Errorf("AddFlag: expected error %q, got %v.", expected, err)
+	}
+
+	// Prerequisites may be registered later in the same batch.
+	r := NewRegistry()
+	if err := r.AddFlags([]Flag{{Name: "b", DependsOn: []string{"a"}}, {Name: "a", BaseValue: true}}); err != nil {
+		t.Fatalf("AddFlags: expected no error, but got %q.", err.Error())
+	}
+
+	// Reloads may depend on flags of the registry reloaded into, but not
+	// on flags a Replace reload removes.
+	if err := r.ReloadJSON([]byte(`{"flag_defs": [{"flag": "c", "base_value": true, "depends_on": ["a"]}]}`)); err != nil {
+		t.Errorf("ReloadJSON: expected no error, but got %q.", err.Error())
+	}
+	if err := r.ReloadFlags([]byte(`{"flag_defs": [{"flag": "b", "base_value": true, "depends_on": ["missing"]}]}`)); err == nil || err.Error() != expected {
+		t.Errorf("ReloadFlags: expected error %q, got %v.", expected, err)
+	}
+	expected = `Flag "c" depends on flag "a", which has not been registered.`
+	if err := r.ReloadJSONMode([]byte(`{"flag_defs": [{"flag": "c", "base_value": true, "depends_on": ["a"]}]}`), Replace); err == nil || err.Error() != expected {
+		t.Errorf("ReloadJSONMode: expected error %q, got %v.", expected, err)
+	}
+	if len(r.Flags()) != 3 {
+		t.Errorf("ReloadJSONMode: expected a failed reload to keep every flag, got %v.", r.Flags())
+	}
+}
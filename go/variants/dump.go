@@ -0,0 +1,28 @@
+package variants
+
+import "encoding/json"
+
+// DumpJSON returns the flags and variants registered within the
+// DefaultRegistry, encoded as LoadJSON expects them.
+func DumpJSON() ([]byte, error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.DumpJSON()
+}
+
+// DumpJSON returns the flags and variants registered with the receiver,
+// encoded in the format consumed by LoadJSON, so that a registry changed
+// at runtime can be saved and loaded again. Conditions are encoded by
+// their type and values; their evaluators are rebuilt when loaded. Flags
+// are ordered by name and variants by ID, both read from the same
+// snapshot, so that a concurrent reload cannot leave mods of flags
+// missing from the dump.
+func (r *Registry) DumpJSON() ([]byte, error) {
+	s := r.load()
+	config := configFile{
+		Version:  configVersion,
+		Flags:    s.flagList(),
+		Variants: s.variantList(),
+	}
+	return json.Marshal(config)
+}
@@ -0,0 +1,110 @@
+package variants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDumpJSON(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	data, err := r.DumpJSON()
+	if err != nil {
+		t.Fatalf("DumpJSON: expected no error, but got %q.", err.Error())
+	}
+	other := NewRegistry()
+	if err := other.LoadJSON(data); err != nil {
+		t.Fatalf("LoadJSON: expected the dump to load, but got %q.", err.Error())
+	}
+
+	if !reflect.DeepEqual(flagsByName(other.Flags()), flagsByName(r.Flags())) {
+		t.Errorf("DumpJSON: expected flags %+v, got %+v.", r.Flags(), other.Flags())
+	}
+	for _, v := range r.Variants() {
+		ov, found := other.load().variants[v.ID]
+		if !found {
+			t.Errorf("DumpJSON: expected variant %q to round-trip.", v.ID)
+			continue
+		}
+		if !reflect.DeepEqual(stripEvaluators(ov), stripEvaluators(v)) {
+			t.Errorf("DumpJSON: expected variant %+v, got %+v.", v, ov)
+		}
+	}
+	for _, ctx := range []map[string]int{{"user_id": 3}, {"user_id": 42}} {
+		if a, b := r.FlagValueWithContext("mod_range", ctx), other.FlagValueWithContext("mod_range", ctx); a != b {
+			t.Errorf("DumpJSON: expected mod_range %v for %v, got %v.", a, ctx, b)
+		}
+	}
+
+	again, err := other.DumpJSON()
+	if err != nil || string(again) != string(data) {
+		t.Errorf("DumpJSON: expected a stable dump, got %s, then %s (%v).", data, again, err)
+	}
+}
+
+func flagsByName(flags []Flag) map[string]Flag {
+	m := map[string]Flag{}
+	for _, f := range flags {
+		f.raw = nil
+		m[f.Name] = f
+	}
+	return m
+}
+
+func stripEvaluators(v Variant) Variant {
+	conditions := make([]Condition, len(v.Conditions))
+	for i, c := range v.Conditions {
+		c.Evaluator = nil
+		conditions[i] = c
+	}
+	v.Conditions = conditions
+	mods := make([]Mod, len(v.Mods))
+	for i, m := range v.Mods {
+		m.raw = nil
+		mods[i] = m
+	}
+	v.Mods = mods
+	return v
+}
+
+func TestDumpJSONChangedValues(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "limit", "base_value": 1}],
+	  "variants": [{"id": "Raise", "unconditional": true, "mods": [{"flag": "limit", "value": 2}]}]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+
+	// Change the loaded values at runtime.
+	f, _ := r.Flag("limit")
+	f.BaseValue = 30.0
+	v := r.Variants()[0]
+	v.Mods = []Mod{v.Mods[0]}
+	v.Mods[0].Value = 40.0
+	changed := NewRegistry()
+	if err := changed.AddFlag(f); err != nil {
+		t.Fatalf("AddFlag: expected no error, but got %q.", err.Error())
+	}
+	if err := changed.AddVariant(v); err != nil {
+		t.Fatalf("AddVariant: expected no error, but got %q.", err.Error())
+	}
+
+	data, err := changed.DumpJSON()
+	if err != nil {
+		t.Fatalf("DumpJSON: expected no error, but got %q.", err.Error())
+	}
+	other := NewRegistry()
+	if err := other.LoadJSON(data); err != nil {
+		t.Fatalf("LoadJSON: expected the dump to load, but got %q.", err.Error())
+	}
+	if f, _ := other.Flag("limit"); f.BaseValue != 30.0 {
+		t.Errorf("DumpJSON: expected the changed base value, got %v.", f.BaseValue)
+	}
+	if val := other.FlagValue("limit"); val != 40.0 {
+		t.Errorf("DumpJSON: expected the changed mod value, got %v.", val)
+	}
+}
@@ -0,0 +1,64 @@
+package variants
+
+import "fmt"
+
+// SetVariantsEnabledByTag enables or disables every variant with the given
+// tag within the DefaultRegistry.
+func SetVariantsEnabledByTag(tag string, enabled bool) int {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.SetVariantsEnabledByTag(tag, enabled)
+}
+
+// SetVariantEnabled enables or disables the variant with the given ID
+// within the DefaultRegistry.
+func SetVariantEnabled(id string, enabled bool) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.SetVariantEnabled(id, enabled)
+}
+
+// SetVariantEnabled enables or disables the variant with the given ID,
+// returning an error if it is not registered. Like
+// SetVariantsEnabledByTag, it takes effect immediately without a reload,
+// and a later reload of the variant resets its state to that of the
+// config.
+func (r *Registry) SetVariantEnabled(id string, enabled bool) error {
+	r.Lock()
+	defer r.Unlock()
+	return r.update(func(s *snapshot) error {
+		v, found := s.variants[id]
+		if !found {
+			return fmt.Errorf("Variant with ID %q has not been registered.", id)
+		}
+		v.Enabled = &enabled
+		s.variants[id] = v
+		return nil
+	})
+}
+
+// SetVariantsEnabledByTag enables or disables every variant with the given
+// tag, returning the number of variants affected. This takes effect
+// immediately without a reload, making it a fast lever for switching off
+// a class of variants during an incident. A later reload of a variant
+// resets its state to that of the config.
+func (r *Registry) SetVariantsEnabledByTag(tag string, enabled bool) int {
+	r.Lock()
+	defer r.Unlock()
+	n := 0
+	r.update(func(s *snapshot) error {
+		for id, v := range s.variants {
+			for _, t := range v.Tags {
+				if t == tag {
+					e := enabled
+					v.Enabled = &e
+					s.variants[id] = v
+					n++
+					break
+				}
+			}
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,87 @@
+package variants
+
+import "testing"
+
+func TestSetVariantsEnabledByTag(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "new_checkout", "base_value": false},
+	    {"flag": "new_search", "base_value": false},
+	    {"flag": "new_footer", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "NewCheckout",
+	    "unconditional": true,
+	    "tags": ["risky", "payments"],
+	    "mods": [{"flag": "new_checkout", "value": true}]
+	  }, {
+	    "id": "NewSearch",
+	    "unconditional": true,
+	    "tags": ["risky"],
+	    "mods": [{"flag": "new_search", "value": true}]
+	  }, {
+	    "id": "NewFooter",
+	    "enabled": false,
+	    "unconditional": true,
+	    "mods": [{"flag": "new_footer", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("new_footer"); v != false {
+		t.Errorf("FlagValue: expected disabled variant not to apply, got %v.", v)
+	}
+
+	if n := r.SetVariantsEnabledByTag("risky", false); n != 2 {
+		t.Errorf("SetVariantsEnabledByTag: expected 2 variants affected, got %d.", n)
+	}
+	for _, flag := range []string{"new_checkout", "new_search"} {
+		if v := r.FlagValue(flag); v != false {
+			t.Errorf("FlagValue: expected %s to be disabled, got %v.", flag, v)
+		}
+	}
+
+	if n := r.SetVariantsEnabledByTag("payments", true); n != 1 {
+		t.Errorf("SetVariantsEnabledByTag: expected 1 variant affected, got %d.", n)
+	}
+	if v := r.FlagValue("new_checkout"); v != true {
+		t.Errorf("FlagValue: expected new_checkout to be re-enabled, got %v.", v)
+	}
+	if n := r.SetVariantsEnabledByTag("unknown", true); n != 0 {
+		t.Errorf("SetVariantsEnabledByTag: expected no variants affected, got %d.", n)
+	}
+}
+
+func TestSetVariantEnabled(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "new_checkout", "base_value": false}],
+	  "variants": [{
+	    "id": "NewCheckout",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "new_checkout", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if err := r.SetVariantEnabled("NewCheckout", false); err != nil {
+		t.Fatalf("SetVariantEnabled: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("new_checkout"); v != false {
+		t.Errorf("FlagValue: expected the disabled variant not to apply, got %v.", v)
+	}
+	if err := r.SetVariantEnabled("NewCheckout", true); err != nil {
+		t.Fatalf("SetVariantEnabled: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("new_checkout"); v != true {
+		t.Errorf("FlagValue: expected the re-enabled variant to apply, got %v.", v)
+	}
+
+	expected := `Variant with ID "Unknown" has not been registered.`
+	if err := r.SetVariantEnabled("Unknown", false); err == nil || err.Error() != expected {
+		t.Errorf("SetVariantEnabled: expected error %q, got %v.", expected, err)
+	}
+}
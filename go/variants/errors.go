@@ -0,0 +1,88 @@
+package variants
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An UnknownConditionTypeError reports a condition whose type has no
+// registered evaluator, so that it can never be met.
+type UnknownConditionTypeError struct {
+	VariantID string
+	Type      string
+}
+
+func (e *UnknownConditionTypeError) Error() string {
+	return fmt.Sprintf("unknown condition type %q in variant %q", e.Type, e.VariantID)
+}
+
+// An InvalidConditionError reports a condition whose values were rejected
+// by its condition type when loading the variant with the given ID.
+type InvalidConditionError struct {
+	VariantID string
+	Index     int
+	Type      string
+	Values    []interface{}
+	Err       error
+}
+
+func (e *InvalidConditionError) Error() string {
+	return fmt.Sprintf("Condition %d (%s) of variant %q has invalid values %v: %v", e.Index, e.Type, e.VariantID, e.Values, e.Err)
+}
+
+// Unwrap returns the error of the condition type rejecting the values.
+func (e *InvalidConditionError) Unwrap() error {
+	return e.Err
+}
+
+// ErrNoEvaluator is returned by Condition.EvaluateErr for a condition
+// without an evaluator.
+var ErrNoEvaluator = errors.New("Condition has no evaluator.")
+
+// A ConditionError reports a condition of a variant whose evaluator could
+// not evaluate it.
+type ConditionError struct {
+	VariantID string
+	Type      string
+	Err       error
+}
+
+func (e *ConditionError) Error() string {
+	return fmt.Sprintf("condition type %q in variant %q: %v", e.Type, e.VariantID, e.Err)
+}
+
+// Unwrap returns the error of the condition's evaluator.
+func (e *ConditionError) Unwrap() error {
+	return e.Err
+}
+
+// conditionError returns the error describing err, returned by evaluating
+// c, a condition of the variant with the given ID.
+func conditionError(variantID string, c *Condition, err error) error {
+	if err == ErrNoEvaluator {
+		return &UnknownConditionTypeError{VariantID: variantID, Type: c.Type}
+	}
+	return &ConditionError{VariantID: variantID, Type: c.Type, Err: err}
+}
+
+// FlagValueWithContextErr returns the value of the flag with the given
+// name and context from the DefaultRegistry, and any misconfiguration found.
+func FlagValueWithContextErr(name string, context interface{}) (interface{}, error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.FlagValueWithContextErr(name, context)
+}
+
+// FlagValueWithContextErr is like FlagValueWithContext, but also returns
+// an *UnknownConditionTypeError if any variant evaluated while resolving
+// the flag has a condition without an evaluator, for example because its
+// type was not registered when the config was loaded. Such a condition is
+// never met, so the returned value may silently differ from what the
+// config intends. Likewise, a *ConditionError is returned if a condition
+// evaluated could not be, which also counts as not met. The value is
+// returned even when the error is non-nil.
+func (r *Registry) FlagValueWithContextErr(name string, context interface{}) (interface{}, error) {
+	opts := &evalState{checkConditions: true}
+	val := r.evaluate(name, context, opts)
+	return val, opts.err
+}
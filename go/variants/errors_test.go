@@ -0,0 +1,104 @@
+package variants
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFlagValueWithContextErr(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "custom", "base_value": false}, {"flag": "random", "base_value": false}],
+	  "variants": [{
+	    "id": "Unregistered",
+	    "conditions": [{"type": "UNREGISTERED", "value": "x"}],
+	    "mods": [{"flag": "custom", "value": true}]
+	  }, {
+	    "id": "Random",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "random", "value": true}]
+	  }]
+	}`
+	err := NewRegistry().LoadJSON([]byte(config))
+	if e, ok := err.(*UnknownConditionTypeError); !ok || e.Error() != `unknown condition type "UNREGISTERED" in variant "Unregistered"` {
+		t.Fatalf("LoadJSON: expected an UnknownConditionTypeError, got %v.", err)
+	}
+	if err := r.LoadJSONWithOptions([]byte(config), LoadOptions{Lenient: true}); err != nil {
+		t.Fatalf("LoadJSONWithOptions: expected no error, but got %q.", err.Error())
+	}
+
+	v, err := r.FlagValueWithContextErr("custom", nil)
+	if v != false {
+		t.Errorf("FlagValueWithContextErr: expected base value, got %v.", v)
+	}
+	if e, ok := err.(*UnknownConditionTypeError); !ok || e.VariantID != "Unregistered" || e.Type != "UNREGISTERED" {
+		t.Errorf("FlagValueWithContextErr: expected UnknownConditionTypeError for Unregistered, got %v.", err)
+	}
+
+	v, err = r.FlagValueWithContextErr("random", nil)
+	if v != true || err != nil {
+		t.Errorf("FlagValueWithContextErr: expected true and no error, got %v and %v.", v, err)
+	}
+}
+
+func TestEvaluateErr(t *testing.T) {
+	errWrongType := errors.New("context is not a string")
+	isFoo := func(context interface{}) (bool, error) {
+		s, ok := context.(string)
+		if !ok {
+			return false, errWrongType
+		}
+		return s == "foo", nil
+	}
+	c := Condition{Type: "FOO", EvaluatorErr: isFoo}
+	if met, err := c.EvaluateErr("foo"); !met || err != nil {
+		t.Errorf("EvaluateErr: expected true and no error, got %v and %v.", met, err)
+	}
+	if met, err := c.EvaluateErr("bar"); met || err != nil {
+		t.Errorf("EvaluateErr: expected false and no error, got %v and %v.", met, err)
+	}
+	if met, err := c.EvaluateErr(42); met || err != errWrongType {
+		t.Errorf("EvaluateErr: expected false and %v, got %v and %v.", errWrongType, met, err)
+	}
+	if c.Evaluate(42) {
+		t.Error("Evaluate: expected a condition that cannot be evaluated not to be met.")
+	}
+	if _, err := (&Condition{Type: "NONE"}).EvaluateErr("foo"); err != ErrNoEvaluator {
+		t.Errorf("EvaluateErr: expected ErrNoEvaluator, got %v.", err)
+	}
+
+	v := Variant{
+		ID:                  "Foo",
+		ConditionalOperator: ConditionalOperatorOr,
+		Conditions:          []Condition{c, {Type: "TRUE", Evaluator: func(interface{}) bool { return true }}},
+	}
+	met, err := v.EvaluateErr(42)
+	if !met {
+		t.Error("EvaluateErr: expected the variant to be met by its other condition.")
+	}
+	var ce *ConditionError
+	if !errors.As(err, &ce) || ce.VariantID != "Foo" || ce.Type != "FOO" || !errors.Is(err, errWrongType) {
+		t.Errorf("EvaluateErr: expected a ConditionError wrapping %v, got %v.", errWrongType, err)
+	}
+	if met, err := v.EvaluateErr("foo"); !met || err != nil {
+		t.Errorf("EvaluateErr: expected true and no error, got %v and %v.", met, err)
+	}
+
+	v = Variant{ID: "None", Conditions: []Condition{{Type: "NONE"}}}
+	if _, err := v.EvaluateErr("foo"); err == nil || err.Error() != `unknown condition type "NONE" in variant "None"` {
+		t.Errorf("EvaluateErr: expected an UnknownConditionTypeError, got %v.", err)
+	}
+
+	r := NewRegistry()
+	r.AddFlag(Flag{Name: "foo", BaseValue: false})
+	v = Variant{ID: "Foo", Conditions: []Condition{c}, Mods: []Mod{{FlagName: "foo", Value: true}}}
+	if err := r.AddVariant(v); err != nil {
+		t.Fatalf("AddVariant: expected no error, but got %q.", err.Error())
+	}
+	if val, err := r.FlagValueWithContextErr("foo", "foo"); val != true || err != nil {
+		t.Errorf("FlagValueWithContextErr: expected true and no error, got %v and %v.", val, err)
+	}
+	if val, err := r.FlagValueWithContextErr("foo", 42); val != false || !errors.Is(err, errWrongType) {
+		t.Errorf("FlagValueWithContextErr: expected false and %v, got %v and %v.", errWrongType, val, err)
+	}
+}
@@ -0,0 +1,91 @@
+package variants
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// FlagValueWithETag returns the value of the flag with the given name and
+// context from the DefaultRegistry, and an ETag of the inputs producing it.
+func FlagValueWithETag(name string, context interface{}) (value interface{}, etag string) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.FlagValueWithETag(name, context)
+}
+
+// FlagValueWithETag is like FlagValueWithContext, but also returns an ETag,
+// a stable hash of the inputs that produced the value, for keying caches
+// such as HTTP caches at the edge. The ETag covers the definitions of the
+// flag and of the variants modifying it, and the context attributes their
+// conditions read, as declared by the conditions' schemas. When a condition
+// type declares no schema, or a mod computes its value from the context,
+// the whole context is covered. Identical inputs
+// yield identical ETags across processes. The ETag does not cover inputs
+// outside the registry, such as the current time or random numbers, so
+// flags depending on them should not be cached by it. The ETag is empty
+// if the inputs cannot be encoded as JSON.
+func (r *Registry) FlagValueWithETag(name string, context interface{}) (value interface{}, etag string) {
+	return r.FlagValueWithContext(name, context), r.etag(name, context)
+}
+
+// etag returns the ETag of the named flag for context.
+func (r *Registry) etag(name string, context interface{}) string {
+	r.RLock()
+	defer r.RUnlock()
+	s := r.load()
+	context = s.mergeDefaultContext(context)
+	variants := s.sortedVariants(s.flagToVariantIDMap[name])
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].ID < variants[j].ID
+	})
+
+	inputs := struct {
+		Flag     Flag                   `json:"flag"`
+		Variants []Variant              `json:"variants"`
+		Context  map[string]interface{} `json:"context,omitempty"`
+		Whole    interface{}            `json:"whole_context,omitempty"`
+	}{Flag: s.flags[name], Variants: variants}
+	for _, v := range variants {
+		for _, m := range v.Mods {
+			if m.FlagName == name && m.ValueFunc != nil {
+				inputs.Whole = context
+			}
+		}
+		if len(v.Arms) > 0 {
+			if val, ok := contextValue(context, v.ArmKey); ok {
+				if inputs.Context == nil {
+					inputs.Context = map[string]interface{}{}
+				}
+				inputs.Context[v.ArmKey] = val
+			}
+		}
+		for _, c := range v.allConditions() {
+			schema, ok := r.conditionSchemas[c.Type]
+			if !ok {
+				inputs.Whole = context
+				continue
+			}
+			for _, attr := range schema.Context {
+				key, err := attr.key(c.args())
+				if err != nil {
+					continue
+				}
+				if val, ok := contextValue(context, key); ok {
+					if inputs.Context == nil {
+						inputs.Context = map[string]interface{}{}
+					}
+					inputs.Context[key] = val
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
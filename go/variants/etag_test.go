@@ -0,0 +1,34 @@
+package variants
+
+import "testing"
+
+func TestFlagValueWithETag(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	v, etag := r.FlagValueWithETag("mod_range", map[string]interface{}{"user_id": 3, "session": "a"})
+	if v != true || len(etag) == 0 {
+		t.Fatalf("FlagValueWithETag: expected true and an ETag, got %v and %q.", v, etag)
+	}
+	if _, other := r.FlagValueWithETag("mod_range", map[string]interface{}{"user_id": 3, "session": "b"}); other != etag {
+		t.Errorf("FlagValueWithETag: expected attributes no condition reads not to change the ETag, got %q and %q.", etag, other)
+	}
+	if _, other := r.FlagValueWithETag("mod_range", map[string]interface{}{"user_id": 4}); other == etag {
+		t.Errorf("FlagValueWithETag: expected a different user_id to change the ETag %q.", etag)
+	}
+
+	same := NewRegistry()
+	if err := same.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	if _, other := same.FlagValueWithETag("mod_range", map[string]int{"user_id": 3}); other != etag {
+		t.Errorf("FlagValueWithETag: expected identical inputs to yield identical ETags, got %q and %q.", etag, other)
+	}
+	if err := same.ReloadConfig("testdata/testdata_reloaded.json"); err != nil {
+		t.Fatalf("ReloadConfig: Expected no error, but got %q", err.Error())
+	}
+	if _, other := same.FlagValueWithETag("mod_range", map[string]int{"user_id": 3}); other == etag {
+		t.Errorf("FlagValueWithETag: expected a changed definition to change the ETag %q.", etag)
+	}
+}
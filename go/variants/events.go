@@ -0,0 +1,105 @@
+package variants
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// An EvalEvent records the resolution of a single flag.
+type EvalEvent struct {
+	Flag      string      `json:"flag"`
+	Context   interface{} `json:"context"`
+	Value     interface{} `json:"value"`
+	VariantID string      `json:"variant_id,omitempty"`
+
+	// Reason tells why the variant was activated, such as
+	// ReasonAllowlist or ReasonSchedule, when its conditions
+	// distinguish it.
+	Reason string    `json:"reason,omitempty"`
+	Seed   int64     `json:"seed,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// A SeededContext is a context carrying the seed of any randomness used
+// while serving the request it describes. The seed is recorded with
+// evaluation events so that randomized decisions can be investigated.
+type SeededContext interface {
+	Seed() int64
+}
+
+// EventStream returns a stream of evaluation events from the DefaultRegistry.
+func EventStream(buffer int) <-chan EvalEvent {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.EventStream(buffer)
+}
+
+// EventStream returns a channel receiving an EvalEvent for every flag
+// evaluated by the receiver. The channel holds up to buffer events; when
+// it is full, new events are dropped rather than blocking evaluation and
+// counted by DroppedEvents. The channel is closed by Close.
+func (r *Registry) EventStream(buffer int) <-chan EvalEvent {
+	r.streamsMu.Lock()
+	defer r.streamsMu.Unlock()
+	ch := make(chan EvalEvent, buffer)
+	if r.closed {
+		close(ch)
+		return ch
+	}
+	streams := r.loadStreams()
+	r.streams.Store(append(streams[:len(streams):len(streams)], ch))
+	return ch
+}
+
+// DroppedEvents returns the number of events dropped because an event
+// stream's consumer fell behind.
+func (r *Registry) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&r.droppedEvents)
+}
+
+// Close closes all event streams of the receiver. Flags may still be
+// evaluated after Close, but no further events are emitted.
+func (r *Registry) Close() error {
+	r.streamsMu.Lock()
+	defer r.streamsMu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	for _, ch := range r.loadStreams() {
+		close(ch)
+	}
+	r.streams.Store([]chan EvalEvent(nil))
+	return nil
+}
+
+// loadStreams returns the receiver's open event streams, a slice which
+// must not be modified.
+func (r *Registry) loadStreams() []chan EvalEvent {
+	return r.streams.Load().([]chan EvalEvent)
+}
+
+// emit sends e to every open event stream without blocking. It takes no
+// lock when there are none.
+func (r *Registry) emit(e EvalEvent) {
+	if len(r.loadStreams()) == 0 {
+		return
+	}
+	r.streamsMu.RLock()
+	defer r.streamsMu.RUnlock()
+	streams := r.loadStreams()
+	if len(streams) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	if sc, ok := e.Context.(SeededContext); ok {
+		e.Seed = sc.Seed()
+	}
+	for _, ch := range streams {
+		select {
+		case ch <- e:
+		default:
+			atomic.AddUint64(&r.droppedEvents, 1)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package variants
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func TestEventStream(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	events := r.EventStream(1)
+	r.FlagValue("always_passes")
+	r.FlagValue("always_fails")
+
+	e := <-events
+	if e.Flag != "always_passes" || e.Value != true || e.VariantID != "AlwaysPassesTest" {
+		t.Errorf("EventStream: expected always_passes event from AlwaysPassesTest, got %+v.", e)
+	}
+	if n := r.DroppedEvents(); n != 1 {
+		t.Errorf("DroppedEvents: expected 1 dropped event, got %d.", n)
+	}
+
+	r.Close()
+	if _, ok := <-events; ok {
+		t.Error("Close: expected event stream to be closed.")
+	}
+	if _, ok := <-r.EventStream(1); ok {
+		t.Error("EventStream: expected stream of a closed registry to be closed.")
+	}
+	r.FlagValue("always_passes")
+}
+
+func TestReplay(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	events := r.EventStream(10)
+	for _, id := range []int{5, 50} {
+		r.FlagValueWithContext("mod_range", map[string]interface{}{"user_id": id})
+	}
+	r.Close()
+
+	buf := &bytes.Buffer{}
+	if err := RecordEvents(events, buf); err != nil {
+		t.Fatalf("RecordEvents: expected no error, but got %q.", err.Error())
+	}
+	recorded, err := ReadEvents(buf)
+	if err != nil {
+		t.Fatalf("ReadEvents: expected no error, but got %q.", err.Error())
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("ReadEvents: expected 2 events, got %d.", len(recorded))
+	}
+	if m := r.Replay(recorded); len(m) != 0 {
+		t.Errorf("Replay: expected no mismatches against the same config, got %+v.", m)
+	}
+
+	fixed := NewRegistry()
+	if err := fixed.LoadJSON([]byte(`{
+	  "flag_defs": [{"flag": "mod_range", "base_value": false}],
+	  "variants": [{
+	    "id": "ModRangeTest",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 99]}],
+	    "mods": [{"flag": "mod_range", "value": true}]
+	  }]
+	}`)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	m := fixed.Replay(recorded)
+	if len(m) != 1 || m[0].Value != true || m[0].VariantID != "ModRangeTest" {
+		t.Errorf("Replay: expected user 50 to now resolve to true, got %+v.", m)
+	}
+}
+
+// A requestContext seeds its randomness from a request ID, as servers
+// recording events with their seeds would.
+type requestContext struct {
+	attrs map[string]interface{}
+	seed  int64
+}
+
+func (c requestContext) Get(key string) (interface{}, bool) {
+	v, ok := c.attrs[key]
+	return v, ok
+}
+func (c requestContext) Rand() *rand.Rand             { return rand.New(rand.NewSource(c.seed)) }
+func (c requestContext) Seed() int64                  { return c.seed }
+func (c requestContext) MarshalJSON() ([]byte, error) { return json.Marshal(c.attrs) }
+
+func TestReplaySeed(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	events := r.EventStream(32)
+	for seed := int64(1); seed <= 32; seed++ {
+		r.FlagValueWithContext("coin_flip", requestContext{map[string]interface{}{"user_id": seed}, seed})
+	}
+	r.Close()
+
+	buf := &bytes.Buffer{}
+	if err := RecordEvents(events, buf); err != nil {
+		t.Fatalf("RecordEvents: expected no error, but got %q.", err.Error())
+	}
+	recorded, err := ReadEvents(buf)
+	if err != nil {
+		t.Fatalf("ReadEvents: expected no error, but got %q.", err.Error())
+	}
+	if len(recorded) != 32 || recorded[0].Seed != 1 {
+		t.Fatalf("ReadEvents: expected 32 seeded events, got %+v.", recorded)
+	}
+	if m := r.Replay(recorded); len(m) != 0 {
+		t.Errorf("Replay: expected seeded events to replay their random decisions, got %d mismatches.", len(m))
+	}
+}
@@ -0,0 +1,86 @@
+package variants
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Explain returns the value of the flag with the given name and context
+// from the DefaultRegistry, with the variant that supplied it.
+func Explain(name string, context interface{}) (value interface{}, variantID string, matched bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.Explain(name, context)
+}
+
+// Explain is like FlagValueWithContext, but also returns the ID of the
+// variant whose mod supplied the value, and whether any variant matched.
+// When no variant matched, the variant ID is empty and the value is the
+// flag's base value, unless middleware supplied the value instead of
+// resolving the flag. Like ActiveVariants, it is a dry run: no subject is
+// enrolled in a capped variant, and no exposure is observed, logged, or
+// streamed.
+func (r *Registry) Explain(name string, context interface{}) (value interface{}, variantID string, matched bool) {
+	opts := &evalState{dryRun: true}
+	value = r.evaluate(name, context, opts)
+	return value, opts.variantID, len(opts.variantID) > 0
+}
+
+// EvaluateVariant evaluates the variant with the given ID within the
+// DefaultRegistry for context.
+func EvaluateVariant(id string, context interface{}) (bool, error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.EvaluateVariant(id, context)
+}
+
+// EvaluateVariant reports whether the conditions of the registered variant
+// with the given ID are met by context, merged with the default context,
+// for tools testing a variant apart from the flags it modifies. Whether
+// the variant is enabled, capped, or excluded by another variant of its
+// exclusion group is not considered. It returns an error if the variant
+// is not registered, or, as Variant.EvaluateErr does, if a condition
+// could not be evaluated.
+func (r *Registry) EvaluateVariant(id string, context interface{}) (bool, error) {
+	s := r.load()
+	v, found := s.variants[id]
+	if !found {
+		return false, fmt.Errorf("Variant with ID %q has not been registered.", id)
+	}
+	return v.EvaluateErr(s.mergeDefaultContext(context))
+}
+
+// ActiveVariants returns the variants of the DefaultRegistry that are
+// active for context.
+func ActiveVariants(context interface{}) []Variant {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ActiveVariants(context)
+}
+
+// ActiveVariants returns every registered variant that is active for
+// context, merged with the default context, ordered by ID, whichever
+// flags it modifies, for diagnosing how overlapping variants resolve
+// flags. A variant is active as it is when resolving a flag: it must be
+// enabled, its conditions met, and it must win its exclusion group, if
+// any. A capped variant is active only for a subject already enrolled,
+// and no subject is enrolled by the call.
+func (r *Registry) ActiveVariants(context interface{}) []Variant {
+	s := r.load()
+	st := &evalState{
+		snap:    s,
+		context: s.mergeDefaultContext(context),
+		results: map[string]bool{},
+		dryRun:  true,
+	}
+	active := []Variant{}
+	for _, v := range s.variants {
+		if r.isActive(v, st) {
+			active = append(active, v)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].ID < active[j].ID
+	})
+	return active
+}
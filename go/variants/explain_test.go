@@ -0,0 +1,147 @@
+package variants
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "color", "base_value": "gray"}],
+	  "variants": [{
+	    "id": "Blue",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "priority": 2,
+	    "mods": [{"flag": "color", "value": "blue"}]
+	  }, {
+	    "id": "Red",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a", "b"]}],
+	    "mods": [{"flag": "color", "value": "red"}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		ID        string
+		Value     interface{}
+		VariantID string
+		Matched   bool
+	}{
+		{"a", "blue", "Blue", true},
+		{"b", "red", "Red", true},
+		{"c", "gray", "", false},
+	}
+	for _, tc := range testCases {
+		value, variantID, matched := r.Explain("color", map[string]string{"id": tc.ID})
+		if value != tc.Value || variantID != tc.VariantID || matched != tc.Matched {
+			t.Errorf("Explain: expected %q to return %v, %q, %t, got %v, %q, %t.", tc.ID, tc.Value, tc.VariantID, tc.Matched, value, variantID, matched)
+		}
+	}
+
+	// Explaining a flag is a dry run.
+	log := &exposureLog{}
+	r.SetLogger(log)
+	events := r.EventStream(1)
+	r.Explain("color", map[string]string{"id": "a"})
+	if len(log.flags) != 0 || len(events) != 0 {
+		t.Errorf("Explain: expected no exposure to be logged or streamed, got %v and %d events.", log.flags, len(events))
+	}
+	capped := `{"variants": [{"id": "Capped", "unconditional": true, "priority": 3, "max_exposures": 1, "exposure_key": "id", "mods": [{"flag": "color", "value": "gold"}]}]}`
+	if err := r.ReloadVariants([]byte(capped)); err != nil {
+		t.Fatalf("ReloadVariants: expected no error, but got %q.", err.Error())
+	}
+	r.Explain("color", map[string]string{"id": "a"})
+	if value := r.FlagValueWithContext("color", map[string]string{"id": "b"}); value != "gold" {
+		t.Errorf("Explain: expected no subject to be enrolled by explaining, got %v.", value)
+	}
+
+	r.Use(Overrides(map[string]interface{}{"color": "green"}))
+	if value, variantID, matched := r.Explain("color", map[string]string{"id": "a"}); value != "green" || variantID != "" || matched {
+		t.Errorf("Explain: expected an override to return green without a variant, got %v, %q, %t.", value, variantID, matched)
+	}
+}
+
+func TestEvaluateVariant(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "BetaTesters",
+	    "enabled": false,
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{map[string]interface{}{"id": "a"}, true},
+		{map[string]interface{}{"id": "b"}, false},
+		{nil, false},
+	}
+	for _, tc := range testCases {
+		met, err := r.EvaluateVariant("BetaTesters", tc.Context)
+		if err != nil {
+			t.Errorf("EvaluateVariant: expected no error for %v, but got %q.", tc.Context, err.Error())
+		} else if met != tc.Expected {
+			t.Errorf("EvaluateVariant: expected %t for %v, got %t.", tc.Expected, tc.Context, met)
+		}
+	}
+
+	r.SetDefaultContext(map[string]interface{}{"id": "a"})
+	if met, _ := r.EvaluateVariant("BetaTesters", nil); !met {
+		t.Error("EvaluateVariant: expected the default context to meet the conditions.")
+	}
+	if _, err := r.EvaluateVariant("Unknown", nil); err == nil || err.Error() != `Variant with ID "Unknown" has not been registered.` {
+		t.Errorf("EvaluateVariant: expected an error for an unknown variant, got %v.", err)
+	}
+}
+
+func TestActiveVariants(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "color", "base_value": "blue"}, {"flag": "size", "base_value": 1}],
+	  "variants": [{
+	    "id": "Red",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a", "b"]}],
+	    "mods": [{"flag": "color", "value": "red"}]
+	  }, {
+	    "id": "Green",
+	    "priority": 1,
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "color", "value": "green"}]
+	  }, {
+	    "id": "Large",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "b"]}],
+	    "mods": [{"flag": "size", "value": 2}]
+	  }, {
+	    "id": "Off",
+	    "enabled": false,
+	    "unconditional": true,
+	    "mods": [{"flag": "size", "value": 3}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := map[string]string{
+		"a": "Green,Red",
+		"b": "Large,Red",
+		"c": "",
+	}
+	for id, expected := range testCases {
+		ids := []string{}
+		for _, v := range r.ActiveVariants(map[string]string{"id": id}) {
+			ids = append(ids, v.ID)
+		}
+		if strings.Join(ids, ",") != expected {
+			t.Errorf("ActiveVariants: expected %q for %s, got %v.", expected, id, ids)
+		}
+	}
+}
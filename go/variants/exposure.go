@@ -0,0 +1,98 @@
+package variants
+
+import "sync"
+
+// An ExposureStore records the subjects enrolled in variants capped by
+// MaxExposures. Implementations backed by durable storage keep caps
+// across restarts and processes, and must be safe for concurrent use.
+type ExposureStore interface {
+	// Enroll reports whether subject is enrolled in the variant with the
+	// given ID, enrolling it first if it is not and fewer than max
+	// subjects are.
+	Enroll(variantID, subject string, max int) (bool, error)
+
+	// Enrolled reports whether subject is enrolled in the variant with
+	// the given ID, without enrolling it.
+	Enrolled(variantID, subject string) (bool, error)
+}
+
+// NewMemoryExposureStore returns an ExposureStore holding enrollments in
+// memory, the default store of a Registry.
+func NewMemoryExposureStore() ExposureStore {
+	return &memoryExposureStore{subjects: map[string]map[string]struct{}{}}
+}
+
+type memoryExposureStore struct {
+	mu       sync.Mutex
+	subjects map[string]map[string]struct{}
+}
+
+func (s *memoryExposureStore) Enroll(variantID, subject string, max int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrolled := s.subjects[variantID]
+	if _, found := enrolled[subject]; found {
+		return true, nil
+	}
+	if len(enrolled) >= max {
+		return false, nil
+	}
+	if enrolled == nil {
+		enrolled = map[string]struct{}{}
+		s.subjects[variantID] = enrolled
+	}
+	enrolled[subject] = struct{}{}
+	return true, nil
+}
+
+func (s *memoryExposureStore) Enrolled(variantID, subject string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, found := s.subjects[variantID][subject]
+	return found, nil
+}
+
+// SetExposureStore sets the store of enrollments in capped variants of
+// the DefaultRegistry.
+func SetExposureStore(store ExposureStore) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetExposureStore(store)
+}
+
+// SetExposureStore sets the store recording the subjects enrolled in
+// variants capped by MaxExposures, replacing the in-memory default.
+func (r *Registry) SetExposureStore(store ExposureStore) {
+	r.Lock()
+	defer r.Unlock()
+	r.update(func(s *snapshot) error {
+		s.exposures = store
+		return nil
+	})
+}
+
+// enrolled reports whether the subject of st's context is enrolled in v,
+// which has met its conditions, within the cap of v's MaxExposures. A
+// context without a subject is never enrolled, and neither is one that
+// the store fails to record. Dry runs only report existing enrollments.
+func (r *Registry) enrolled(v *Variant, st *evalState) bool {
+	if v.MaxExposures <= 0 {
+		return true
+	}
+	value, ok := contextValue(st.context, v.ExposureKey)
+	if !ok {
+		return false
+	}
+	subject, ok := toString(value)
+	if !ok {
+		return false
+	}
+	var enrolled bool
+	var err error
+	if st.dryRun {
+		enrolled, err = st.snap.exposures.Enrolled(v.ID, subject)
+	} else {
+		enrolled, err = st.snap.exposures.Enroll(v.ID, subject, v.MaxExposures)
+	}
+	return err == nil && enrolled
+}
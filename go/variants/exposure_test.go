@@ -0,0 +1,71 @@
+package variants
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMaxExposures(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "CappedBeta",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "max_exposures": 2,
+	    "exposure_key": "user_id",
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{map[string]string{"user_id": "a"}, true},
+		{map[string]string{"user_id": "a"}, true},
+		{map[string]string{"user_id": "b"}, true},
+		{map[string]string{"user_id": "c"}, false},
+		{map[string]string{"user_id": "a"}, true},
+		{map[string]string{"user_id": "b"}, true},
+		{nil, false},
+	}
+	for i, tc := range testCases {
+		if v := r.FlagValueWithContext("beta", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext %d: expected %v to return %t, got %v.", i, tc.Context, tc.Expected, v)
+		}
+	}
+
+	// Analyses do not enroll subjects.
+	other := NewRegistry()
+	if err := other.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v, _ := other.resolve("beta", map[string]string{"user_id": "a"}, nil); v != false {
+		t.Errorf("resolve: expected a dry run not to enroll, got %v.", v)
+	}
+	for _, id := range []string{"c", "d", "a"} {
+		other.FlagValueWithContext("beta", map[string]string{"user_id": id})
+	}
+	if v := other.FlagValueWithContext("beta", map[string]string{"user_id": "a"}); v != false {
+		t.Errorf("FlagValueWithContext: expected a to miss the cap, got %v.", v)
+	}
+}
+
+func TestMaxExposuresWithoutKey(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "CappedBeta",
+	    "max_exposures": 2,
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	err := NewRegistry().LoadJSON([]byte(config))
+	expected := fmt.Sprintf("Variant with ID %q has max exposures but no exposure key specified.", "CappedBeta")
+	if err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+	}
+}
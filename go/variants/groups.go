@@ -0,0 +1,202 @@
+package variants
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A ConditionGroup combines a list of conditions, and any nested groups,
+// with its own conditional operator. Within a group, the conditions come
+// before the nested groups.
+type ConditionGroup struct {
+	ConditionalOperator string `json:"condition_operator"`
+	Conditions          []Condition
+	Groups              []ConditionGroup `json:"groups"`
+}
+
+// Evaluate returns the result of combining the receiver's conditions
+// and nested groups with its operator, given a context.
+func (g *ConditionGroup) Evaluate(context interface{}) bool {
+	return g.evaluateWith(func(c *Condition) bool {
+		return c.Evaluate(context)
+	})
+}
+
+// evaluateWith is like Evaluate, using eval to evaluate each condition.
+func (g *ConditionGroup) evaluateWith(eval func(c *Condition) bool) bool {
+	n := len(g.Conditions)
+	return combine(g.ConditionalOperator, n+len(g.Groups), func(i int) bool {
+		if i < n {
+			return eval(&g.Conditions[i])
+		}
+		return g.Groups[i-n].evaluateWith(eval)
+	})
+}
+
+// conditions returns the conditions of the receiver and its nested groups.
+func (g *ConditionGroup) conditions() []Condition {
+	conditions := append([]Condition(nil), g.Conditions...)
+	for _, sub := range g.Groups {
+		conditions = append(conditions, sub.conditions()...)
+	}
+	return conditions
+}
+
+// allConditions returns the conditions of the receiver and its condition
+// groups.
+func (v *Variant) allConditions() []Condition {
+	conditions := append([]Condition(nil), v.Conditions...)
+	for _, g := range v.ConditionGroups {
+		conditions = append(conditions, g.conditions()...)
+	}
+	return conditions
+}
+
+// checkOperators returns an error if v or any of its condition groups has
+// an unknown conditional operator.
+func checkOperators(v *Variant) error {
+	if !validConditionalOperator(v.ConditionalOperator) {
+		return fmt.Errorf("Variant with ID %q has unknown conditional operator %q.", v.ID, v.ConditionalOperator)
+	}
+	if !validConditionalOperator(v.GroupOperator) {
+		return fmt.Errorf("Variant with ID %q has unknown group operator %q.", v.ID, v.GroupOperator)
+	}
+	return checkGroupOperators(v.ID, v.ConditionGroups)
+}
+
+// checkUnconditional returns an error unless v either has conditions or
+// is marked unconditional.
+func checkUnconditional(v *Variant) error {
+	hasConditions := len(v.allConditions()) > 0
+	if !hasConditions && !v.Unconditional {
+		return fmt.Errorf("Variant with ID %q has no conditions but is not marked unconditional.", v.ID)
+	}
+	if hasConditions && v.Unconditional {
+		return fmt.Errorf("Variant with ID %q is marked unconditional but has conditions.", v.ID)
+	}
+	return nil
+}
+
+func checkGroupOperators(variantID string, groups []ConditionGroup) error {
+	for _, g := range groups {
+		if !validConditionalOperator(g.ConditionalOperator) {
+			return fmt.Errorf("Condition group in variant %q has unknown conditional operator %q.", variantID, g.ConditionalOperator)
+		}
+		if err := checkGroupOperators(variantID, g.Groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validConditionalOperator(op string) bool {
+	switch op {
+	case "", ConditionalOperatorAnd, ConditionalOperatorOr, ConditionalOperatorNot:
+		return true
+	}
+	return false
+}
+
+// loadConditionGroups validates the operators of v's condition groups and
+// wires up the evaluators of their conditions.
+func (r *Registry) loadConditionGroups(v *Variant, opts LoadOptions) error {
+	if len(v.ConditionGroups) == 0 {
+		return nil
+	}
+	n := len(v.ConditionGroups)
+	if len(v.Conditions) > 0 {
+		n++
+	}
+	if n > 1 && len(v.GroupOperator) == 0 {
+		return fmt.Errorf("Variant with ID %q has %d condition groups but no group operator specified.", v.ID, n)
+	}
+	if !validConditionalOperator(v.GroupOperator) {
+		return fmt.Errorf("Variant with ID %q has unknown group operator %q.", v.ID, v.GroupOperator)
+	}
+	groups := make([]ConditionGroup, len(v.ConditionGroups))
+	for i, g := range v.ConditionGroups {
+		var err error
+		if groups[i], err = r.loadConditionGroup(v, g, opts); err != nil {
+			return err
+		}
+	}
+	v.ConditionGroups = groups
+	return nil
+}
+
+func (r *Registry) loadConditionGroup(v *Variant, g ConditionGroup, opts LoadOptions) (ConditionGroup, error) {
+	n := len(g.Conditions) + len(g.Groups)
+	if n > 1 && len(g.ConditionalOperator) == 0 {
+		return g, fmt.Errorf("Condition group in variant %q has %d members but no conditional operator specified.", v.ID, n)
+	}
+	if !validConditionalOperator(g.ConditionalOperator) {
+		return g, fmt.Errorf("Condition group in variant %q has unknown conditional operator %q.", v.ID, g.ConditionalOperator)
+	}
+	g.Conditions = append([]Condition(nil), g.Conditions...)
+	if err := r.wireConditions(v, g.Conditions, opts); err != nil {
+		return g, err
+	}
+	subs := make([]ConditionGroup, len(g.Groups))
+	for i, sub := range g.Groups {
+		var err error
+		if subs[i], err = r.loadConditionGroup(v, sub, opts); err != nil {
+			return g, err
+		}
+	}
+	g.Groups = subs
+	return g, nil
+}
+
+// wireConditions sets the evaluator of each of conditions, conditions of
+// v, from the registered spec of its type. It returns an error
+// if a spec rejects a condition's values, or if a condition's type is not
+// registered unless opts is lenient.
+func (r *Registry) wireConditions(v *Variant, conditions []Condition, opts LoadOptions) error {
+	r.Lock()
+	defer r.Unlock()
+	for i, c := range conditions {
+		fn, ok := r.conditionSpecs[c.Type]
+		if !ok {
+			if opts.Lenient {
+				continue
+			}
+			return &UnknownConditionTypeError{VariantID: v.ID, Type: c.Type}
+		}
+		args := c.args()
+		if saltedConditionTypes[c.Type] {
+			args = append([]interface{}{v.salt()}, args...)
+		}
+		eval, err := constructEvaluator(fn, args)
+		if err != nil {
+			return &InvalidConditionError{VariantID: v.ID, Index: i, Type: c.Type, Values: c.args(), Err: err}
+		}
+		conditions[i].Evaluator = eval
+		schema, ok := r.conditionSchemas[c.Type]
+		conditions[i].ignoresContext = ok && len(schema.Context) == 0
+		if fn, ok := reasoners[c.Type]; ok {
+			conditions[i].reasoner = fn(c.args())
+		}
+		if c.Type == conditionTypeFlagEq {
+			conditions[i].comparedFlag, _ = args[0].(string)
+			conditions[i].comparedValue = args[1]
+		}
+	}
+	return nil
+}
+
+// constructEvaluator calls the spec fn with values, reporting a nil result
+// or a panic, such as from a failed type assertion, as an error.
+func constructEvaluator(fn specFunc, values []interface{}) (eval func(interface{}) bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			eval, err = nil, fmt.Errorf("%v", p)
+		}
+	}()
+	if eval, err = fn(values...); err != nil {
+		return nil, err
+	}
+	if eval == nil {
+		return nil, errors.New("rejected by condition type")
+	}
+	return eval, nil
+}
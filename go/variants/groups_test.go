@@ -0,0 +1,253 @@
+package variants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConditionGroups(t *testing.T) {
+	r := NewRegistry()
+	// (a in [0, 9] AND b in [0, 9]) OR c in [0, 9]
+	config := `{
+	  "flag_defs": [{"flag": "grouped", "base_value": false}],
+	  "variants": [{
+	    "id": "Grouped",
+	    "group_operator": "OR",
+	    "condition_groups": [{
+	      "condition_operator": "AND",
+	      "conditions": [
+	        {"type": "MOD_RANGE", "values": ["a", 0, 9]},
+	        {"type": "MOD_RANGE", "values": ["b", 0, 9]}
+	      ]
+	    }, {
+	      "conditions": [{"type": "MOD_RANGE", "values": ["c", 0, 9]}]
+	    }],
+	    "mods": [{"flag": "grouped", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  map[string]int
+		Expected bool
+	}{
+		{map[string]int{"a": 1, "b": 1, "c": 50}, true},
+		{map[string]int{"a": 1, "b": 50, "c": 50}, false},
+		{map[string]int{"a": 50, "b": 50, "c": 1}, true},
+		{map[string]int{"a": 50, "b": 1, "c": 50}, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("grouped", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+}
+
+func TestConditionGroupsFlatConditions(t *testing.T) {
+	// Flat conditions act as one more group: c in [0, 9] AND (a in [0, 9] OR b in [0, 9]).
+	v := Variant{
+		Conditions:    []Condition{modRangeCondition("c")},
+		GroupOperator: ConditionalOperatorAnd,
+		ConditionGroups: []ConditionGroup{{
+			ConditionalOperator: ConditionalOperatorOr,
+			Conditions:          []Condition{modRangeCondition("a"), modRangeCondition("b")},
+		}},
+	}
+	if !v.Evaluate(map[string]int{"a": 50, "b": 1, "c": 1}) {
+		t.Error("Evaluate: expected flat condition and group to be met.")
+	}
+	if v.Evaluate(map[string]int{"a": 1, "b": 1, "c": 50}) {
+		t.Error("Evaluate: expected unmet flat condition to fail the variant.")
+	}
+}
+
+func TestConditionGroupsPrecedence(t *testing.T) {
+	// (A AND B) OR (C AND D), with the operand values taken from truth
+	// and each evaluation recorded in evaluated.
+	var truth [4]bool
+	var evaluated []int
+	cond := func(i int) Condition {
+		return Condition{Evaluator: func(interface{}) bool {
+			evaluated = append(evaluated, i)
+			return truth[i]
+		}}
+	}
+	v := Variant{
+		GroupOperator: ConditionalOperatorOr,
+		ConditionGroups: []ConditionGroup{{
+			ConditionalOperator: ConditionalOperatorAnd,
+			Conditions:          []Condition{cond(0), cond(1)},
+		}, {
+			ConditionalOperator: ConditionalOperatorAnd,
+			Conditions:          []Condition{cond(2), cond(3)},
+		}},
+	}
+	for bits := 0; bits < 16; bits++ {
+		for i := range truth {
+			truth[i] = bits&(1<<uint(i)) != 0
+		}
+		expected := (truth[0] && truth[1]) || (truth[2] && truth[3])
+		if met := v.Evaluate(nil); met != expected {
+			t.Errorf("Evaluate: expected %t for A, B, C, D = %v, got %t.", expected, truth, met)
+		}
+	}
+
+	testCases := []struct {
+		Truth     [4]bool
+		Evaluated []int
+	}{
+		// The first group is met, so the second is skipped.
+		{[4]bool{true, true, false, false}, []int{0, 1}},
+		// A is not met, so B is skipped.
+		{[4]bool{false, true, true, true}, []int{0, 2, 3}},
+		{[4]bool{true, false, false, true}, []int{0, 1, 2}},
+	}
+	for _, tc := range testCases {
+		truth, evaluated = tc.Truth, nil
+		v.Evaluate(nil)
+		if !reflect.DeepEqual(evaluated, tc.Evaluated) {
+			t.Errorf("Evaluate: expected conditions %v to be evaluated for %v, got %v.", tc.Evaluated, tc.Truth, evaluated)
+		}
+	}
+}
+
+func modRangeCondition(key string) Condition {
+	return Condition{
+		Type: conditionTypeModRange,
+		Evaluator: func(context interface{}) bool {
+			mod, ok := modBucket(context, key)
+			return ok && mod < 10
+		},
+	}
+}
+
+func TestConditionGroupsMissingOperator(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "grouped", "base_value": false}],
+	  "variants": [{
+	    "id": "Grouped",
+	    "condition_groups": [{
+	      "conditions": [
+	        {"type": "RANDOM", "value": 1.0},
+	        {"type": "RANDOM", "value": 1.0}
+	      ]
+	    }],
+	    "mods": [{"flag": "grouped", "value": true}]
+	  }]
+	}`
+	if err := NewRegistry().LoadJSON([]byte(config)); err == nil {
+		t.Error("LoadJSON: expected missing group operator error, but got nil.")
+	}
+}
+
+func TestAddVariantUnknownOperator(t *testing.T) {
+	r := NewRegistry()
+	if err := r.AddFlag(Flag{Name: "beta", BaseValue: false}); err != nil {
+		t.Fatalf("AddFlag: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Variant  Variant
+		Expected string
+	}{
+		{
+			Variant{ID: "Typo", ConditionalOperator: "ANDD", Mods: []Mod{{FlagName: "beta", Value: true}}},
+			`Variant with ID "Typo" has unknown conditional operator "ANDD".`,
+		},
+		{
+			Variant{ID: "GroupTypo", GroupOperator: "or", Mods: []Mod{{FlagName: "beta", Value: true}}},
+			`Variant with ID "GroupTypo" has unknown group operator "or".`,
+		},
+		{
+			Variant{
+				ID:              "NestedTypo",
+				ConditionGroups: []ConditionGroup{{Groups: []ConditionGroup{{ConditionalOperator: "XOR"}}}},
+				Mods:            []Mod{{FlagName: "beta", Value: true}},
+			},
+			`Condition group in variant "NestedTypo" has unknown conditional operator "XOR".`,
+		},
+	}
+	for _, tc := range testCases {
+		if err := r.AddVariant(tc.Variant); err == nil || err.Error() != tc.Expected {
+			t.Errorf("AddVariant: expected error %q, got %v.", tc.Expected, err)
+		}
+	}
+	if err := r.AddVariant(Variant{ID: "Single", Unconditional: true, Mods: []Mod{{FlagName: "beta", Value: true}}}); err != nil {
+		t.Errorf("AddVariant: expected no error without an operator, but got %q.", err.Error())
+	}
+}
+
+func TestNegatedConditions(t *testing.T) {
+	r := NewRegistry()
+	// a in [0, 49] AND NOT b in [0, 9]
+	config := `{
+	  "flag_defs": [{"flag": "negated", "base_value": false}],
+	  "variants": [{
+	    "id": "Negated",
+	    "condition_operator": "AND",
+	    "conditions": [
+	      {"type": "MOD_RANGE", "values": ["a", 0, 49]},
+	      {"type": "MOD_RANGE", "values": ["b", 0, 9], "negate": true}
+	    ],
+	    "mods": [{"flag": "negated", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context  map[string]int
+		Expected bool
+	}{
+		{map[string]int{"a": 1, "b": 50}, true},
+		{map[string]int{"a": 1, "b": 1}, false},
+		{map[string]int{"a": 50, "b": 50}, false},
+		// Without b, the negated condition cannot bucket and is not met.
+		{map[string]int{"a": 1}, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("negated", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+		if v := r.EvaluateAll(tc.Context)["negated"]; v != tc.Expected {
+			t.Errorf("EvaluateAll: expected %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+
+	buckets := r.BucketFor("b", map[string]int{"a": 1, "b": 1})
+	if len(buckets) != 1 || buckets[0].Active {
+		t.Errorf("BucketFor: expected the negated condition's bucket to be inactive, got %+v.", buckets)
+	}
+
+	// A negated condition is not met without the context it reads.
+	config = `{
+	  "flag_defs": [{"flag": "holdout", "base_value": false}],
+	  "variants": [{
+	    "id": "Holdout",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", 10], "negate": true}],
+	    "mods": [{"flag": "holdout", "value": true}]
+	  }]
+	}`
+	if err := r.ReloadJSON([]byte(config)); err != nil {
+		t.Fatalf("ReloadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("holdout"); v != false {
+		t.Errorf("FlagValue: expected a negated condition not to be met without a context, got %v.", v)
+	}
+	for _, context := range []interface{}{nil, map[string]interface{}{"id": 1}} {
+		if v := r.FlagValueWithContext("holdout", context); v != false {
+			t.Errorf("FlagValueWithContext: expected a negated condition not to be met with %v, got %v.", context, v)
+		}
+		if v := r.EvaluateAll(context)["holdout"]; v != false {
+			t.Errorf("EvaluateAll: expected a negated condition not to be met with %v, got %v.", context, v)
+		}
+	}
+
+	c := Condition{
+		EvaluatorErr: func(interface{}) (bool, error) { return false, ErrNoEvaluator },
+		Negate:       true,
+	}
+	if c.Evaluate(nil) {
+		t.Error("Evaluate: expected a negated condition that cannot be evaluated not to be met.")
+	}
+}
@@ -0,0 +1,79 @@
+package variants
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxContextBytes is the largest request body accepted by Handler.
+const maxContextBytes = 1 << 20
+
+// Handler returns an HTTP handler serving the flags of r to clients such
+// as web frontends. A POST to /evaluate with a JSON object of context
+// attributes as its body, which may be empty, responds with a JSON object
+// mapping the name of every client-safe flag to its value for the
+// context, as returned by EvaluateAllMode in ClientMode; server-only
+// flags are never served. A flags query parameter, such as ?flags=a,b,c, restricts the
+// response to the listed flags. A malformed context, or one larger than
+// 1 MiB, is answered with status 400, and a failed evaluation with status
+// 500.
+func Handler(r *Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/evaluate", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxContextBytes))
+		if err != nil {
+			http.Error(w, "Unable to read request body.", http.StatusBadRequest)
+			return
+		}
+		var context map[string]interface{}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &context); err != nil {
+				http.Error(w, "Invalid JSON context: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		data, err := evaluateJSON(r, context, req.URL.Query().Get("flags"))
+		if err != nil {
+			log.Printf("variants: evaluating flags: %v", err)
+			http.Error(w, "Unable to evaluate flags.", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+	return mux
+}
+
+// evaluateJSON returns the JSON encoding of the values of r's flags for
+// context, restricted to the comma-separated names in flags if any are
+// given. A panic while evaluating, such as from a condition evaluator, is
+// returned as an error.
+func evaluateJSON(r *Registry, context interface{}, flags string) (data []byte, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			data, err = nil, fmt.Errorf("%v", p)
+		}
+	}()
+	values := r.EvaluateAllMode(context, ClientMode)
+	if len(flags) > 0 {
+		selected := map[string]interface{}{}
+		for _, name := range strings.Split(flags, ",") {
+			name = strings.TrimSpace(name)
+			if v, ok := values[name]; ok {
+				selected[name] = v
+			}
+		}
+		values = selected
+	}
+	return json.Marshal(values)
+}
@@ -0,0 +1,82 @@
+package variants
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "new_nav", "base_value": false, "client_safe": true},
+	    {"flag": "color", "base_value": "blue", "client_safe": true},
+	    {"flag": "limit", "base_value": 10, "client_safe": true},
+	    {"flag": "kill_switch", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "Beta",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "new_nav", "value": true}, {"flag": "color", "value": "red"}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	h := Handler(r)
+
+	testCases := []struct {
+		Method   string
+		Target   string
+		Body     string
+		Status   int
+		Expected map[string]interface{}
+	}{
+		{"POST", "/evaluate", `{"id": "a"}`, http.StatusOK, map[string]interface{}{"new_nav": true, "color": "red", "limit": 10.0}},
+		{"POST", "/evaluate", `{"id": "b"}`, http.StatusOK, map[string]interface{}{"new_nav": false, "color": "blue", "limit": 10.0}},
+		{"POST", "/evaluate", ``, http.StatusOK, map[string]interface{}{"new_nav": false, "color": "blue", "limit": 10.0}},
+		{"POST", "/evaluate?flags=new_nav,%20limit,unknown", `{"id": "a"}`, http.StatusOK, map[string]interface{}{"new_nav": true, "limit": 10.0}},
+		{"POST", "/evaluate?flags=kill_switch", `{}`, http.StatusOK, map[string]interface{}{}},
+		{"POST", "/evaluate", `{"id": "` + strings.Repeat("a", maxContextBytes) + `"}`, http.StatusBadRequest, nil},
+		{"POST", "/evaluate", `{"id": `, http.StatusBadRequest, nil},
+		{"POST", "/evaluate", `["a"]`, http.StatusBadRequest, nil},
+		{"GET", "/evaluate", ``, http.StatusMethodNotAllowed, nil},
+		{"POST", "/flags", `{}`, http.StatusNotFound, nil},
+	}
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.Method, tc.Target, strings.NewReader(tc.Body)))
+		if w.Code != tc.Status {
+			t.Errorf("%s %s: expected status %d, got %d.", tc.Method, tc.Target, tc.Status, w.Code)
+			continue
+		}
+		if tc.Expected == nil {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: expected a JSON response, got %q.", tc.Method, tc.Target, ct)
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &values); err != nil {
+			t.Errorf("%s %s: expected a JSON object, got %q.", tc.Method, tc.Target, w.Body.String())
+		} else if !reflect.DeepEqual(values, tc.Expected) {
+			t.Errorf("%s %s %s: expected %v, got %v.", tc.Method, tc.Target, tc.Body, tc.Expected, values)
+		}
+	}
+
+	r.RegisterConditionType("PANIC", func(...interface{}) func(interface{}) bool {
+		return func(interface{}) bool { panic("secret detail") }
+	})
+	if err := r.ReloadVariants([]byte(`{"variants": [{"id": "Panic", "conditions": [{"type": "PANIC"}], "mods": [{"flag": "limit", "value": 0}]}]}`)); err != nil {
+		t.Fatalf("ReloadVariants: expected no error, but got %q.", err.Error())
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/evaluate", strings.NewReader(`{}`)))
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("POST /evaluate: expected status 500 with a safe message, got %d and %q.", w.Code, w.Body.String())
+	}
+}
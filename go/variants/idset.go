@@ -0,0 +1,175 @@
+package variants
+
+import (
+	"bufio"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ReloadIDSet rereads the ID set file at path for the DefaultRegistry.
+func ReloadIDSet(path string) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ReloadIDSet(path)
+}
+
+// ReloadIDSet rereads the ID set file at path used by any ID_SET
+// conditions, independently of reloading the config that references it.
+// If the file cannot be read, the previously loaded IDs remain in use.
+func (r *Registry) ReloadIDSet(path string) error {
+	r.idSets.mu.Lock()
+	sets := r.idSets.sets[path]
+	r.idSets.mu.Unlock()
+	for _, s := range sets {
+		if err := s.load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An idSetCache holds the ID sets loaded by ID_SET conditions mapped by
+// file path. It is guarded by its own mutex rather than the registry lock
+// since sets are created while conditions are constructed.
+type idSetCache struct {
+	mu   sync.Mutex
+	sets map[string][]*idSet
+}
+
+func newIDSetCache() *idSetCache {
+	return &idSetCache{sets: map[string][]*idSet{}}
+}
+
+// loadIDSet returns the set of IDs read from path, reusing an existing set
+// that was loaded with the same false positive rate.
+func (r *Registry) loadIDSet(path string, falsePositiveRate float64) (*idSet, error) {
+	r.idSets.mu.Lock()
+	defer r.idSets.mu.Unlock()
+	for _, s := range r.idSets.sets[path] {
+		if s.falsePositiveRate == falsePositiveRate {
+			return s, nil
+		}
+	}
+	s := &idSet{path: path, falsePositiveRate: falsePositiveRate}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	r.idSets.sets[path] = append(r.idSets.sets[path], s)
+	return s, nil
+}
+
+// An idSet is a set of IDs read from a file containing one ID per line.
+// Blank lines and lines beginning with "#" are ignored. When
+// falsePositiveRate is nonzero, the IDs are kept in a bloom filter
+// instead of an exact set.
+type idSet struct {
+	path              string
+	falsePositiveRate float64
+
+	mu    sync.RWMutex
+	exact map[string]struct{}
+	bloom *bloomFilter
+}
+
+func (s *idSet) load() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ids := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var exact map[string]struct{}
+	var bloom *bloomFilter
+	if s.falsePositiveRate > 0 {
+		bloom = newBloomFilter(len(ids), s.falsePositiveRate)
+		for _, id := range ids {
+			bloom.add(id)
+		}
+	} else {
+		exact = make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			exact[id] = struct{}{}
+		}
+	}
+
+	s.mu.Lock()
+	s.exact, s.bloom = exact, bloom
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *idSet) contains(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.bloom != nil {
+		return s.bloom.contains(id)
+	}
+	_, ok := s.exact[id]
+	return ok
+}
+
+// A bloomFilter is a fixed-size probabilistic set that may report false
+// positives but never false negatives.
+type bloomFilter struct {
+	bits   []uint64
+	hashes uint64
+}
+
+// newBloomFilter returns a bloomFilter sized to hold n items with the
+// given false positive rate.
+func newBloomFilter(n int, falsePositiveRate float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+	return &bloomFilter{
+		bits:   make([]uint64, (uint64(m)+63)/64),
+		hashes: uint64(k),
+	}
+}
+
+// locations returns the bit positions for s using double hashing.
+func (b *bloomFilter) locations(s string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32
+	size := uint64(len(b.bits)) * 64
+	locs := make([]uint64, b.hashes)
+	for i := range locs {
+		locs[i] = (h1 + uint64(i)*h2) % size
+	}
+	return locs
+}
+
+func (b *bloomFilter) add(s string) {
+	for _, l := range b.locations(s) {
+		b.bits[l/64] |= 1 << (l % 64)
+	}
+}
+
+func (b *bloomFilter) contains(s string) bool {
+	for _, l := range b.locations(s) {
+		if b.bits[l/64]&(1<<(l%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
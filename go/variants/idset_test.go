@@ -0,0 +1,98 @@
+package variants
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeIDFile(t *testing.T, path string, ids ...string) {
+	data := "# allowed accounts\n"
+	for _, id := range ids {
+		data += id + "\n"
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile: expected no error, but got %q.", err.Error())
+	}
+}
+
+func TestIDSet(t *testing.T) {
+	f, err := ioutil.TempFile("", "idset")
+	if err != nil {
+		t.Fatalf("TempFile: expected no error, but got %q.", err.Error())
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	writeIDFile(t, f.Name(), "1001", "1002")
+
+	for _, rate := range []string{"", ", 0.001"} {
+		r := NewRegistry()
+		config := fmt.Sprintf(`{
+		  "flag_defs": [{"flag": "enterprise", "base_value": false}],
+		  "variants": [{
+		    "id": "Enterprise",
+		    "conditions": [{"type": "ID_SET", "values": ["account_id", %q%s]}],
+		    "mods": [{"flag": "enterprise", "value": true}]
+		  }]
+		}`, f.Name(), rate)
+		if err := r.LoadJSON([]byte(config)); err != nil {
+			t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+		}
+
+		testCases := map[interface{}]bool{
+			"1001":         true,
+			float64(1002):  true,
+			"1003":         false,
+			"# allowed ..": false,
+		}
+		for id, expected := range testCases {
+			ctx := map[string]interface{}{"account_id": id}
+			if v := r.FlagValueWithContext("enterprise", ctx); v != expected {
+				t.Errorf("FlagValueWithContext: expected account %v to return %t, got %v.", id, expected, v)
+			}
+		}
+
+		writeIDFile(t, f.Name(), "1003")
+		if err := r.ReloadIDSet(f.Name()); err != nil {
+			t.Fatalf("ReloadIDSet: expected no error, but got %q.", err.Error())
+		}
+		if v := r.FlagValueWithContext("enterprise", map[string]string{"account_id": "1003"}); v != true {
+			t.Errorf("FlagValueWithContext: expected reloaded account to return true, got %v.", v)
+		}
+		writeIDFile(t, f.Name(), "1001", "1002")
+	}
+}
+
+func TestIDSetReloadedConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "idset")
+	if err != nil {
+		t.Fatalf("TempFile: expected no error, but got %q.", err.Error())
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	writeIDFile(t, f.Name(), "1001")
+
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(`{"flag_defs": [{"flag": "enterprise", "base_value": false}]}`)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	config := fmt.Sprintf(`{
+	  "flag_defs": [{"flag": "enterprise", "base_value": false}],
+	  "variants": [{
+	    "id": "Enterprise",
+	    "conditions": [{"type": "ID_SET", "values": ["account_id", %q]}],
+	    "mods": [{"flag": "enterprise", "value": true}]
+	  }]
+	}`, f.Name())
+	if err := r.ReloadJSON([]byte(config)); err != nil {
+		t.Fatalf("ReloadJSON: expected no error, but got %q.", err.Error())
+	}
+	writeIDFile(t, f.Name(), "1002")
+	if err := r.ReloadIDSet(f.Name()); err != nil {
+		t.Fatalf("ReloadIDSet: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValueWithContext("enterprise", map[string]string{"account_id": "1002"}); v != true {
+		t.Errorf("FlagValueWithContext: expected the ID set of a reloaded config to be reloaded, got %v.", v)
+	}
+}
@@ -0,0 +1,60 @@
+package variants
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A ValueDelta summarizes how a flag's value changes across a set of
+// sample contexts under a new config.
+type ValueDelta struct {
+	// Changed is the number of contexts whose value differs.
+	Changed int
+
+	// Transitions counts the changed contexts by direction, keyed
+	// as "old -> new" using the values' default formats.
+	Transitions map[string]int
+}
+
+// ImpactReport evaluates sampleContexts against the DefaultRegistry and newConfig.
+func ImpactReport(newConfig []byte, sampleContexts []interface{}) (map[string]ValueDelta, error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ImpactReport(newConfig, sampleContexts)
+}
+
+// ImpactReport loads newConfig, a complete JSON-encoded config, into a
+// scratch registry sharing the receiver's condition types, evaluates
+// every sample context against both, and reports per flag how many
+// contexts would change value. Flags present in only one of the configs
+// are included. The receiver is not modified, and no middleware or event
+// streams are involved. Conditions that are nondeterministic, such as
+// RANDOM, will contribute noise to the report.
+func (r *Registry) ImpactReport(newConfig []byte, sampleContexts []interface{}) (map[string]ValueDelta, error) {
+	// ID sets loaded by the new config are not kept by the receiver.
+	scratch := r.scratch()
+	scratch.idSets = newIDSetCache()
+	if err := scratch.LoadJSON(newConfig); err != nil {
+		return nil, err
+	}
+
+	report := map[string]ValueDelta{}
+	for _, reg := range []*Registry{r, scratch} {
+		for _, f := range reg.Flags() {
+			report[f.Name] = ValueDelta{Transitions: map[string]int{}}
+		}
+	}
+	for name, delta := range report {
+		for _, ctx := range sampleContexts {
+			oldVal, _ := r.resolve(name, ctx, nil)
+			newVal, _ := scratch.resolve(name, ctx, nil)
+			if reflect.DeepEqual(oldVal, newVal) {
+				continue
+			}
+			delta.Changed++
+			delta.Transitions[fmt.Sprintf("%v -> %v", oldVal, newVal)]++
+		}
+		report[name] = delta
+	}
+	return report, nil
+}
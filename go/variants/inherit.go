@@ -0,0 +1,103 @@
+package variants
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveInheritance returns variants with each variant that extends
+// another expanded to include the inherited conditions, operator, and
+// mods. Base variants are looked up first in variants, then among those
+// registered with the receiver, and then, for a scratch registry, among
+// those of the registry it was made by, so that a reloaded variant may
+// extend one already registered.
+func (r *Registry) resolveInheritance(variants []Variant) ([]Variant, error) {
+	byID := make(map[string]Variant, len(variants))
+	for _, v := range variants {
+		byID[v.ID] = v
+	}
+	resolved := map[string]Variant{}
+
+	var resolve func(id string, chain []string) (Variant, error)
+	resolve = func(id string, chain []string) (Variant, error) {
+		if v, ok := resolved[id]; ok {
+			return v, nil
+		}
+		for i, seen := range chain {
+			if seen == id {
+				cycle := append(chain[i:], id)
+				return Variant{}, fmt.Errorf("Variants form an inheritance cycle: %s.", strings.Join(cycle, " -> "))
+			}
+		}
+		v, ok := byID[id]
+		if !ok {
+			v, ok = r.load().variants[id]
+		}
+		if !ok && r.target != nil {
+			v, ok = r.target.load().variants[id]
+		}
+		if !ok {
+			return Variant{}, fmt.Errorf("Variant %q extends unknown variant %q.", chain[len(chain)-1], id)
+		}
+		if len(v.Extends) > 0 {
+			base, err := resolve(v.Extends, append(chain, id))
+			if err != nil {
+				return Variant{}, err
+			}
+			v = inherit(v, base)
+		}
+		resolved[id] = v
+		return v, nil
+	}
+
+	result := make([]Variant, len(variants))
+	for i, v := range variants {
+		var err error
+		if result[i], err = resolve(v.ID, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// inherit returns v with the conditions, conditional operator, arms, and
+// mods it does not define itself taken from base. Without conditions of its
+// own, v is unconditional if base is.
+func inherit(v, base Variant) Variant {
+	if len(v.allConditions()) == 0 && base.Unconditional {
+		v.Unconditional = true
+	}
+	if len(v.Conditions) == 0 {
+		v.Conditions = append([]Condition(nil), base.Conditions...)
+	}
+	if len(v.ConditionalOperator) == 0 {
+		v.ConditionalOperator = base.ConditionalOperator
+	}
+	if len(v.ConditionGroups) == 0 {
+		v.ConditionGroups = append([]ConditionGroup(nil), base.ConditionGroups...)
+	}
+	if len(v.GroupOperator) == 0 {
+		v.GroupOperator = base.GroupOperator
+	}
+	if len(v.Arms) == 0 {
+		v.Arms = append([]Arm(nil), base.Arms...)
+		v.ArmKey = base.ArmKey
+	}
+	mods := []Mod{}
+	for _, m := range base.Mods {
+		if !v.hasMod(m.FlagName) {
+			mods = append(mods, m)
+		}
+	}
+	v.Mods = append(mods, v.Mods...)
+	return v
+}
+
+func (v *Variant) hasMod(flagName string) bool {
+	for _, m := range v.Mods {
+		if m.FlagName == flagName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package variants
+
+import "testing"
+
+func TestInheritance(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "color", "base_value": "gray"},
+	    {"flag": "size", "base_value": "small"}
+	  ],
+	  "variants": [{
+	    "id": "Child",
+	    "extends": "Base",
+	    "mods": [{"flag": "color", "value": "blue"}]
+	  }, {
+	    "id": "Base",
+	    "condition_operator": "AND",
+	    "conditions": [
+	      {"type": "MOD_RANGE", "values": ["user_id", 0, 49]},
+	      {"type": "RANDOM", "value": 1.0}
+	    ],
+	    "mods": [{"flag": "color", "value": "red"}, {"flag": "size", "value": "large"}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	child := Variant{}
+	for _, v := range r.Variants() {
+		if v.ID == "Child" {
+			child = v
+		}
+	}
+	if len(child.Conditions) != 2 || child.ConditionalOperator != "AND" {
+		t.Errorf("LoadJSON: expected Child to inherit Base's conditions, got %+v.", child)
+	}
+	if v := child.FlagValue("color"); v != "blue" {
+		t.Errorf("FlagValue: expected Child to override color, got %v.", v)
+	}
+	if v := child.FlagValue("size"); v != "large" {
+		t.Errorf("FlagValue: expected Child to inherit size, got %v.", v)
+	}
+	if !child.Evaluate(map[string]int{"user_id": 10}) || child.Evaluate(map[string]int{"user_id": 60}) {
+		t.Error("Evaluate: expected Child's inherited conditions to be wired up.")
+	}
+}
+
+func TestInheritanceOnReload(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "color", "base_value": "gray"}],
+	  "variants": [{
+	    "id": "Base",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 49]}],
+	    "mods": [{"flag": "color", "value": "red"}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	child := `{
+	  "flag_defs": [{"flag": "color", "base_value": "gray"}],
+	  "variants": [{"id": "Child", "extends": "Base", "priority": 1, "mods": [{"flag": "color", "value": "blue"}]}]
+	}`
+	reloads := map[string]func([]byte) error{
+		"ReloadJSON":     r.ReloadJSON,
+		"ReloadVariants": r.ReloadVariants,
+	}
+	for name, reload := range reloads {
+		if err := reload([]byte(child)); err != nil {
+			t.Fatalf("%s: expected no error, but got %q.", name, err.Error())
+		}
+		if v := r.FlagValueWithContext("color", map[string]int{"user_id": 10}); v != "blue" {
+			t.Errorf("%s: expected Child to inherit Base's conditions, got %v.", name, v)
+		}
+		if v := r.FlagValueWithContext("color", map[string]int{"user_id": 60}); v != "gray" {
+			t.Errorf("%s: expected Child to inherit Base's conditions, got %v.", name, v)
+		}
+	}
+}
+
+func TestInheritanceErrors(t *testing.T) {
+	testCases := map[string]string{
+		"cycle": `{
+		  "flag_defs": [{"flag": "f", "base_value": false}],
+		  "variants": [
+		    {"id": "A", "extends": "B", "mods": [{"flag": "f", "value": true}]},
+		    {"id": "B", "extends": "A", "mods": [{"flag": "f", "value": true}]}
+		  ]
+		}`,
+		"unknown base": `{
+		  "flag_defs": [{"flag": "f", "base_value": false}],
+		  "variants": [
+		    {"id": "A", "extends": "Missing", "mods": [{"flag": "f", "value": true}]}
+		  ]
+		}`,
+	}
+	for name, config := range testCases {
+		if err := NewRegistry().LoadJSON([]byte(config)); err == nil {
+			t.Errorf("LoadJSON: expected an error for %s, but got nil.", name)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package variants
+
+import "time"
+
+// Reasons a LAUNCH condition is met, recorded with evaluation events.
+const (
+	// ReasonAllowlist means the context's ID is in the allowlist.
+	ReasonAllowlist = "allowlist"
+	// ReasonSchedule means the launch time has passed.
+	ReasonSchedule = "schedule"
+)
+
+// A launch is a parsed LAUNCH condition: on for an allowlist of IDs now,
+// and for everyone from start.
+type launch struct {
+	key   string
+	start time.Time
+	ids   map[string]struct{}
+}
+
+// parseLaunch parses the values of a LAUNCH condition: the context key of
+// the ID, the RFC 3339 launch time, and the allowlisted IDs.
+func parseLaunch(values []interface{}) (*launch, bool) {
+	if len(values) < 2 {
+		return nil, false
+	}
+	key, ok := values[0].(string)
+	if !ok {
+		return nil, false
+	}
+	s, ok := values[1].(string)
+	if !ok {
+		return nil, false
+	}
+	start, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, false
+	}
+	l := &launch{key: key, start: start, ids: make(map[string]struct{}, len(values)-2)}
+	for _, v := range values[2:] {
+		id, ok := toString(v)
+		if !ok {
+			return nil, false
+		}
+		l.ids[id] = struct{}{}
+	}
+	return l, true
+}
+
+// reason returns why the launch is on for context at now, or the empty
+// string if it is off. The allowlist takes precedence over the schedule.
+func (l *launch) reason(context interface{}, now time.Time) string {
+	if v, ok := contextValue(context, l.key); ok {
+		if id, ok := toString(v); ok {
+			if _, found := l.ids[id]; found {
+				return ReasonAllowlist
+			}
+		}
+	}
+	if !now.Before(l.start) {
+		return ReasonSchedule
+	}
+	return ""
+}
+
+// reasoners construct, given a condition's arguments, functions returning
+// why conditions of the built-in condition types that distinguish
+// activation reasons are met, given the context and the current time, or
+// the empty string if they are not. They return nil if the arguments are
+// invalid.
+var reasoners = map[string]func(args []interface{}) func(context interface{}, now time.Time) string{
+	conditionTypeLaunch: func(args []interface{}) func(context interface{}, now time.Time) string {
+		l, ok := parseLaunch(args)
+		if !ok {
+			return nil
+		}
+		return l.reason
+	},
+}
@@ -0,0 +1,70 @@
+package variants
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A languageTag holds the language, script, and region subtags of a
+// BCP 47 language tag, in canonical case. Variants, extensions, and
+// private use subtags are ignored.
+type languageTag struct {
+	language string
+	script   string
+	region   string
+}
+
+// parseLanguageTag parses tags such as "en", "en-US", "zh-Hant-TW", or
+// "es_419". It returns false if s is not a well-formed tag.
+func parseLanguageTag(s string) (languageTag, bool) {
+	subtags := strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' })
+	if len(subtags) == 0 {
+		return languageTag{}, false
+	}
+	tag := languageTag{language: strings.ToLower(subtags[0])}
+	if n := len(tag.language); n < 2 || n > 8 || !isAlpha(tag.language) {
+		return languageTag{}, false
+	}
+	rest := subtags[1:]
+	// Skip any extended language subtags.
+	for len(rest) > 0 && len(rest[0]) == 3 && isAlpha(rest[0]) {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && len(rest[0]) == 4 && isAlpha(rest[0]) {
+		tag.script = strings.ToUpper(rest[0][:1]) + strings.ToLower(rest[0][1:])
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		if r := rest[0]; (len(r) == 2 && isAlpha(r)) || (len(r) == 3 && isDigits(r)) {
+			tag.region = strings.ToUpper(r)
+		}
+	}
+	return tag, true
+}
+
+// matches reports whether t satisfies the rule tag: the languages must be
+// equal, and the rule's script and region must match when it has them.
+// A rule of "en" therefore matches "en-GB", but "en-US" does not.
+func (t languageTag) matches(rule languageTag) bool {
+	return t.language == rule.language &&
+		(len(rule.script) == 0 || t.script == rule.script) &&
+		(len(rule.region) == 0 || t.region == rule.region)
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
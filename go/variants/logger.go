@@ -0,0 +1,40 @@
+package variants
+
+// A Logger records exposures, the evaluations in which a variant supplied
+// the value of a flag, for example to audit which users were exposed to
+// an experiment. Its method is called synchronously during evaluation, so
+// it should be fast and safe for concurrent use.
+type Logger interface {
+	// LogExposure is called each time the variant with the given ID
+	// supplies value as the value of the named flag for context. The
+	// conditions the variant matched are those of the registered variant,
+	// which may be looked up by ID.
+	LogExposure(flagName, variantID string, value interface{}, context interface{})
+}
+
+// SetLogger sets the Logger recording exposures of the DefaultRegistry.
+func SetLogger(l Logger) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetLogger(l)
+}
+
+// SetLogger sets the Logger recording exposures in evaluations made
+// through FlagValueWithContext and the functions built on it. Evaluations
+// resolving a flag to its base value are not exposures and are not
+// logged. A nil Logger stops logging.
+func (r *Registry) SetLogger(l Logger) {
+	r.updateHooks(func(h *hooks) { h.logger = l })
+}
+
+// logExposure records with the receiver's Logger, if any, that the
+// variant with the given ID supplied value for the named flag, unless the
+// ID is empty.
+func (r *Registry) logExposure(flagName, variantID string, value interface{}, context interface{}) {
+	if len(variantID) == 0 {
+		return
+	}
+	if l := r.loadHooks().logger; l != nil {
+		l.LogExposure(flagName, variantID, value, context)
+	}
+}
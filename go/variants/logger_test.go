@@ -0,0 +1,50 @@
+package variants
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) LogExposure(flagName, variantID string, value interface{}, context interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf("%s %s %v %v", flagName, variantID, value, context))
+}
+
+func TestLogger(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "BetaTesters",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	// Evaluating without a logger is a no-op.
+	r.FlagValueWithContext("beta", map[string]string{"id": "a"})
+
+	l := &recordingLogger{}
+	r.SetLogger(l)
+	r.FlagValueWithContext("beta", map[string]string{"id": "a"})
+	r.FlagValueWithContext("beta", map[string]string{"id": "b"})
+	if expected := []string{"beta BetaTesters true map[id:a]"}; !reflect.DeepEqual(l.lines, expected) {
+		t.Errorf("LogExposure: expected %q, got %q.", expected, l.lines)
+	}
+
+	r.SetLogger(nil)
+	r.FlagValueWithContext("beta", map[string]string{"id": "a"})
+	if len(l.lines) != 1 {
+		t.Errorf("LogExposure: expected no logging after the logger is unset, got %q.", l.lines)
+	}
+}
@@ -0,0 +1,72 @@
+package variants
+
+// An Evaluator returns the value of the flag with the given name
+// for a context.
+type Evaluator func(name string, context interface{}) interface{}
+
+// An EvaluationMiddleware wraps an Evaluator. The returned Evaluator
+// may inspect or replace the flag name, context, or resolved value,
+// and decides whether to call next at all.
+type EvaluationMiddleware func(next Evaluator) Evaluator
+
+// hooks are the middleware, observer, and logger of a registry. Like a
+// snapshot, they are replaced rather than modified.
+type hooks struct {
+	// Middleware wrapping flag evaluation, outermost first.
+	middleware []EvaluationMiddleware
+
+	// Observer notified of flag evaluations.
+	observer Observer
+
+	// Logger recording exposures.
+	logger Logger
+}
+
+// loadHooks returns the receiver's current hooks, which must not be
+// modified.
+func (r *Registry) loadHooks() *hooks {
+	return r.hooks.Load().(*hooks)
+}
+
+// updateHooks applies fn to a copy of the receiver's hooks and swaps the
+// copy in.
+func (r *Registry) updateHooks(fn func(h *hooks)) {
+	r.Lock()
+	defer r.Unlock()
+	h := *r.loadHooks()
+	fn(&h)
+	r.hooks.Store(&h)
+}
+
+// Use adds mw to the chain of middleware wrapping flag evaluation.
+// Middleware added first is outermost: it sees each call first and
+// the resolved value last.
+func (r *Registry) Use(mw EvaluationMiddleware) {
+	r.updateHooks(func(h *hooks) {
+		h.middleware = append(h.middleware[:len(h.middleware):len(h.middleware)], mw)
+	})
+}
+
+// chain wraps eval with the receiver's middleware. No lock is held while
+// the chain runs, so middleware may safely call back into the registry.
+func (r *Registry) chain(eval Evaluator) Evaluator {
+	middleware := r.loadHooks().middleware
+	for i := len(middleware) - 1; i >= 0; i-- {
+		eval = middleware[i](eval)
+	}
+	return eval
+}
+
+// Overrides returns middleware that resolves each flag named in values
+// to the given value without evaluating any variants. Flags not present
+// in values are evaluated as usual.
+func Overrides(values map[string]interface{}) EvaluationMiddleware {
+	return func(next Evaluator) Evaluator {
+		return func(name string, context interface{}) interface{} {
+			if v, ok := values[name]; ok {
+				return v
+			}
+			return next(name, context)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package variants
+
+import "fmt"
+
+// configVersion is the version of the config format written by DumpJSON.
+// A config without a version is taken to be of version 1.
+//
+// Version 2 requires variants without conditions to be marked
+// unconditional. In version 1, such variants were always active.
+const configVersion = 2
+
+// migrations upgrade a config of version i+1 to version i+2.
+var migrations = []func(config *configFile){
+	migrateUnconditional,
+}
+
+// migrateConfig upgrades config to the current version of the config
+// format, returning an error if its version is unknown.
+func migrateConfig(config *configFile) error {
+	if config.Version == 0 {
+		config.Version = 1
+	}
+	if config.Version < 0 || config.Version > configVersion {
+		return fmt.Errorf("Config version %d is not supported; the latest supported version is %d.", config.Version, configVersion)
+	}
+	for ; config.Version < configVersion; config.Version++ {
+		migrations[config.Version-1](config)
+	}
+	return nil
+}
+
+// migrateUnconditional marks the variants of a version 1 config that
+// have no conditions of their own, and do not inherit any, unconditional.
+func migrateUnconditional(config *configFile) {
+	for i := range config.Variants {
+		v := &config.Variants[i]
+		if len(v.Extends) == 0 && len(v.allConditions()) == 0 {
+			v.Unconditional = true
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package variants
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestConfigVersion(t *testing.T) {
+	config := `{
+	  %s
+	  "flag_defs": [{"flag": "always", "base_value": false}, {"flag": "also", "base_value": false}],
+	  "variants": [{
+	    "id": "Always",
+	    "mods": [{"flag": "always", "value": true}]
+	  }, {
+	    "id": "Also",
+	    "extends": "Always",
+	    "mods": [{"flag": "also", "value": true}]
+	  }]
+	}`
+
+	// Configs of version 1, with or without a version, have always-on
+	// variants without conditions.
+	for _, version := range []string{``, `"version": 1,`} {
+		r := NewRegistry()
+		if err := r.LoadJSON([]byte(fmt.Sprintf(config, version))); err != nil {
+			t.Fatalf("LoadJSON: expected a config with version %q to load, but got %q.", version, err.Error())
+		}
+		if r.FlagValue("always") != true || r.FlagValue("also") != true {
+			t.Errorf("LoadJSON: expected the variants of a config with version %q to be unconditional.", version)
+		}
+	}
+
+	expected := `Variant with ID "Always" has no conditions but is not marked unconditional.`
+	if err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(config, `"version": 2,`))); err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q for version 2, got %v.", expected, err)
+	}
+	expected = `Config version 3 is not supported; the latest supported version is 2.`
+	if err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(config, `"version": 3,`))); err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q for version 3, got %v.", expected, err)
+	}
+
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(fmt.Sprintf(config, ``))); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	data, err := r.DumpJSON()
+	if err != nil {
+		t.Fatalf("DumpJSON: expected no error, but got %q.", err.Error())
+	}
+	var dumped configFile
+	if err := json.Unmarshal(data, &dumped); err != nil || dumped.Version != configVersion {
+		t.Errorf("DumpJSON: expected a config of version %d, got %s.", configVersion, data)
+	}
+}
@@ -0,0 +1,68 @@
+package variants
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegisterModType registers a mod type with the given ID and function
+// constructing the functions computing its values with the
+// DefaultRegistry.
+func RegisterModType(id string, fn func(values ...interface{}) func(context interface{}) interface{}) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterModType(id, fn)
+}
+
+// RegisterModType registers a mod type, for mods whose value is computed
+// from the context, with an ID unique to the set of registered mod types.
+// A mod loaded with the type is given the ValueFunc that fn returns for
+// the mod's values, as condition types construct the evaluators of
+// conditions. fn returns nil if the values are invalid, failing the load.
+// Computed values are not checked against the value type of their flag.
+func (r *Registry) RegisterModType(id string, fn func(values ...interface{}) func(context interface{}) interface{}) error {
+	r.Lock()
+	defer r.Unlock()
+	id = strings.ToUpper(id)
+	if _, found := r.modSpecs[id]; found {
+		return fmt.Errorf("Mod type with id %q already registered.", id)
+	}
+	r.modSpecs[id] = fn
+	return nil
+}
+
+// wireMods sets the ValueFunc of each of v's mods with a type from the
+// registered spec of its type.
+func (r *Registry) wireMods(v *Variant) error {
+	r.RLock()
+	defer r.RUnlock()
+	for i, m := range v.Mods {
+		if len(m.Type) == 0 {
+			continue
+		}
+		fn, ok := r.modSpecs[m.Type]
+		if !ok {
+			return fmt.Errorf("Mod of flag %q in variant %q has unknown type %q.", m.FlagName, v.ID, m.Type)
+		}
+		valueFunc, err := constructValueFunc(fn, m.Values)
+		if err != nil {
+			return fmt.Errorf("Mod of flag %q in variant %q has invalid values %v: %v", m.FlagName, v.ID, m.Values, err)
+		}
+		v.Mods[i].ValueFunc = valueFunc
+	}
+	return nil
+}
+
+// constructValueFunc calls the spec fn with values, reporting a nil
+// result or a panic as an error.
+func constructValueFunc(fn func(...interface{}) func(interface{}) interface{}, values []interface{}) (valueFunc func(interface{}) interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			valueFunc, err = nil, fmt.Errorf("%v", p)
+		}
+	}()
+	if valueFunc = fn(values...); valueFunc == nil {
+		return nil, fmt.Errorf("rejected by mod type")
+	}
+	return valueFunc, nil
+}
@@ -0,0 +1,98 @@
+package variants
+
+import "testing"
+
+func TestModType(t *testing.T) {
+	r := NewRegistry()
+	// DISCOUNT gives a percentage off, growing with the user's years of
+	// membership up to a maximum.
+	err := r.RegisterModType("DISCOUNT", func(values ...interface{}) func(interface{}) interface{} {
+		if len(values) != 2 {
+			return nil
+		}
+		perYear, perYearOK := toFloat(values[0])
+		max, maxOK := toFloat(values[1])
+		if !perYearOK || !maxOK {
+			return nil
+		}
+		return func(context interface{}) interface{} {
+			years, _ := contextValue(context, "years")
+			n, _ := toFloat(years)
+			if n*perYear > max {
+				return max
+			}
+			return n * perYear
+		}
+	})
+	if err != nil {
+		t.Fatalf("RegisterModType: expected no error, but got %q.", err.Error())
+	}
+	if err := r.RegisterModType("discount", nil); err == nil {
+		t.Error("RegisterModType: expected a duplicate mod type error, but got nil.")
+	}
+	config := `{
+	  "flag_defs": [{"flag": "discount", "base_value": 0}],
+	  "variants": [{
+	    "id": "Members",
+	    "conditions": [{"type": "NUMERIC", "values": ["years", "GTE", 1]}],
+	    "mods": [{"flag": "discount", "type": "DISCOUNT", "values": [5, 20]}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Years    float64
+		Expected float64
+	}{
+		{0, 0},
+		{1, 5},
+		{3, 15},
+		{10, 20},
+	}
+	for _, tc := range testCases {
+		ctx := map[string]interface{}{"years": tc.Years}
+		if v := r.FlagValueWithContext("discount", ctx); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %v for %v years, got %v.", tc.Expected, tc.Years, v)
+		}
+	}
+	v, _ := r.Variant("Members")
+	if value := v.FlagValueWithContext("discount", map[string]interface{}{"years": 2.0}); value != 10.0 {
+		t.Errorf("Variant.FlagValueWithContext: expected 10, got %v.", value)
+	}
+	if _, etag := r.FlagValueWithETag("discount", map[string]interface{}{"years": 2.0}); etag == r.etag("discount", map[string]interface{}{"years": 3.0}) {
+		t.Error("FlagValueWithETag: expected the ETag to cover the context of a computed value.")
+	}
+
+	malformed := map[string]string{
+		`{"flag": "discount", "type": "BOGUS"}`:                     `Mod of flag "discount" in variant "Bad" has unknown type "BOGUS".`,
+		`{"flag": "discount", "type": "DISCOUNT", "values": ["x"]}`: `Mod of flag "discount" in variant "Bad" has invalid values [x]: rejected by mod type`,
+	}
+	for mod, expected := range malformed {
+		config := `{"variants": [{"id": "Bad", "unconditional": true, "mods": [` + mod + `]}]}`
+		if err := r.LoadJSON([]byte(config)); err == nil || err.Error() != expected {
+			t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+		}
+	}
+}
+
+func TestModValueFuncRequiresContext(t *testing.T) {
+	r := NewRegistry()
+	if err := r.AddFlag(Flag{Name: "greeting", BaseValue: "hello"}); err != nil {
+		t.Fatalf("AddFlag: expected no error, but got %q.", err.Error())
+	}
+	err := r.AddVariant(Variant{
+		ID:            "Personalized",
+		Unconditional: true,
+		Mods: []Mod{{FlagName: "greeting", ValueFunc: func(context interface{}) interface{} {
+			name, _ := contextValue(context, "name")
+			return "hello " + name.(string)
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("AddVariant: expected no error, but got %q.", err.Error())
+	}
+	if !r.RequiresContext("greeting") {
+		t.Error("RequiresContext: expected a flag with a computed value to require a context.")
+	}
+}
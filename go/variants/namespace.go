@@ -0,0 +1,112 @@
+package variants
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namespaceSeparator separates a namespace from the names within it.
+const namespaceSeparator = "/"
+
+// LoadJSONNamespaced loads data into namespace ns of the DefaultRegistry.
+func LoadJSONNamespaced(ns string, data []byte) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.LoadJSONNamespaced(ns, data)
+}
+
+// LoadJSONNamespaced is like LoadJSON, but places the flags and variants
+// of data in the namespace ns, so that configs of different teams can be
+// loaded into one registry without their names colliding. Every flag
+// name and variant ID in data is prefixed with ns and a "/", so the flag
+// "checkout" of namespace "payments" is resolved as "payments/checkout".
+// References within data to flags, variants, and exclusion groups, such
+// as mods and DependsOn, are prefixed likewise, unless they already
+// contain a "/", in which case they refer to another namespace.
+func (r *Registry) LoadJSONNamespaced(ns string, data []byte) error {
+	if err := checkNamespace(ns); err != nil {
+		return err
+	}
+	return r.LoadJSONWithOptions(data, LoadOptions{Namespace: ns})
+}
+
+// namespaced returns name within ns, or name itself if it is already
+// qualified by a namespace.
+func namespaced(ns, name string) string {
+	if len(name) == 0 || strings.Contains(name, namespaceSeparator) {
+		return name
+	}
+	return ns + namespaceSeparator + name
+}
+
+// checkNamespace returns an error if ns is not a valid namespace.
+func checkNamespace(ns string) error {
+	if len(ns) == 0 || strings.Contains(ns, namespaceSeparator) {
+		return fmt.Errorf("Namespace %q must be non-empty and must not contain %q.", ns, namespaceSeparator)
+	}
+	return nil
+}
+
+// applyNamespace places the flags and variants of config in ns.
+func applyNamespace(ns string, config *configFile) error {
+	if err := checkNamespace(ns); err != nil {
+		return err
+	}
+	for i := range config.Flags {
+		f := &config.Flags[i]
+		f.Name = ns + namespaceSeparator + f.Name
+		deps := make([]string, len(f.DependsOn))
+		for j, dep := range f.DependsOn {
+			deps[j] = namespaced(ns, dep)
+		}
+		f.DependsOn = deps
+	}
+	for i := range config.Variants {
+		v := &config.Variants[i]
+		v.ID = ns + namespaceSeparator + v.ID
+		v.Extends = namespaced(ns, v.Extends)
+		v.ExclusionGroup = namespaced(ns, v.ExclusionGroup)
+		mods := make([]Mod, len(v.Mods))
+		for j, m := range v.Mods {
+			m.FlagName = namespaced(ns, m.FlagName)
+			mods[j] = m
+		}
+		v.Mods = mods
+		v.Conditions = namespaceConditions(ns, v.Conditions)
+		v.ConditionGroups = namespaceGroups(ns, v.ConditionGroups)
+	}
+	return nil
+}
+
+// namespaceConditions returns a copy of conditions whose FLAG_EQUALS
+// conditions compare flags within ns.
+func namespaceConditions(ns string, conditions []Condition) []Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]Condition, len(conditions))
+	for i, c := range conditions {
+		if c.Type == conditionTypeFlagEq {
+			args := append([]interface{}(nil), c.args()...)
+			if name, ok := args[0].(string); ok {
+				args[0] = namespaced(ns, name)
+			}
+			c.Value, c.Values = nil, args
+		}
+		result[i] = c
+	}
+	return result
+}
+
+func namespaceGroups(ns string, groups []ConditionGroup) []ConditionGroup {
+	if groups == nil {
+		return nil
+	}
+	result := make([]ConditionGroup, len(groups))
+	for i, g := range groups {
+		g.Conditions = namespaceConditions(ns, g.Conditions)
+		g.Groups = namespaceGroups(ns, g.Groups)
+		result[i] = g
+	}
+	return result
+}
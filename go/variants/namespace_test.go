@@ -0,0 +1,66 @@
+package variants
+
+import "testing"
+
+func TestLoadJSONNamespaced(t *testing.T) {
+	r := NewRegistry()
+	payments := `{
+	  "flag_defs": [
+	    {"flag": "enabled", "base_value": true},
+	    {"flag": "checkout", "base_value": "old", "depends_on": ["enabled"]}
+	  ],
+	  "variants": [{
+	    "id": "NewCheckout",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "checkout", "value": "new"}]
+	  }]
+	}`
+	search := `{
+	  "flag_defs": [
+	    {"flag": "enabled", "base_value": false},
+	    {"flag": "checkout_link", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "NewCheckout",
+	    "conditions": [{"type": "FLAG_EQUALS", "values": ["payments/checkout", "new"]}],
+	    "mods": [{"flag": "checkout_link", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSONNamespaced("payments", []byte(payments)); err != nil {
+		t.Fatalf("LoadJSONNamespaced: expected no error, but got %q.", err.Error())
+	}
+	if err := r.LoadJSONNamespaced("search", []byte(search)); err != nil {
+		t.Fatalf("LoadJSONNamespaced: expected no error, but got %q.", err.Error())
+	}
+
+	testCases := []struct {
+		Flag     string
+		Context  map[string]string
+		Expected interface{}
+	}{
+		{"payments/enabled", nil, true},
+		{"search/enabled", nil, false},
+		{"payments/checkout", map[string]string{"id": "a"}, "new"},
+		{"payments/checkout", map[string]string{"id": "b"}, "old"},
+		{"search/checkout_link", map[string]string{"id": "a"}, true},
+		{"search/checkout_link", map[string]string{"id": "b"}, false},
+		{"enabled", nil, nil},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext(tc.Flag, tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected %s to be %v for %v, got %v.", tc.Flag, tc.Expected, tc.Context, v)
+		}
+	}
+	if _, found := r.Variant("payments/NewCheckout"); !found {
+		t.Error("Variant: expected the namespaced ID payments/NewCheckout to be registered.")
+	}
+	if f, _ := r.Flag("payments/checkout"); len(f.DependsOn) != 1 || f.DependsOn[0] != "payments/enabled" {
+		t.Errorf("Flag: expected payments/checkout to depend on payments/enabled, got %v.", f.DependsOn)
+	}
+
+	for _, ns := range []string{"", "a/b"} {
+		if err := r.LoadJSONNamespaced(ns, []byte(payments)); err == nil {
+			t.Errorf("LoadJSONNamespaced: expected an error for namespace %q, got none.", ns)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package variants
+
+// An Observer is notified of flag evaluations, for example to count them
+// in a monitoring system. Its methods are called synchronously during
+// evaluation, so they should be fast and safe for concurrent use.
+type Observer interface {
+	// FlagEvaluated is called each time the named flag is evaluated.
+	FlagEvaluated(flagName string)
+
+	// VariantMatched is called each time the variant with the given ID
+	// supplies the value of the named flag.
+	VariantMatched(variantID, flagName string)
+}
+
+// SetObserver sets the Observer notified of evaluations by the
+// DefaultRegistry.
+func SetObserver(o Observer) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetObserver(o)
+}
+
+// SetObserver sets the Observer notified of flag evaluations made through
+// FlagValueWithContext and the functions built on it. A nil Observer
+// stops notifications.
+func (r *Registry) SetObserver(o Observer) {
+	r.updateHooks(func(h *hooks) { h.observer = o })
+}
+
+// observe notifies the receiver's Observer, if any, of the evaluation of
+// the named flag, whose value was supplied by the variant with the given
+// ID, or by no variant if it is empty.
+func (r *Registry) observe(flagName, variantID string) {
+	o := r.loadHooks().observer
+	if o == nil {
+		return
+	}
+	o.FlagEvaluated(flagName)
+	if len(variantID) > 0 {
+		o.VariantMatched(variantID, flagName)
+	}
+}
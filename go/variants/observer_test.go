@@ -0,0 +1,50 @@
+package variants
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type countingObserver struct {
+	mu        sync.Mutex
+	evaluated map[string]int
+	matched   map[string]int
+}
+
+func (o *countingObserver) FlagEvaluated(flagName string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.evaluated[flagName]++
+}
+
+func (o *countingObserver) VariantMatched(variantID, flagName string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.matched[variantID+"/"+flagName]++
+}
+
+func TestObserver(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	o := &countingObserver{evaluated: map[string]int{}, matched: map[string]int{}}
+	r.SetObserver(o)
+	for id := 0; id < 20; id++ {
+		r.FlagValueWithContext("mod_range", map[string]int{"user_id": id})
+	}
+
+	if expected := map[string]int{"mod_range": 20}; !reflect.DeepEqual(o.evaluated, expected) {
+		t.Errorf("FlagEvaluated: expected counts %v, got %v.", expected, o.evaluated)
+	}
+	if expected := map[string]int{"ModRangeTest/mod_range": 10}; !reflect.DeepEqual(o.matched, expected) {
+		t.Errorf("VariantMatched: expected counts %v, got %v.", expected, o.matched)
+	}
+
+	r.SetObserver(nil)
+	r.FlagValue("mod_range")
+	if o.evaluated["mod_range"] != 20 {
+		t.Errorf("SetObserver: expected no notifications after removing the observer, got %d.", o.evaluated["mod_range"])
+	}
+}
@@ -0,0 +1,152 @@
+package variants
+
+// evalState holds the inputs to, and results shared within, a single
+// evaluation call.
+type evalState struct {
+	// Snapshot of the registry the evaluation reads.
+	snap *snapshot
+
+	context        interface{}
+	forcedVariants map[string]bool
+
+	// Winning variant IDs of the exclusion groups evaluated so far.
+	groupWinners map[string]string
+
+	// Results of the variants evaluated so far mapped by ID, if they
+	// are cached, possibly shared with other evaluations made for the
+	// same caller.
+	results map[string]bool
+
+	// Buckets computed so far, possibly shared with other evaluations
+	// made for the same caller.
+	buckets bucketMemo
+
+	// Reasons variants evaluated so far were activated, mapped by
+	// variant ID, for conditions that distinguish them.
+	reasons map[string]string
+
+	// Whether the evaluation is an analysis, such as a replay, that must
+	// not enroll subjects in capped variants.
+	dryRun bool
+
+	// Whether to record an error in err when a variant is evaluated
+	// with a condition that has no evaluator.
+	checkConditions bool
+	err             error
+
+	// Names of the flags whose prerequisites are being resolved, to
+	// guard against dependency cycles.
+	resolving map[string]bool
+
+	// ID of the variant supplying the value of the flag evaluated, or
+	// empty if the base value was used.
+	variantID string
+
+	// Whether the flag evaluated was resolved, rather than its value
+	// supplied by middleware.
+	resolved bool
+}
+
+// isActive reports whether v is active in st, honoring forced variants
+// and exclusion groups.
+func (r *Registry) isActive(v Variant, st *evalState) bool {
+	if forced, found := st.forcedVariants[v.ID]; found {
+		return forced
+	}
+	if !v.IsEnabled() {
+		return false
+	}
+	if len(v.ExclusionGroup) > 0 {
+		return r.groupWinner(v.ExclusionGroup, st) == v.ID
+	}
+	return r.evaluateVariant(v, st)
+}
+
+// evaluateVariant returns the result of evaluating v's conditions with
+// st's context, enrolling the context's subject if v is capped. Results
+// are cached in st when it holds a cache.
+func (r *Registry) evaluateVariant(v Variant, st *evalState) bool {
+	if result, found := st.results[v.ID]; found {
+		return result
+	}
+	if st.checkConditions && st.err == nil {
+		for _, c := range v.allConditions() {
+			if !c.hasEvaluator() {
+				st.err = &UnknownConditionTypeError{VariantID: v.ID, Type: c.Type}
+				break
+			}
+		}
+	}
+	result := v.evaluateWith(func(c *Condition) bool {
+		return r.evaluateCondition(c, &v, st)
+	}) && v.hasArm(st.context) && r.enrolled(&v, st)
+	if st.results != nil {
+		st.results[v.ID] = result
+	}
+	return result
+}
+
+// evaluateCondition returns whether c, a condition of v, is met with st's
+// context. Bucketing conditions reuse the buckets memoized in st, and the
+// reasons conditions are met are recorded in st, as are the errors of
+// conditions that cannot be evaluated when st checks conditions. Negated
+// conditions record no reason, and negated bucketing conditions are not
+// met by a context lacking their key. FLAG_EQUALS conditions resolve the
+// compared flag with st, reading its snapshot, without enrolling subjects
+// in a dry run, and without the hooks of a flag evaluation.
+func (r *Registry) evaluateCondition(c *Condition, v *Variant, st *evalState) bool {
+	if !c.hasEvaluator() {
+		return false
+	}
+	if len(c.comparedFlag) > 0 {
+		if c.Negate && st.context == nil {
+			return false
+		}
+		val, _ := r.resolveSnapshot(c.comparedFlag, st)
+		return flagValuesEqual(val, c.comparedValue) != c.Negate
+	}
+	if c.reasoner != nil && !c.Negate {
+		reason := c.reasoner(st.context, r.now())
+		if reason != "" {
+			if st.reasons == nil {
+				st.reasons = map[string]string{}
+			}
+			st.reasons[v.ID] = reason
+		}
+		return reason != ""
+	}
+	fn, ok := bucketers[c.Type]
+	if !ok || (st.buckets == nil && !c.Negate) {
+		met, err := c.EvaluateErr(st.context)
+		if err != nil && st.checkConditions && st.err == nil {
+			st.err = conditionError(v.ID, c, err)
+		}
+		return err == nil && met
+	}
+	b, ok := fn(c.args(), v, st.context, st.buckets)
+	return ok && b.Active != c.Negate
+}
+
+// groupWinner returns the ID of the highest-priority active variant of
+// the named exclusion group, or an empty string if none are active.
+// Variants are evaluated in priority order and evaluation stops at the
+// first active one, so the conditions of lower-priority variants are not
+// evaluated once a winner is found.
+func (r *Registry) groupWinner(group string, st *evalState) string {
+	if winner, ok := st.groupWinners[group]; ok {
+		return winner
+	}
+	winner := ""
+	for _, v := range st.snap.sortedVariants(st.snap.exclusionGroups[group]) {
+		forced, found := st.forcedVariants[v.ID]
+		if (found && forced) || (!found && v.IsEnabled() && r.evaluateVariant(v, st)) {
+			winner = v.ID
+			break
+		}
+	}
+	if st.groupWinners == nil {
+		st.groupWinners = map[string]string{}
+	}
+	st.groupWinners[group] = winner
+	return winner
+}
@@ -0,0 +1,92 @@
+package variants
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExclusionGroupShortCircuit(t *testing.T) {
+	r := NewRegistry()
+	evaluated := map[string]int{}
+	r.RegisterConditionType("TRACKED", func(values ...interface{}) func(interface{}) bool {
+		name, result := values[0].(string), values[1].(bool)
+		return func(interface{}) bool {
+			evaluated[name]++
+			return result
+		}
+	})
+	config := `{
+	  "flag_defs": [
+	    {"flag": "checkout", "base_value": "control"},
+	    {"flag": "banner", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "High",
+	    "priority": 10,
+	    "exclusion_group": "checkout_tests",
+	    "conditions": [{"type": "TRACKED", "values": ["high", true]}],
+	    "mods": [{"flag": "checkout", "value": "high"}]
+	  }, {
+	    "id": "Low",
+	    "priority": 1,
+	    "exclusion_group": "checkout_tests",
+	    "conditions": [{"type": "TRACKED", "values": ["low", true]}],
+	    "mods": [{"flag": "checkout", "value": "low"}, {"flag": "banner", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+
+	if v := r.FlagValue("checkout"); v != "high" {
+		t.Errorf("FlagValue: expected the higher-priority variant to win, got %v.", v)
+	}
+	if v := r.FlagValue("banner"); v != false {
+		t.Errorf("FlagValue: expected excluded variant not to modify banner, got %v.", v)
+	}
+	if evaluated["low"] != 0 {
+		t.Errorf("FlagValue: expected lower-priority conditions not to be evaluated, but they were evaluated %d times.", evaluated["low"])
+	}
+
+	forced := map[string]bool{"High": false}
+	if v := r.FlagValueWithContextWithForcedVariants("banner", nil, forced); v != true {
+		t.Errorf("FlagValueWithContextWithForcedVariants: expected Low to win when High is forced off, got %v.", v)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "color", "base_value": "gray"}],
+	  "variants": [{
+	    "id": "Blue",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "color", "value": "blue"}]
+	  }, {
+	    "id": "Amber",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "color", "value": "amber"}]
+	  }, {
+	    "id": "Red",
+	    "priority": %d,
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "color", "value": "red"}]
+	  }]
+	}`
+	testCases := map[int]string{
+		// Without a priority, ties are broken by ID.
+		0:  "amber",
+		1:  "red",
+		-1: "amber",
+	}
+	for priority, expected := range testCases {
+		r := NewRegistry()
+		if err := r.LoadJSON([]byte(fmt.Sprintf(config, priority))); err != nil {
+			t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+		}
+		for i := 0; i < 10; i++ {
+			if v := r.FlagValue("color"); v != expected {
+				t.Fatalf("FlagValue: expected color to be %q with Red at priority %d, got %v.", expected, priority, v)
+			}
+		}
+	}
+}
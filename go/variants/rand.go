@@ -0,0 +1,43 @@
+package variants
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// A randSource holds the source of randomness of RANDOM conditions.
+// Since a *rand.Rand is not safe for concurrent use, draws from it are
+// serialized.
+type randSource struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// SetRand sets the source of randomness used by RANDOM conditions of the
+// DefaultRegistry.
+func SetRand(rng *rand.Rand) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetRand(rng)
+}
+
+// SetRand sets the source of randomness used by RANDOM conditions whose
+// context is not a RandContext, so that tests can seed it to make their
+// outcomes reproducible. A nil rng restores the global source of
+// math/rand. Draws from rng are serialized, so it must not be used
+// elsewhere while the registry uses it.
+func (r *Registry) SetRand(rng *rand.Rand) {
+	r.rng.mu.Lock()
+	defer r.rng.mu.Unlock()
+	r.rng.rng = rng
+}
+
+// float64 returns a random number in [0.0, 1.0) from the source.
+func (s *randSource) float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rng == nil {
+		return rand.Float64()
+	}
+	return s.rng.Float64()
+}
@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // A Registry keeps track of all Flags, Conditions, and Variants.
@@ -14,27 +15,99 @@ type Registry struct {
 	// This mutex protects the fields below.
 	sync.RWMutex
 
-	// Currently registered variants mapped by ID.
-	variants map[string]Variant
+	// Current *snapshot of the registered flags and variants, read
+	// without the lock and replaced as a whole by writers holding it.
+	snap atomic.Value
 
 	// Registered condition specs mapped on type. Specs create condition functions.
-	conditionSpecs map[string]func(...interface{}) func(interface{}) bool
+	conditionSpecs map[string]specFunc
 
-	// Registered variant flags mapped by name.
-	flags map[string]Flag
+	// Functions of the registered condition specs that are given the
+	// registry mapped on type. Their specs are bound to the receiver when
+	// copied to a scratch registry, and to the copy by Clone.
+	contextualSpecs map[string]func(*Registry, ...interface{}) func(interface{}) bool
 
-	// Maps flag names to a set of variant IDs. Used to evaluate flag values.
-	flagToVariantIDMap map[string]map[string]struct{}
+	// Schemas of registered condition types mapped on type.
+	conditionSchemas map[string]ConditionSchema
+
+	// Values of the conditions of registered types mapped on type, for
+	// the types that describe them.
+	conditionArgs map[string][]ConditionArg
+
+	// Initialization of registered condition specs that require it.
+	specInits []*specInit
+
+	// Registered mod specs mapped on type. Specs create functions
+	// computing mod values.
+	modSpecs map[string]func(...interface{}) func(interface{}) interface{}
+
+	// Registered decoders for flag and mod values mapped by flag name.
+	flagDecoders map[string]func(json.RawMessage) (interface{}, error)
+
+	// Middleware, observer, and logger called around evaluations,
+	// published like the snapshot so that evaluations read them without
+	// taking the registry lock.
+	hooks atomic.Value // *hooks
+
+	// Callbacks notified of flags changed by reloads.
+	changeHandlers []func(flagName string)
+
+	// Key used to sign and verify assignment tokens.
+	tokenKey []byte
+
+	// Whether every load, reload, and added variant is checked as with
+	// LoadOptions.StrictTypes. Shared with scratch registries.
+	strictTypes bool
+
+	// Clock of time-based conditions, shared with scratch registries so
+	// that their conditions follow SetClock on the receiver.
+	clock *clock
+
+	// Source of randomness of RANDOM conditions, shared with scratch
+	// registries like the clock.
+	rng *randSource
+
+	// For a scratch registry, the registry it was made by, whose
+	// variants those of the scratch registry may extend.
+	target *Registry
+
+	// ID sets loaded by ID_SET conditions, shared with scratch registries
+	// like the clock, so that ReloadIDSet refreshes the sets of reloaded
+	// configs.
+	idSets *idSetCache
+
+	// Open event streams. Changed under streamsMu, and published in
+	// streams so that evaluations find none without locking. Events are
+	// sent under a read lock of streamsMu, so that Close does not close
+	// a stream during a send.
+	streamsMu     sync.RWMutex
+	streams       atomic.Value // []chan EvalEvent
+	closed        bool
+	droppedEvents uint64
 }
 
 // NewRegistry allocates and returns a new Registry.
 func NewRegistry() *Registry {
 	r := &Registry{
+		conditionSpecs:   map[string]specFunc{},
+		conditionSchemas: map[string]ConditionSchema{},
+		conditionArgs:    map[string][]ConditionArg{},
+		contextualSpecs:  map[string]func(*Registry, ...interface{}) func(interface{}) bool{},
+		idSets:           newIDSetCache(),
+		modSpecs:         map[string]func(...interface{}) func(interface{}) interface{}{},
+		flagDecoders:     map[string]func(json.RawMessage) (interface{}, error){},
+		clock:            &clock{},
+		rng:              &randSource{},
+	}
+	r.snap.Store(&snapshot{
 		variants:           map[string]Variant{},
-		conditionSpecs:     map[string]func(...interface{}) func(interface{}) bool{},
 		flags:              map[string]Flag{},
 		flagToVariantIDMap: map[string]map[string]struct{}{},
-	}
+		exclusionGroups:    map[string]map[string]struct{}{},
+		exposures:          NewMemoryExposureStore(),
+	})
+	r.hooks.Store(&hooks{})
+	r.streams.Store([]chan EvalEvent(nil))
 	r.registerBuiltInConditionTypes()
 	return r
 }
@@ -59,6 +132,13 @@ func AddFlag(f Flag) error {
 	return DefaultRegistry.AddFlag(f)
 }
 
+// AddFlags adds fs to the DefaultRegistry, or none of them on error.
+func AddFlags(fs []Flag) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.AddFlags(fs)
+}
+
 // FlagValue returns the value of a flag with the given name from the DefaultRegistry.
 func FlagValue(name string) interface{} {
 	defaultRegistryMu.RLock()
@@ -74,6 +154,31 @@ func FlagValueWithContext(name string, context interface{}) interface{} {
 	return DefaultRegistry.FlagValueWithContext(name, context)
 }
 
+// FlagValueOr returns the value of the flag with the given name from the
+// DefaultRegistry, or fallback if it is not registered.
+func FlagValueOr(name string, fallback interface{}) interface{} {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.FlagValueOr(name, fallback)
+}
+
+// FlagValueWithContextOr returns the value of the flag with the given name
+// and context from the DefaultRegistry, or fallback if it is not
+// registered.
+func FlagValueWithContextOr(name string, context interface{}, fallback interface{}) interface{} {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.FlagValueWithContextOr(name, context, fallback)
+}
+
+// IsRegistered reports whether a flag with the given name is registered
+// with the DefaultRegistry.
+func IsRegistered(name string) bool {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.IsRegistered(name)
+}
+
 // FlagValueWithContextWithForcedVariants returns the value of the flag with the given name and
 // context from the DefaultRegistry. Potentially forcing a variant on or off.
 func FlagValueWithContextWithForcedVariants(
@@ -86,13 +191,22 @@ func FlagValueWithContextWithForcedVariants(
 	return DefaultRegistry.FlagValueWithContextWithForcedVariants(name, context, forcedVariants)
 }
 
-// Flags returns all Flags registered with the DefaultRegistry.
+// Flags returns all Flags registered with the DefaultRegistry, ordered by
+// name.
 func Flags() []Flag {
 	defaultRegistryMu.RLock()
 	defer defaultRegistryMu.RUnlock()
 	return DefaultRegistry.Flags()
 }
 
+// GetFlag returns the flag with the given name registered with the
+// DefaultRegistry, and whether it was found.
+func GetFlag(name string) (Flag, bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.Flag(name)
+}
+
 // AddVariant adds v to the DefaultRegistry.
 func AddVariant(v Variant) error {
 	defaultRegistryMu.RLock()
@@ -100,13 +214,36 @@ func AddVariant(v Variant) error {
 	return DefaultRegistry.AddVariant(v)
 }
 
-// Variants returns all variants registered within the DefaultRegistry.
+// AddVariants adds vs to the DefaultRegistry, or none of them on error.
+func AddVariants(vs []Variant) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.AddVariants(vs)
+}
+
+// AddBatch adds fs and vs to the DefaultRegistry, or none of them on error.
+func AddBatch(fs []Flag, vs []Variant) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.AddBatch(fs, vs)
+}
+
+// Variants returns all variants registered within the DefaultRegistry,
+// ordered by ID.
 func Variants() []Variant {
 	defaultRegistryMu.RLock()
 	defer defaultRegistryMu.RUnlock()
 	return DefaultRegistry.Variants()
 }
 
+// GetVariant returns the variant with the given ID registered within the
+// DefaultRegistry, and whether it was found.
+func GetVariant(id string) (Variant, bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.Variant(id)
+}
+
 // RegisterConditionType registers a Condition type with the given ID
 // and evaluating function with the DefaultRegistry.
 func RegisterConditionType(id string, fn func(...interface{}) func(interface{}) bool) error {
@@ -115,6 +252,15 @@ func RegisterConditionType(id string, fn func(...interface{}) func(interface{})
 	return DefaultRegistry.RegisterConditionType(id, fn)
 }
 
+// RegisterConditionTypeErr registers a Condition type with the given ID
+// and evaluating function, which reports invalid values with an error,
+// with the DefaultRegistry.
+func RegisterConditionTypeErr(id string, fn func(...interface{}) (func(interface{}) bool, error)) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterConditionTypeErr(id, fn)
+}
+
 // LoadConfig loads filename, a JSON-encoded set of Mods, Conditions, and Variants,
 // with the DefaultRegistry.
 func LoadConfig(filename string) error {
@@ -131,6 +277,14 @@ func LoadJSON(data []byte) error {
 	return DefaultRegistry.LoadJSON(data)
 }
 
+// LoadJSONWithOptions loads data, a JSON-encoded set of Mods, Conditions,
+// and Variants, with the DefaultRegistry as directed by opts.
+func LoadJSONWithOptions(data []byte, opts LoadOptions) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.LoadJSONWithOptions(data, opts)
+}
+
 // ReloadConfig reloads the given filename config into the DefaultRegistry.
 func ReloadConfig(filename string) error {
 	defaultRegistryMu.RLock()
@@ -138,6 +292,22 @@ func ReloadConfig(filename string) error {
 	return DefaultRegistry.ReloadConfig(filename)
 }
 
+// ReloadFlags reloads only the flag definitions of the given JSON-encoded
+// config into the DefaultRegistry.
+func ReloadFlags(data []byte) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ReloadFlags(data)
+}
+
+// ReloadVariants reloads only the variants of the given JSON-encoded
+// config into the DefaultRegistry.
+func ReloadVariants(data []byte) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ReloadVariants(data)
+}
+
 // ReloadJSON reloads the given JSON-encoded byte slice into the DefaultRegistry.
 func ReloadJSON(data []byte) error {
 	defaultRegistryMu.RLock()
@@ -145,17 +315,44 @@ func ReloadJSON(data []byte) error {
 	return DefaultRegistry.ReloadJSON(data)
 }
 
+// ReloadJSONMode reloads the given JSON-encoded byte slice into the
+// DefaultRegistry with the given mode.
+func ReloadJSONMode(data []byte, mode ReloadMode) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ReloadJSONMode(data, mode)
+}
+
+// Use adds mw to the evaluation middleware of the DefaultRegistry.
+func Use(mw EvaluationMiddleware) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.Use(mw)
+}
+
 // AddFlag registers a new flag, returning an error if a flag already
-// exists with the same name.
+// exists with the same name or f depends on a flag not registered.
 func (r *Registry) AddFlag(f Flag) error {
 	r.Lock()
 	defer r.Unlock()
-	if _, present := r.flags[f.Name]; present {
-		return fmt.Errorf("Variant flag with the name %q is already registered.", f.Name)
-	}
-	r.flags[f.Name] = f
-	r.flagToVariantIDMap[f.Name] = map[string]struct{}{}
-	return nil
+	return r.update(func(s *snapshot) error {
+		if err := s.addFlag(f); err != nil {
+			return err
+		}
+		return s.checkDependencies([]string{f.Name}, nil)
+	})
+}
+
+// AddFlags registers each of fs as AddFlag does, in a single swap. The
+// whole batch is validated first, each flag against those registered and
+// those before it in fs, so that on error none of fs is registered. A
+// flag may depend on flags registered or anywhere in fs.
+func (r *Registry) AddFlags(fs []Flag) error {
+	r.Lock()
+	defer r.Unlock()
+	return r.update(func(s *snapshot) error {
+		return s.addFlags(fs)
+	})
 }
 
 // FlagValue returns the value of a flag based on a nil context.
@@ -164,215 +361,677 @@ func (r *Registry) FlagValue(name string) interface{} {
 }
 
 // FlagValueWithContext returns the value of a flag based on a given context object.
-// The first variant that is satisfied and has a mod associated with the given flag name
-// will be evaluated. The order of variant evaluation is nondeterministic.
+// Variants with a mod associated with the given flag name are evaluated in descending
+// priority order, ties broken by ascending ID, and the first one satisfied supplies the value.
 func (r *Registry) FlagValueWithContext(name string, context interface{}) interface{} {
 	return r.FlagValueWithContextWithForcedVariants(name, context, nil)
 }
 
+// FlagValueOr is like FlagValue, but returns fallback if no flag with the
+// given name is registered, so that an unknown flag can be told apart from
+// a flag whose value is nil.
+func (r *Registry) FlagValueOr(name string, fallback interface{}) interface{} {
+	return r.FlagValueWithContextOr(name, nil, fallback)
+}
+
+// FlagValueWithContextOr is like FlagValueWithContext, but returns
+// fallback if no flag with the given name is registered. A registered
+// flag's value is returned even if it is nil. The flag is looked up and
+// evaluated in the same snapshot, so that a concurrent reload removing it
+// cannot turn the value into nil rather than the flag's value or fallback.
+func (r *Registry) FlagValueWithContextOr(name string, context interface{}, fallback interface{}) interface{} {
+	s := r.load()
+	if _, found := s.flags[name]; !found {
+		return fallback
+	}
+	return r.evaluate(name, context, &evalState{snap: s})
+}
+
+// IsRegistered reports whether a flag with the given name is registered.
+func (r *Registry) IsRegistered(name string) bool {
+	_, found := r.load().flags[name]
+	return found
+}
+
 // FlagValueWithContextWithForcedVariants returns the value of a flag based on a given context object.
-// The first variant that is satisfied and has a mod associated with the given flag name
-// will be evaluated. The order of variant evaluation is nondeterministic. A forced variant
-// can "force" the value of the flag to returned or ignored.
-// TODO(andybons): Deterministic behavior through rule ordering.
+// Variants with a mod associated with the given flag name are evaluated in descending
+// priority order, ties broken by ascending ID, and the first one satisfied supplies the value.
+// A forced variant can "force" the value of the flag to returned or ignored.
+// Evaluation passes through any middleware registered with Use.
 func (r *Registry) FlagValueWithContextWithForcedVariants(
 	name string,
 	context interface{},
 	forcedVariants map[string]bool,
 ) interface{} {
-	r.RLock()
-	defer r.RUnlock()
-	val := r.flags[name].BaseValue
-	for variantID := range r.flagToVariantIDMap[name] {
-		variant := r.variants[variantID]
+	return r.evaluate(name, context, &evalState{forcedVariants: forcedVariants})
+}
+
+// evaluate resolves the named flag through the receiver's middleware. The
+// options of opts, such as forced variants, apply to every resolution made
+// by the chain, and the first error recorded by any of them is set on opts,
+// along with the ID of the variant supplying the named flag's value. If
+// opts has a snapshot, every resolution reads it. If opts is a dry run,
+// no subject is enrolled and the observer, logger, and event streams are
+// not called.
+func (r *Registry) evaluate(flagName string, context interface{}, opts *evalState) interface{} {
+	eval := Evaluator(func(name string, context interface{}) interface{} {
+		st := &evalState{
+			snap:            opts.snap,
+			context:         context,
+			forcedVariants:  opts.forcedVariants,
+			checkConditions: opts.checkConditions,
+			dryRun:          opts.dryRun,
+		}
+		if sameContext(context, opts.context) {
+			st.groupWinners, st.results = opts.groupWinners, opts.results
+			st.buckets, st.reasons = opts.buckets, opts.reasons
+		}
+		val, variantID := r.resolveState(name, st)
+		if opts.err == nil {
+			opts.err = st.err
+		}
+		if name == flagName {
+			opts.variantID = variantID
+			opts.resolved = true
+		}
+		if opts.dryRun {
+			return val
+		}
+		r.observe(name, variantID)
+		r.logExposure(name, variantID, val, context)
+		r.emit(EvalEvent{
+			Flag:      name,
+			Context:   context,
+			Value:     val,
+			VariantID: variantID,
+			Reason:    st.reasons[variantID],
+		})
+		return val
+	})
+	return r.chain(eval)(flagName, context)
+}
 
-		forcedVal, found := forcedVariants[variantID]
-		forcedOn := found && forcedVal == true
-		forcedOff := found && forcedVal == false
+// resolve evaluates the variants modifying the named flag without
+// passing through any middleware, as a dry run for analyses. It returns
+// the flag's value and the ID of the variant that supplied it, or an
+// empty ID if the base value was used.
+func (r *Registry) resolve(name string, context interface{}, forcedVariants map[string]bool) (interface{}, string) {
+	return r.resolveState(name, &evalState{
+		context:        context,
+		forcedVariants: forcedVariants,
+		dryRun:         true,
+	})
+}
 
-		if !forcedOff && (forcedOn || variant.Evaluate(context)) {
-			val = variant.FlagValue(name)
+// resolveState is like resolve, taking its inputs from st. It reads st's
+// snapshot, or else the receiver's current one, without taking its lock.
+func (r *Registry) resolveState(name string, st *evalState) (interface{}, string) {
+	if st.snap == nil {
+		st.snap = r.load()
+	}
+	st.context = st.snap.mergeDefaultContext(st.context)
+	return r.resolveSnapshot(name, st)
+}
+
+// resolveSnapshot is like resolveState, with st's snapshot loaded and its
+// context already merged over the default context. A flag whose
+// prerequisites are unmet resolves to its base value. An active variant
+// supplying no value, as when split into arms for a context without its
+// arm key, is passed over.
+func (r *Registry) resolveSnapshot(name string, st *evalState) (interface{}, string) {
+	s := st.snap
+	if !r.prerequisitesMet(name, st) {
+		return s.flags[name].BaseValue, ""
+	}
+	if c := s.flags[name].Combine; c == CombineAppend || c == CombineUnion {
+		return r.combineValues(name, st)
+	}
+	for _, variant := range s.flagVariants[name] {
+		if !r.isActive(variant, st) {
+			continue
+		}
+		if val, ok := variant.modValue(name, st.context); ok {
+			return val, variant.ID
 		}
 	}
-	return val
+	return s.flags[name].BaseValue, ""
 }
 
-// Flags returns all flags registered with the receiver.
+// Flags returns all flags registered with the receiver, ordered by name.
 func (r *Registry) Flags() []Flag {
-	r.RLock()
-	defer r.RUnlock()
-	result := make([]Flag, len(r.flags))
-	i := 0
-	for _, f := range r.flags {
-		result[i] = f
-		i++
+	return r.load().flagList()
+}
+
+// flagList returns the receiver's flags, ordered by name.
+func (s *snapshot) flagList() []Flag {
+	result := make([]Flag, 0, len(s.flags))
+	for _, f := range s.flags {
+		result = append(result, f)
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
 	return result
 }
 
+// Flag returns the flag with the given name, and whether it is registered.
+func (r *Registry) Flag(name string) (Flag, bool) {
+	f, found := r.load().flags[name]
+	return f, found
+}
+
 // AddVariant registers a new variant, returning an error if the flag
-// already exists with the same Id or the flag name within any of the variant's
-// mods is not registered.
+// already exists with the same Id, the flag name within any of the variant's
+// mods is not registered, or any of its conditional operators is unknown.
 func (r *Registry) AddVariant(v Variant) error {
 	r.Lock()
 	defer r.Unlock()
-	if _, found := r.variants[v.ID]; found {
-		return fmt.Errorf("Variant already registered with the ID %q", v.ID)
-	}
+	return r.update(func(s *snapshot) error {
+		if err := s.addNewVariant(v); err != nil {
+			return err
+		}
+		return r.checkStrictTypes(s, []Variant{v})
+	})
+}
 
-	for _, m := range v.Mods {
-		if _, found := r.flags[m.FlagName]; !found {
-			return fmt.Errorf("Flag with the name %q has not been registered.", m.FlagName)
+// AddVariants registers each of vs as AddVariant does, in a single swap.
+// The whole batch is validated first, each variant against the flags and
+// variants registered and those before it in vs, so that on error none
+// of vs is registered.
+func (r *Registry) AddVariants(vs []Variant) error {
+	r.Lock()
+	defer r.Unlock()
+	return r.update(func(s *snapshot) error {
+		if err := s.addNewVariants(vs); err != nil {
+			return err
 		}
-		r.flagToVariantIDMap[m.FlagName][v.ID] = struct{}{}
-	}
-	r.variants[v.ID] = v
-	return nil
+		return r.checkStrictTypes(s, vs)
+	})
+}
+
+// AddBatch registers fs as AddFlags does and then vs as AddVariants does,
+// in a single swap, so that variants may modify flags of the same batch.
+// On error, none of fs or vs is registered.
+func (r *Registry) AddBatch(fs []Flag, vs []Variant) error {
+	r.Lock()
+	defer r.Unlock()
+	return r.update(func(s *snapshot) error {
+		if err := s.addFlags(fs); err != nil {
+			return err
+		}
+		if err := s.addNewVariants(vs); err != nil {
+			return err
+		}
+		return r.checkStrictTypes(s, vs)
+	})
 }
 
-// Variants returns a slice of all variants registered with the receiver.
+// Variants returns a slice of all variants registered with the receiver,
+// ordered by ID. The variants share their Mods, Conditions, and other
+// slices with the receiver, so they must be treated as read-only; use
+// GetVariantView to inspect a variant safely.
 func (r *Registry) Variants() []Variant {
-	r.RLock()
-	defer r.RUnlock()
-	result := make([]Variant, len(r.variants))
-	i := 0
-	for _, v := range r.variants {
-		result[i] = v
-		i++
+	return r.load().variantList()
+}
+
+// variantList returns the receiver's variants, ordered by ID.
+func (s *snapshot) variantList() []Variant {
+	result := make([]Variant, 0, len(s.variants))
+	for _, v := range s.variants {
+		result = append(result, v)
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
 	return result
 }
 
+// Variant returns the variant with the given ID, and whether it is
+// registered. Like those returned by Variants, the variant shares its
+// slices with the receiver and must be treated as read-only.
+func (r *Registry) Variant(id string) (Variant, bool) {
+	v, found := r.load().variants[id]
+	return v, found
+}
+
 // RegisterConditionType registers the condition type with an ID unique to the
 // set of registered condition types with a function that determines how the
 // condition will be evaluated.
+// fn returns nil if a condition's values are invalid, failing the load of
+// its config. The evaluators it returns are called concurrently, without
+// any lock held, so they must be safe for concurrent use: state they
+// share, such as a counter, must be guarded or updated atomically.
 func (r *Registry) RegisterConditionType(id string, fn func(...interface{}) func(interface{}) bool) error {
+	return r.registerSpec(id, nil, ignoreErr(fn))
+}
+
+// RegisterConditionTypeErr is like RegisterConditionType, but fn returns
+// an error describing why a condition's values are invalid, which is
+// reported by the failed load together with the condition and variant.
+func (r *Registry) RegisterConditionTypeErr(id string, fn func(...interface{}) (func(interface{}) bool, error)) error {
+	return r.registerSpec(id, nil, fn)
+}
+
+// A specFunc creates the evaluator of a condition given its values, or
+// returns an error if they are invalid.
+type specFunc func(values ...interface{}) (func(context interface{}) bool, error)
+
+// ignoreErr returns the specFunc of a spec returning nil for invalid
+// values, whose error is reported when the load fails.
+func ignoreErr(fn func(...interface{}) func(interface{}) bool) specFunc {
+	return func(values ...interface{}) (func(interface{}) bool, error) {
+		return fn(values...), nil
+	}
+}
+
+// registerSpec registers fn as the spec of the condition type with the
+// given ID, declaring schema if it is not nil.
+func (r *Registry) registerSpec(id string, schema *ConditionSchema, fn specFunc) error {
 	r.Lock()
 	defer r.Unlock()
 	id = strings.ToUpper(id)
 	if _, found := r.conditionSpecs[id]; found {
 		return fmt.Errorf("Condition with id %q already registered.", id)
 	}
-	// TODO(andybons): Input checking/sanitization is left to the user to muddle around with.
-	// Determine a better way of handling bad input.
 	r.conditionSpecs[id] = fn
+	if schema != nil {
+		r.conditionSchemas[id] = *schema
+		if len(schema.Args) > 0 {
+			r.conditionArgs[id] = schema.Args
+		}
+	}
 	return nil
 }
 
-const (
-	conditionTypeRandom   = "RANDOM"
-	conditionTypeModRange = "MOD_RANGE"
-)
+// LoadOptions direct how a config is loaded.
+type LoadOptions struct {
+	// Lenient ignores conditions whose type is not registered instead of
+	// failing the load. Such conditions are never met.
+	Lenient bool
 
-func (r *Registry) registerBuiltInConditionTypes() {
-	// Register the RANDOM condition type.
-	r.RegisterConditionType(conditionTypeRandom, func(values ...interface{}) func(interface{}) bool {
-		v, ok := values[0].(float64)
-		if !ok || v < 0 || v > 1 {
-			return nil
-		}
-		return func(_ interface{}) bool {
-			return rand.Float64() <= v
-		}
-	})
+	// StrictTypes fails the load if a mod's value is not of the same
+	// kind, such as a bool, number, or string, as the base value of its
+	// flag. Null values are of every kind. See SetStrictTypes to check
+	// added variants and reloads as well.
+	StrictTypes bool
 
-	// Register the MOD_RANGE condition type.
-	r.RegisterConditionType(conditionTypeModRange, func(values ...interface{}) func(interface{}) bool {
-		if len(values) != 3 {
-			return nil
-		}
-
-		// TODO(andybons): These will panic if the type assertion fails.
-		key := values[0].(string)
-		rangeBegin := int(values[1].(float64))
-		rangeEnd := int(values[2].(float64))
-		if rangeBegin > rangeEnd {
-			return nil
-		}
-
-		return func(context interface{}) bool {
-			ctx, ok := context.(map[string]int)
-			if !ok {
-				return false
-			}
-			mod := ctx[key] % 100
-			return mod >= rangeBegin && mod <= rangeEnd
-		}
-	})
+	// Namespace, if set, places the loaded flags and variants in a
+	// namespace, as LoadJSONNamespaced does.
+	Namespace string
 }
 
 type configFile struct {
+	// Version of the config format, upgraded when loaded.
+	Version int `json:"version,omitempty"`
+
 	Flags    []Flag    `json:"flag_defs"`
 	Variants []Variant `json:"variants"`
+
+	// Values referenced as ${name} by condition values and mod values.
+	Variables map[string]interface{} `json:"variables,omitempty"`
 }
 
 // ReloadJSON constructs a union of the registry created by the given
 // JSON byte array and the receiver, overriding any flag or variant
 // definitions present in the new config but leaving all others alone.
 func (r *Registry) ReloadJSON(data []byte) error {
-	registry := NewRegistry()
+	return r.ReloadJSONMode(data, Merge)
+}
+
+// A ReloadMode selects how a reloaded config is combined with the flags
+// and variants already registered.
+type ReloadMode int
+
+const (
+	// Merge overrides the flags and variants present in the config,
+	// leaving all others alone.
+	Merge ReloadMode = iota
+	// Replace removes the flags and variants absent from the config, so
+	// that the registry holds exactly those of the config, for cleaning
+	// up stale experiments.
+	Replace
+)
+
+// ReloadJSONMode is like ReloadJSON, combining the config with the
+// receiver according to mode. Either way the reload is applied in a
+// single swap, and not at all if the config fails to load.
+func (r *Registry) ReloadJSONMode(data []byte, mode ReloadMode) error {
+	registry := r.scratch()
 	if err := registry.LoadJSON(data); err != nil {
 		return err
 	}
-	return r.mergeRegistry(registry)
+	switch mode {
+	case Merge:
+		return r.mergeRegistry(registry)
+	case Replace:
+		return r.replaceRegistry(registry)
+	}
+	return fmt.Errorf("Reload mode %d is unknown.", mode)
 }
 
 // ReloadConfig constructs a union of the registry created by the given
 // config filename and the receiver, overriding any flag or variant
 // definitions present in the new config but leaving all others alone.
 func (r *Registry) ReloadConfig(filename string) error {
-	other := NewRegistry()
+	other := r.scratch()
 	if err := other.LoadConfig(filename); err != nil {
 		return err
 	}
 	return r.mergeRegistry(other)
 }
 
+// scratch returns an empty registry sharing the receiver's condition
+// types, flag decoders, and default context, for loading a config that
+// will be compared with or merged into the receiver. Contextual condition
+// types of the scratch registry resolve flags with the receiver, into
+// which its variants may be merged.
+func (r *Registry) scratch() *Registry {
+	other := NewRegistry()
+	r.RLock()
+	defer r.RUnlock()
+	for id, fn := range r.conditionSpecs {
+		_, contextual := r.contextualSpecs[id]
+		if _, builtIn := other.conditionSpecs[id]; !builtIn || contextual {
+			other.conditionSpecs[id] = fn
+		}
+	}
+	for id, schema := range r.conditionSchemas {
+		if _, builtIn := other.conditionSchemas[id]; !builtIn {
+			other.conditionSchemas[id] = schema
+		}
+	}
+	for id, args := range r.conditionArgs {
+		other.conditionArgs[id] = args
+	}
+	for id, fn := range r.modSpecs {
+		other.modSpecs[id] = fn
+	}
+	for name, fn := range r.flagDecoders {
+		other.flagDecoders[name] = fn
+	}
+	other.snap.Store(&snapshot{
+		variants:           map[string]Variant{},
+		flags:              map[string]Flag{},
+		flagToVariantIDMap: map[string]map[string]struct{}{},
+		exclusionGroups:    map[string]map[string]struct{}{},
+		defaultContext:     r.load().defaultContext,
+		exposures:          NewMemoryExposureStore(),
+	})
+	other.clock = r.clock
+	other.rng = r.rng
+	other.idSets = r.idSets
+	other.target = r
+	other.strictTypes = r.strictTypes
+	return other
+}
+
+// mergeRegistry replaces the receiver's flag definitions and variants
+// with those of registry in a single swap, so that no evaluation sees
+// the reload half applied.
 func (r *Registry) mergeRegistry(registry *Registry) error {
-	for _, flag := range registry.Flags() {
-		delete(r.flags, flag.Name)
-		r.AddFlag(flag)
+	return r.reload(func(s *snapshot) error {
+		s.mergeFlags(registry.load())
+		if err := s.mergeVariants(registry.load()); err != nil {
+			return err
+		}
+		if err := s.checkDependencies(registry.load().flagNames(), nil); err != nil {
+			return err
+		}
+		if err := r.checkStrictTypes(s, nil); err != nil {
+			return err
+		}
+		return s.dependencyCycles()
+	})
+}
+
+// mergeFlags replaces the receiver's flag definitions with those of
+// other, keeping the variants that refer to them.
+func (s *snapshot) mergeFlags(other *snapshot) {
+	for _, flag := range other.flags {
+		s.flags[flag.Name] = flag
+		if s.flagToVariantIDMap[flag.Name] == nil {
+			s.flagToVariantIDMap[flag.Name] = map[string]struct{}{}
+		}
 	}
-	for _, variant := range registry.Variants() {
-		delete(r.variants, variant.ID)
-		r.AddVariant(variant)
+}
+
+// replaceRegistry replaces the receiver's flag definitions and variants
+// with exactly those of registry in a single swap. Default context and
+// enrollments in capped variants are kept.
+func (r *Registry) replaceRegistry(registry *Registry) error {
+	return r.reload(func(s *snapshot) error {
+		other := registry.load().clone()
+		s.variants = other.variants
+		s.flags = other.flags
+		s.flagToVariantIDMap = other.flagToVariantIDMap
+		s.exclusionGroups = other.exclusionGroups
+		if err := s.checkDependencies(s.flagNames(), nil); err != nil {
+			return err
+		}
+		if err := r.checkStrictTypes(s, nil); err != nil {
+			return err
+		}
+		return s.dependencyCycles()
+	})
+}
+
+// mergeVariants replaces the receiver's variants with those of other.
+func (s *snapshot) mergeVariants(other *snapshot) error {
+	for _, variant := range other.variants {
+		s.deleteVariant(variant.ID)
+		if err := s.addVariant(variant); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// ReloadFlags reloads only the flag definitions of the given JSON-encoded
+// config into the receiver, overriding flags present in the config but
+// leaving all others, and every variant, alone. Any variants in the config
+// are ignored.
+func (r *Registry) ReloadFlags(data []byte) error {
+	config := configFile{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	config.Variants = nil
+	other := r.scratch()
+	if err := other.loadConfig(config, LoadOptions{}); err != nil {
+		return err
+	}
+	return r.reload(func(s *snapshot) error {
+		s.mergeFlags(other.load())
+		if err := s.checkDependencies(other.load().flagNames(), nil); err != nil {
+			return err
+		}
+		if err := r.checkStrictTypes(s, nil); err != nil {
+			return err
+		}
+		return s.dependencyCycles()
+	})
+}
+
+// ReloadVariants reloads only the variants of the given JSON-encoded
+// config into the receiver, overriding variants present in the config but
+// leaving all others, and every flag definition, alone. Any flag
+// definitions in the config are ignored, so the variants' mods must refer
+// to flags already registered with the receiver.
+func (r *Registry) ReloadVariants(data []byte) error {
+	config := configFile{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	config.Flags = r.Flags()
+	other := r.scratch()
+	if err := other.loadConfig(config, LoadOptions{}); err != nil {
+		return err
+	}
+	return r.reload(func(s *snapshot) error {
+		if err := s.mergeVariants(other.load()); err != nil {
+			return err
+		}
+		if err := r.checkStrictTypes(s, nil); err != nil {
+			return err
+		}
+		return s.dependencyCycles()
+	})
+}
+
 // LoadJSON reads a byte array of JSON containing flags and variants
 // and registers them with the receiver.
+// Loading fails with an *UnknownConditionTypeError if a condition's type
+// is not registered.
 func (r *Registry) LoadJSON(data []byte) error {
+	return r.LoadJSONWithOptions(data, LoadOptions{})
+}
+
+// LoadJSONWithOptions is like LoadJSON, loading data as directed by opts.
+func (r *Registry) LoadJSONWithOptions(data []byte, opts LoadOptions) error {
 	config := configFile{}
 	if err := json.Unmarshal(data, &config); err != nil {
 		return err
 	}
-	for _, f := range config.Flags {
-		if err := r.AddFlag(f); err != nil {
+	return r.loadConfig(config, opts)
+}
+
+// loadConfig registers the flags and variants of config with the receiver.
+// They are first registered with a copy of the receiver's snapshot, which
+// is swapped in only once the whole config is found valid, so that a
+// failed load leaves the receiver untouched.
+func (r *Registry) loadConfig(config configFile, opts LoadOptions) error {
+	r.RLock()
+	opts.StrictTypes = opts.StrictTypes || r.strictTypes
+	r.RUnlock()
+	staged := r.load().clone()
+	if errs := r.stageConfig(staged, &config, opts, false); len(errs) > 0 {
+		return errs[0]
+	}
+
+	// Registering the staged definitions again checks them against any
+	// changes made to the receiver meanwhile.
+	r.Lock()
+	defer r.Unlock()
+	return r.update(func(s *snapshot) error {
+		for _, f := range config.Flags {
+			if err := s.addFlag(staged.flags[f.Name]); err != nil {
+				return err
+			}
+		}
+		if err := s.checkDependencies(flagNames(config.Flags), r.targetSnapshot()); err != nil {
 			return err
 		}
+		for _, v := range config.Variants {
+			if err := s.addNewVariant(staged.variants[v.ID]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// stageConfig registers the flags and variants of config with staged,
+// returning the problems found. Unless all is set, it stops at the first
+// problem; otherwise it skips each flag or variant with a problem and
+// carries on, stopping only at problems with the config as a whole.
+func (r *Registry) stageConfig(staged *snapshot, config *configFile, opts LoadOptions, all bool) []error {
+	if err := migrateConfig(config); err != nil {
+		return []error{err}
 	}
-	for _, v := range config.Variants {
-		if len(v.Mods) == 0 {
-			return fmt.Errorf("Variant with ID %q must have at least one mod.", v.ID)
+	if err := checkDuplicates(*config); err != nil {
+		return []error{err}
+	}
+	if err := substituteVariables(config); err != nil {
+		return []error{err}
+	}
+	if len(opts.Namespace) > 0 {
+		if err := applyNamespace(opts.Namespace, config); err != nil {
+			return []error{err}
 		}
-		if len(v.Conditions) > 1 && len(v.ConditionalOperator) == 0 {
-			return fmt.Errorf("Variant with ID %q has %d conditions but no conditional operator specified.", v.ID, len(v.Conditions))
+	}
+	var errs []error
+	for _, f := range config.Flags {
+		err := r.decodeFlag(&f)
+		if err == nil {
+			err = staged.addFlag(f)
 		}
-		for i, c := range v.Conditions {
-			if len(c.Values) == 0 {
-				c.Values = []interface{}{c.Value}
+		if err != nil {
+			if errs = append(errs, err); !all {
+				return errs
 			}
-			r.Lock()
-			if fn, ok := r.conditionSpecs[c.Type]; ok {
-				v.Conditions[i].Evaluator = fn(c.Values...)
+		}
+	}
+	if err := staged.checkDependencies(flagNames(config.Flags), r.targetSnapshot()); err != nil {
+		if errs = append(errs, err); !all {
+			return errs
+		}
+	}
+	variants, err := r.resolveInheritance(config.Variants)
+	if err != nil {
+		return append(errs, err)
+	}
+	for _, v := range variants {
+		if err := r.stageVariant(staged, v, opts); err != nil {
+			if errs = append(errs, err); !all {
+				return errs
 			}
-			r.Unlock()
 		}
-		if err := r.AddVariant(v); err != nil {
+	}
+	return errs
+}
+
+// stageVariant checks v, a variant of a config being loaded, wires up
+// the evaluators of its conditions, and registers it with staged.
+func (r *Registry) stageVariant(staged *snapshot, v Variant, opts LoadOptions) error {
+	if len(v.Mods) == 0 {
+		return fmt.Errorf("Variant with ID %q must have at least one mod.", v.ID)
+	}
+	if err := r.decodeMods(&v); err != nil {
+		return err
+	}
+	if err := r.wireMods(&v); err != nil {
+		return err
+	}
+	if opts.StrictTypes {
+		if err := staged.checkModKinds(&v); err != nil {
 			return err
 		}
 	}
+	if len(v.Conditions) > 1 && len(v.ConditionalOperator) == 0 {
+		return fmt.Errorf("Variant with ID %q has %d conditions but no conditional operator specified.", v.ID, len(v.Conditions))
+	}
+	if v.MaxExposures > 0 && len(v.ExposureKey) == 0 {
+		return fmt.Errorf("Variant with ID %q has max exposures but no exposure key specified.", v.ID)
+	}
+	if !validConditionalOperator(v.ConditionalOperator) {
+		return fmt.Errorf("Variant with ID %q has unknown conditional operator %q.", v.ID, v.ConditionalOperator)
+	}
+	if err := r.loadConditionGroups(&v, opts); err != nil {
+		return err
+	}
+	if err := r.wireConditions(&v, v.Conditions, opts); err != nil {
+		return err
+	}
+	return staged.addNewVariant(v)
+}
+
+// checkDuplicates returns an error if config defines a flag name or
+// variant ID more than once, before any of its definitions is registered.
+func checkDuplicates(config configFile) error {
+	flags := map[string]bool{}
+	for _, f := range config.Flags {
+		if flags[f.Name] {
+			return fmt.Errorf("Duplicate flag %q in config.", f.Name)
+		}
+		flags[f.Name] = true
+	}
+	variants := map[string]bool{}
+	for _, v := range config.Variants {
+		if variants[v.ID] {
+			return fmt.Errorf("Duplicate variant %q in config.", v.ID)
+		}
+		variants[v.ID] = true
+	}
 	return nil
 }
 
@@ -1,6 +1,10 @@
 package variants
 
 import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -24,10 +28,10 @@ func TestDuplicateFlags(t *testing.T) {
 
 func TestDuplicateVariant(t *testing.T) {
 	Reset()
-	if err := AddVariant(Variant{ID: "GOOB"}); err != nil {
+	if err := AddVariant(Variant{ID: "GOOB", Unconditional: true}); err != nil {
 		t.Errorf("AddVariant: Expected no error but got %q", err.Error())
 	}
-	if err := AddVariant(Variant{ID: "GOOB"}); err == nil {
+	if err := AddVariant(Variant{ID: "GOOB", Unconditional: true}); err == nil {
 		t.Error("AddVariant: Expected duplicate variant error, but got nil.")
 	}
 }
@@ -328,3 +332,647 @@ func TestRegistryDataRace(t *testing.T) {
 	}()
 	wg.Wait()
 }
+
+func TestReloadConfigWhileEvaluatingDataRace(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			r.ReloadConfig("testdata/testdata_reloaded.json")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			r.FlagValueWithContext("mod_range", map[string]int{"user_id": i})
+			r.AllFlagValues(nil)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestMiddleware(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	calls := []string{}
+	r.Use(func(next Evaluator) Evaluator {
+		return func(name string, context interface{}) interface{} {
+			calls = append(calls, "outer:"+name)
+			return next(name, context)
+		}
+	})
+	r.Use(Overrides(map[string]interface{}{"always_fails": "overridden"}))
+
+	if v := r.FlagValue("always_fails"); v != "overridden" {
+		t.Errorf("FlagValue: expected always_fails to be overridden, got %v.", v)
+	}
+	if v := r.FlagValue("always_passes"); v != true {
+		t.Errorf("FlagValue: expected always_passes to return true, got %v.", v)
+	}
+	expected := []string{"outer:always_fails", "outer:always_passes"}
+	if len(calls) != len(expected) || calls[0] != expected[0] || calls[1] != expected[1] {
+		t.Errorf("Use: expected middleware calls %v, got %v.", expected, calls)
+	}
+}
+
+func TestImpactReport(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	newConfig := `{
+	  "flag_defs": [{"flag": "mod_range", "base_value": false}],
+	  "variants": [{
+	    "id": "ModRangeTest",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 19]}],
+	    "mods": [{"flag": "mod_range", "value": true}]
+	  }]
+	}`
+	samples := []interface{}{}
+	for i := 0; i < 100; i++ {
+		samples = append(samples, map[string]int{"user_id": i})
+	}
+	report, err := r.ImpactReport([]byte(newConfig), samples)
+	if err != nil {
+		t.Fatalf("ImpactReport: expected no error, but got %q.", err.Error())
+	}
+	delta := report["mod_range"]
+	if delta.Changed != 10 || delta.Transitions["false -> true"] != 10 {
+		t.Errorf("ImpactReport: expected 10 contexts to change from false to true, got %+v.", delta)
+	}
+	if delta := report["always_passes"]; delta.Transitions["true -> <nil>"] != 100 {
+		t.Errorf("ImpactReport: expected removed flag to change for every context, got %+v.", delta)
+	}
+}
+
+func TestModRangeInterfaceContext(t *testing.T) {
+	resetAndLoadFile("testdata/testdata.json", t)
+	testCases := map[interface{}]bool{
+		3:            true,
+		float64(109): true,
+		int64(50):    false,
+		"3":          false,
+	}
+	for userID, expected := range testCases {
+		v := FlagValueWithContext("mod_range", map[string]interface{}{"user_id": userID})
+		if v != expected {
+			t.Errorf("FlagValueWithContext: expected mod_range for %#v to return %t, got %t.", userID, expected, v)
+		}
+	}
+	if v := FlagValueWithContext("mod_range", map[string]interface{}{}); v != false {
+		t.Errorf("FlagValueWithContext: expected mod_range without a user_id to return false, got %t.", v)
+	}
+}
+
+func TestDefaultContext(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	r.SetDefaultContext(map[string]interface{}{"user_id": 5})
+
+	testCases := []struct {
+		Context  interface{}
+		Expected bool
+	}{
+		{nil, true},
+		{map[string]interface{}{"region": "us"}, true},
+		{map[string]interface{}{"user_id": 50}, false},
+		{map[string]int{"user_id": 50}, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("mod_range", tc.Context); v != tc.Expected {
+			t.Errorf("FlagValueWithContext: expected mod_range with context %v to return %t, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+}
+
+func TestCaseInsensitiveContext(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "Beta",
+	    "conditions": [
+	      {"type": "WHITELIST", "values": ["User-Id", "42"]},
+	      {"type": "MOD_RANGE", "values": ["Bucket", 0, 49]}
+	    ],
+	    "condition_operator": "OR",
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := []struct {
+		Context     interface{}
+		Sensitive   bool
+		Insensitive bool
+	}{
+		{map[string]string{"User-Id": "42"}, true, true},
+		{map[string]string{"user-id": "42"}, false, true},
+		{map[string]interface{}{"USER-ID": "42"}, false, true},
+		{map[string]int{"bucket": 7}, false, true},
+		{map[string]float64{"BUCKET": 7}, false, true},
+		{map[string]string{"user-id": "43"}, false, false},
+	}
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("beta", tc.Context); v != tc.Sensitive {
+			t.Errorf("FlagValueWithContext: expected %v to return %t with case-sensitive keys, got %v.", tc.Context, tc.Sensitive, v)
+		}
+	}
+
+	r.SetCaseInsensitiveContext(true)
+	r.SetDefaultContext(map[string]interface{}{"Region": "us"})
+	for _, tc := range testCases {
+		if v := r.FlagValueWithContext("beta", tc.Context); v != tc.Insensitive {
+			t.Errorf("FlagValueWithContext: expected %v to return %t with case-insensitive keys, got %v.", tc.Context, tc.Insensitive, v)
+		}
+	}
+}
+
+type seededContext struct {
+	rng *rand.Rand
+}
+
+func (c seededContext) Rand() *rand.Rand { return c.rng }
+
+func TestRandContext(t *testing.T) {
+	resetAndLoadFile("testdata/testdata.json", t)
+	results := func(seed int64) []interface{} {
+		ctx := seededContext{rand.New(rand.NewSource(seed))}
+		vals := []interface{}{}
+		for i := 0; i < 20; i++ {
+			vals = append(vals, FlagValueWithContext("coin_flip", ctx))
+		}
+		return vals
+	}
+	first, second := results(42), results(42)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("FlagValueWithContext: expected identically seeded contexts to produce the same results, got %v and %v.", first, second)
+		}
+	}
+}
+
+func TestSetRand(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	results := func(seed int64) []interface{} {
+		r.SetRand(rand.New(rand.NewSource(seed)))
+		vals := []interface{}{}
+		for i := 0; i < 20; i++ {
+			vals = append(vals, r.FlagValue("coin_flip"))
+		}
+		return vals
+	}
+	first, second := results(42), results(42)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("FlagValue: expected identically seeded registries to produce the same results, got %v and %v.", first, second)
+		}
+	}
+
+	// Reloaded conditions use the receiver's source.
+	reload := `{"variants": [{"id": "CoinFlipTest", "conditions": [{"type": "RANDOM", "value": 0.5}], "mods": [{"flag": "coin_flip", "value": true}]}]}`
+	if err := r.ReloadVariants([]byte(reload)); err != nil {
+		t.Fatalf("ReloadVariants: expected no error, but got %q.", err.Error())
+	}
+	if again := results(42); fmt.Sprint(again) != fmt.Sprint(first) {
+		t.Errorf("FlagValue: expected reloaded conditions to use the seeded source, got %v and %v.", first, again)
+	}
+	r.SetRand(nil)
+	r.FlagValue("coin_flip")
+}
+
+func TestNotOperator(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "everyone_else", "base_value": false}],
+	  "variants": [{
+	    "id": "EveryoneElse",
+	    "condition_operator": "%s",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 9]}],
+	    "mods": [{"flag": "everyone_else", "value": true}]
+	  }]
+	}`
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(fmt.Sprintf(config, "NOT"))); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := map[int]bool{
+		5:  false,
+		50: true,
+	}
+	for userID, expected := range testCases {
+		if v := r.FlagValueWithContext("everyone_else", map[string]int{"user_id": userID}); v != expected {
+			t.Errorf("FlagValueWithContext: expected everyone_else for user %d to return %t, got %v.", userID, expected, v)
+		}
+	}
+
+	if err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(config, "NOR"))); err == nil {
+		t.Error("LoadJSON: expected unknown conditional operator error, but got nil.")
+	}
+}
+
+func TestPartialReload(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	flags := `{
+	  "flag_defs": [{"flag": "always_fails", "base_value": "reloaded"}],
+	  "variants": [{
+	    "id": "AlwaysFailsTest",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "always_fails", "value": true}]
+	  }]
+	}`
+	if err := r.ReloadFlags([]byte(flags)); err != nil {
+		t.Fatalf("ReloadFlags: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("always_fails"); v != "reloaded" {
+		t.Errorf("FlagValue: expected reloaded base value with variants untouched, got %v.", v)
+	}
+	if v := r.FlagValue("always_passes"); v != true {
+		t.Errorf("FlagValue: expected existing variant to still apply after ReloadFlags, got %v.", v)
+	}
+
+	if err := r.ReloadVariants([]byte(flags)); err != nil {
+		t.Fatalf("ReloadVariants: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("always_fails"); v != true {
+		t.Errorf("FlagValue: expected reloaded variant to apply, got %v.", v)
+	}
+
+	unknownFlag := `{
+	  "variants": [{
+	    "id": "Unknown",
+	    "mods": [{"flag": "unknown", "value": true}]
+	  }]
+	}`
+	if err := r.ReloadVariants([]byte(unknownFlag)); err == nil {
+		t.Error("ReloadVariants: expected unregistered flag error, but got nil.")
+	}
+}
+
+func TestReloadJSONMode(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "kept", "base_value": false}, {"flag": "stale", "base_value": false}],
+	  "variants": [{
+	    "id": "Kept",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "kept", "value": true}]
+	  }, {
+	    "id": "Stale",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "stale", "value": true}]
+	  }]
+	}`
+	reloaded := `{
+	  "flag_defs": [{"flag": "kept", "base_value": false}],
+	  "variants": [{
+	    "id": "Kept",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "kept", "value": "reloaded"}]
+	  }]
+	}`
+
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if err := r.ReloadJSONMode([]byte(reloaded), Merge); err != nil {
+		t.Fatalf("ReloadJSONMode: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("kept"); v != "reloaded" {
+		t.Errorf("FlagValue: expected reloaded variant to apply, got %v.", v)
+	}
+	if v := r.FlagValue("stale"); v != true {
+		t.Errorf("FlagValue: expected Merge to leave stale variant alone, got %v.", v)
+	}
+
+	r = NewRegistry()
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	var changed []string
+	r.OnChange(func(name string) { changed = append(changed, name) })
+	if err := r.ReloadJSONMode([]byte(reloaded), Replace); err != nil {
+		t.Fatalf("ReloadJSONMode: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("kept"); v != "reloaded" {
+		t.Errorf("FlagValue: expected reloaded variant to apply, got %v.", v)
+	}
+	if _, found := r.Flag("stale"); found {
+		t.Error("Flag: expected Replace to remove stale flag.")
+	}
+	if vs := r.Variants(); len(vs) != 1 || vs[0].ID != "Kept" {
+		t.Errorf("Variants: expected only the reloaded variant after Replace, got %v.", vs)
+	}
+	if expected := []string{"kept", "stale"}; !reflect.DeepEqual(changed, expected) {
+		t.Errorf("OnChange: expected changes to %v, got %v.", expected, changed)
+	}
+
+	if err := r.ReloadJSONMode([]byte(`{"flag_defs": [{"flag": "kept"}], "variants": [{"id": "Bad"}]}`), Replace); err == nil {
+		t.Error("ReloadJSONMode: expected an invalid config to fail, but got nil.")
+	}
+	if v := r.FlagValue("kept"); v != "reloaded" {
+		t.Errorf("FlagValue: expected a failed Replace to leave the registry alone, got %v.", v)
+	}
+}
+
+func TestAddFlagsAndVariantsAtomic(t *testing.T) {
+	r := NewRegistry()
+	err := r.AddFlags([]Flag{
+		{Name: "a", BaseValue: false, DependsOn: []string{"b"}},
+		{Name: "b", BaseValue: false, DependsOn: []string{"a"}},
+	})
+	if err == nil {
+		t.Fatal("AddFlags: expected dependency cycle error, but got nil.")
+	}
+	if len(r.Flags()) != 0 {
+		t.Errorf("Flags: expected a failed batch to register no flags, got %v.", r.Flags())
+	}
+	if err := r.AddFlags([]Flag{{Name: "a", BaseValue: false}, {Name: "b", BaseValue: false}}); err != nil {
+		t.Fatalf("AddFlags: expected no error, but got %q.", err.Error())
+	}
+
+	err = r.AddVariants([]Variant{
+		{ID: "A", Unconditional: true, Mods: []Mod{{FlagName: "a", Value: true}}},
+		{ID: "Missing", Unconditional: true, Mods: []Mod{{FlagName: "missing", Value: true}}},
+	})
+	if expected := `Flag with the name "missing" has not been registered.`; err == nil || err.Error() != expected {
+		t.Errorf("AddVariants: expected error %q, got %v.", expected, err)
+	}
+	if len(r.Variants()) != 0 {
+		t.Errorf("Variants: expected a failed batch to register no variants, got %v.", r.Variants())
+	}
+	err = r.AddVariants([]Variant{
+		{ID: "A", Unconditional: true, Mods: []Mod{{FlagName: "a", Value: true}}},
+		{ID: "A", Unconditional: true, Mods: []Mod{{FlagName: "b", Value: true}}},
+	})
+	if err == nil {
+		t.Error("AddVariants: expected duplicate ID error within the batch, but got nil.")
+	}
+
+	err = r.AddVariants([]Variant{
+		{ID: "A", Unconditional: true, Mods: []Mod{{FlagName: "a", Value: true}}},
+		{ID: "B", Unconditional: true, Mods: []Mod{{FlagName: "b", Value: true}}},
+	})
+	if err != nil {
+		t.Fatalf("AddVariants: expected no error, but got %q.", err.Error())
+	}
+	if a, b := r.FlagValue("a"), r.FlagValue("b"); a != true || b != true {
+		t.Errorf("FlagValue: expected both batch variants to apply, got %v and %v.", a, b)
+	}
+
+	// A batch of flags and variants fails as a whole, even if only a
+	// variant is invalid.
+	err = r.AddBatch([]Flag{{Name: "c", BaseValue: false}}, []Variant{
+		{ID: "C", Unconditional: true, Mods: []Mod{{FlagName: "c", Value: true}}},
+		{ID: "A", Unconditional: true, Mods: []Mod{{FlagName: "c", Value: true}}},
+	})
+	if err == nil {
+		t.Error("AddBatch: expected duplicate ID error, but got nil.")
+	}
+	if r.IsRegistered("c") || len(r.Variants()) != 2 {
+		t.Errorf("AddBatch: expected a failed batch to register nothing, got flags %v and variants %v.", r.Flags(), r.Variants())
+	}
+	err = r.AddBatch([]Flag{{Name: "c", BaseValue: false}}, []Variant{
+		{ID: "C", Unconditional: true, Mods: []Mod{{FlagName: "c", Value: true}}},
+	})
+	if err != nil {
+		t.Fatalf("AddBatch: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("c"); v != true {
+		t.Errorf("FlagValue: expected the batch variant to modify the batch flag, got %v.", v)
+	}
+}
+
+func TestFlagValueOr(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "nil_base", "base_value": null}, {"flag": "beta", "base_value": false}],
+	  "variants": [{
+	    "id": "Beta",
+	    "conditions": [{"type": "MOD_RANGE", "values": ["user_id", 0, 49]}],
+	    "mods": [{"flag": "beta", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if !r.IsRegistered("nil_base") || r.IsRegistered("missing") {
+		t.Error("IsRegistered: expected only registered flags to be reported.")
+	}
+	if v := r.FlagValueOr("nil_base", "fallback"); v != nil {
+		t.Errorf("FlagValueOr: expected the nil base value of a registered flag, got %v.", v)
+	}
+	if v := r.FlagValueOr("missing", "fallback"); v != "fallback" {
+		t.Errorf("FlagValueOr: expected the fallback for an unregistered flag, got %v.", v)
+	}
+	if v := r.FlagValueWithContextOr("beta", map[string]int{"user_id": 1}, "fallback"); v != true {
+		t.Errorf("FlagValueWithContextOr: expected the flag's value for the context, got %v.", v)
+	}
+	if v := r.FlagValueWithContextOr("missing", map[string]int{"user_id": 1}, "fallback"); v != "fallback" {
+		t.Errorf("FlagValueWithContextOr: expected the fallback for an unregistered flag, got %v.", v)
+	}
+
+	// A reload removing the flag mid-evaluation does not affect it.
+	r.Use(func(next Evaluator) Evaluator {
+		return func(name string, context interface{}) interface{} {
+			if err := r.ReloadJSONMode([]byte(`{"flag_defs": [{"flag": "nil_base", "base_value": null}]}`), Replace); err != nil {
+				t.Fatalf("ReloadJSONMode: expected no error, but got %q.", err.Error())
+			}
+			return next(name, context)
+		}
+	})
+	if v := r.FlagValueWithContextOr("beta", map[string]int{"user_id": 1}, "fallback"); v != true {
+		t.Errorf("FlagValueWithContextOr: expected the flag's value despite a concurrent reload, got %v.", v)
+	}
+}
+
+func TestFlagValueWithJSONContext(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	testCases := []struct {
+		Context  string
+		Expected interface{}
+	}{
+		{`{"user_id": 3}`, true},
+		{`{"user_id": 1242}`, false},
+		{`{}`, false},
+		{`null`, false},
+	}
+	for _, tc := range testCases {
+		v, err := r.FlagValueWithJSONContext("mod_range", []byte(tc.Context))
+		if err != nil {
+			t.Errorf("FlagValueWithJSONContext: expected no error for %s, but got %q.", tc.Context, err.Error())
+		}
+		if v != tc.Expected {
+			t.Errorf("FlagValueWithJSONContext: expected %s to return %v, got %v.", tc.Context, tc.Expected, v)
+		}
+	}
+	for _, ctx := range []string{`{"user_id": `, `[1, 2]`, ``} {
+		if _, err := r.FlagValueWithJSONContext("mod_range", []byte(ctx)); err == nil {
+			t.Errorf("FlagValueWithJSONContext: expected an error for %q.", ctx)
+		}
+	}
+}
+
+func TestFlagsAndVariantsOrdered(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"c", "a", "d", "b"} {
+		r.AddFlag(Flag{Name: name})
+		r.AddVariant(Variant{ID: strings.ToUpper(name), Unconditional: true, Mods: []Mod{{FlagName: name, Value: true}}})
+	}
+	names := []string{}
+	for _, f := range r.Flags() {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "a,b,c,d" {
+		t.Errorf("Flags: expected flags ordered by name, got %v.", names)
+	}
+	ids := []string{}
+	for _, v := range r.Variants() {
+		ids = append(ids, v.ID)
+	}
+	if strings.Join(ids, ",") != "A,B,C,D" {
+		t.Errorf("Variants: expected variants ordered by ID, got %v.", ids)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	Reset()
+	AddFlag(Flag{Name: "a", BaseValue: 1})
+	AddVariant(Variant{ID: "A", Unconditional: true, Mods: []Mod{{FlagName: "a", Value: 2}}})
+	if f, ok := GetFlag("a"); !ok || f.Name != "a" || f.BaseValue != 1 {
+		t.Errorf("GetFlag: expected flag a, got %+v (%v).", f, ok)
+	}
+	if _, ok := GetFlag("b"); ok {
+		t.Error("GetFlag: expected an unknown flag not to be found.")
+	}
+	if v, ok := GetVariant("A"); !ok || v.ID != "A" || v.FlagValue("a") != 2 {
+		t.Errorf("GetVariant: expected variant A, got %+v (%v).", v, ok)
+	}
+	if _, ok := DefaultRegistry.Variant("B"); ok {
+		t.Error("Variant: expected an unknown variant not to be found.")
+	}
+}
+
+func TestRequiresContext(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "random", "base_value": false},
+	    {"flag": "whitelist", "base_value": false},
+	    {"flag": "grouped", "base_value": false},
+	    {"flag": "dependent", "base_value": false, "depends_on": ["whitelist"]},
+	    {"flag": "capped", "base_value": false},
+	    {"flag": "plain", "base_value": false}
+	  ],
+	  "variants": [{
+	    "id": "Random",
+	    "conditions": [{"type": "RANDOM", "value": 0.5}],
+	    "mods": [{"flag": "random", "value": true}]
+	  }, {
+	    "id": "Whitelist",
+	    "conditions": [{"type": "WHITELIST", "values": ["id", "a"]}],
+	    "mods": [{"flag": "whitelist", "value": true}],
+	    "exclusion_group": "test"
+	  }, {
+	    "id": "Grouped",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "grouped", "value": true}],
+	    "exclusion_group": "test"
+	  }, {
+	    "id": "Capped",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "capped", "value": true}],
+	    "max_exposures": 10,
+	    "exposure_key": "id"
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := map[string]bool{
+		"random":    false,
+		"whitelist": true,
+		"grouped":   true,
+		"dependent": true,
+		"capped":    true,
+		"plain":     false,
+		"unknown":   false,
+	}
+	for flag, expected := range testCases {
+		if requires := r.RequiresContext(flag); requires != expected {
+			t.Errorf("RequiresContext: expected %t for %s, got %t.", expected, flag, requires)
+		}
+	}
+
+	random := r.load().variants["Random"].Conditions[0]
+	whitelist := r.load().variants["Whitelist"].Conditions[0]
+	if !random.IgnoresContext() || whitelist.IgnoresContext() {
+		t.Errorf("IgnoresContext: expected true for RANDOM and false for WHITELIST, got %t and %t.", random.IgnoresContext(), whitelist.IgnoresContext())
+	}
+	if (&Condition{Type: "RANDOM"}).IgnoresContext() {
+		t.Error("IgnoresContext: expected false for a condition that was not loaded.")
+	}
+}
+
+func TestDuplicatesInConfig(t *testing.T) {
+	testCases := map[string]string{
+		`{"flag_defs": [{"flag": "a"}, {"flag": "b"}, {"flag": "a"}]}`: `Duplicate flag "a" in config.`,
+		`{
+		  "flag_defs": [{"flag": "a"}],
+		  "variants": [{"id": "A", "mods": [{"flag": "a", "value": 1}]}, {"id": "A", "mods": [{"flag": "a", "value": 2}]}]
+		}`: `Duplicate variant "A" in config.`,
+	}
+	for config, expected := range testCases {
+		r := NewRegistry()
+		if err := r.LoadJSON([]byte(config)); err == nil || err.Error() != expected {
+			t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+		}
+		if len(r.Flags()) != 0 {
+			t.Errorf("LoadJSON: expected nothing to be registered, got %v.", r.Flags())
+		}
+	}
+}
+
+func TestFailedLoadLeavesRegistryUntouched(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(`{"flag_defs": [{"flag": "a", "base_value": 1}]}`)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	config := `{
+	  "flag_defs": [{"flag": "b", "base_value": 2}],
+	  "variants": [
+	    {"id": "A", "conditions": [{"type": "RANDOM", "value": 1.0}], "mods": [{"flag": "a", "value": 10}]},
+	    {"id": "B", "conditions": [{"type": "RANDOM", "value": 1.0}], "mods": [{"flag": "unknown", "value": 3}]}
+	  ]
+	}`
+	if err := r.LoadJSON([]byte(config)); err == nil {
+		t.Fatal("LoadJSON: expected an error for a mod of an unknown flag, got none.")
+	}
+	if flags := r.Flags(); len(flags) != 1 || flags[0].Name != "a" {
+		t.Errorf("Flags: expected only flag \"a\" after a failed load, got %v.", flags)
+	}
+	if variants := r.Variants(); len(variants) != 0 {
+		t.Errorf("Variants: expected no variants after a failed load, got %v.", variants)
+	}
+	if value := r.FlagValue("a"); value != 1.0 {
+		t.Errorf("FlagValue: expected 1 after a failed load, got %v.", value)
+	}
+}
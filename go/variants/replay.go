@@ -0,0 +1,100 @@
+package variants
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+)
+
+// A ReplayMismatch describes a recorded evaluation whose result differs
+// when replayed against the current registry.
+type ReplayMismatch struct {
+	Event     EvalEvent
+	Value     interface{}
+	VariantID string
+}
+
+// RecordEvents writes each event received from events to w as a line of
+// JSON until events is closed, for later use with ReadEvents and Replay.
+func RecordEvents(events <-chan EvalEvent, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadEvents reads events written by RecordEvents. Contexts are decoded
+// as map[string]interface{}, so replayed conditions must accept them.
+func ReadEvents(rd io.Reader) ([]EvalEvent, error) {
+	events := []EvalEvent{}
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		e := EvalEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// Replay re-evaluates events against the DefaultRegistry.
+func Replay(events []EvalEvent) []ReplayMismatch {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.Replay(events)
+}
+
+// Replay re-evaluates each recorded event's flag and context against the
+// receiver and returns the events whose value or winning variant now
+// differ. Values are compared by their JSON encodings, since recorded
+// values have been through a JSON round trip. Middleware is not applied.
+//
+// An event recording a Seed is replayed with its context wrapped in a
+// Context that is also a RandContext drawing from
+// rand.NewSource(Seed), so that RANDOM conditions repeat their decisions
+// when the recorded context seeded its Rand the same way.
+func (r *Registry) Replay(events []EvalEvent) []ReplayMismatch {
+	mismatches := []ReplayMismatch{}
+	for _, e := range events {
+		context := e.Context
+		if e.Seed != 0 {
+			context = replayContext{context, e.Seed, rand.New(rand.NewSource(e.Seed))}
+		}
+		val, variantID := r.resolve(e.Flag, context, nil)
+		if variantID != e.VariantID || !jsonEqual(val, e.Value) {
+			mismatches = append(mismatches, ReplayMismatch{
+				Event:     e,
+				Value:     val,
+				VariantID: variantID,
+			})
+		}
+	}
+	return mismatches
+}
+
+// A replayContext is a recorded context replayed with its recorded seed.
+type replayContext struct {
+	context interface{}
+	seed    int64
+	rng     *rand.Rand
+}
+
+func (c replayContext) Get(key string) (interface{}, bool) { return contextValue(c.context, key) }
+func (c replayContext) Rand() *rand.Rand                   { return c.rng }
+func (c replayContext) Seed() int64                        { return c.seed }
+
+func jsonEqual(a, b interface{}) bool {
+	aj, aErr := json.Marshal(a)
+	bj, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aj, bj)
+}
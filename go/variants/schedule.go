@@ -0,0 +1,100 @@
+package variants
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps the abbreviated names of the days of the week accepted by
+// SCHEDULE conditions to the days.
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday,
+	"MON": time.Monday,
+	"TUE": time.Tuesday,
+	"WED": time.Wednesday,
+	"THU": time.Thursday,
+	"FRI": time.Friday,
+	"SAT": time.Saturday,
+}
+
+// A schedule is a parsed SCHEDULE condition: a daily window of time, on
+// some days of the week, in a time zone.
+type schedule struct {
+	loc  *time.Location
+	days [7]bool
+
+	// Bounds of the window, as offsets from midnight. The window wraps
+	// past midnight if end is before start.
+	start, end time.Duration
+}
+
+// parseSchedule parses the values of a SCHEDULE condition: the IANA time
+// zone, the list of days, and the start and end of the daily window as
+// "15:04" times.
+func parseSchedule(values []interface{}) (*schedule, error) {
+	if len(values) != 4 {
+		return nil, fmt.Errorf("SCHEDULE takes a time zone, days, a start time, and an end time, got %d values.", len(values))
+	}
+	name, ok := values[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("SCHEDULE time zone %v is not a string.", values[0])
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("SCHEDULE time zone %q is unknown.", name)
+	}
+	s := &schedule{loc: loc}
+
+	days, ok := values[1].([]interface{})
+	if !ok || len(days) == 0 {
+		return nil, fmt.Errorf("SCHEDULE days %v are not a list of days.", values[1])
+	}
+	for _, d := range days {
+		day, ok := d.(string)
+		if !ok {
+			return nil, fmt.Errorf("SCHEDULE day %v is not a string.", d)
+		}
+		weekday, ok := weekdays[strings.ToUpper(day)]
+		if !ok {
+			return nil, fmt.Errorf("SCHEDULE day %q is not one of SUN, MON, TUE, WED, THU, FRI, or SAT.", day)
+		}
+		s.days[weekday] = true
+	}
+
+	var bounds [2]time.Duration
+	for i := range bounds {
+		str, ok := values[i+2].(string)
+		if !ok {
+			return nil, fmt.Errorf("SCHEDULE time %v is not a string.", values[i+2])
+		}
+		t, err := time.Parse("15:04", str)
+		if err != nil {
+			return nil, fmt.Errorf("SCHEDULE time %q is not a time of day such as 09:30.", str)
+		}
+		bounds[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	s.start, s.end = bounds[0], bounds[1]
+	if s.start == s.end {
+		return nil, fmt.Errorf("SCHEDULE start and end are both %s.", values[2])
+	}
+	return s, nil
+}
+
+// active reports whether now falls within the schedule. Times past
+// midnight within a window wrapping past it belong to the day the window
+// started.
+func (s *schedule) active(now time.Time) bool {
+	now = now.In(s.loc)
+	// The wall-clock time of day, which on days daylight saving time
+	// begins or ends is not the time elapsed since midnight.
+	offset := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	day := now.Weekday()
+	if s.start < s.end {
+		return s.days[day] && offset >= s.start && offset < s.end
+	}
+	if offset >= s.start {
+		return s.days[day]
+	}
+	return offset < s.end && s.days[(day+6)%7]
+}
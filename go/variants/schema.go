@@ -0,0 +1,222 @@
+package variants
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Types of context attributes read by condition types.
+const (
+	// ContextTypeAny accepts a value of any type.
+	ContextTypeAny = "any"
+	// ContextTypeInt accepts any numeric value, which is truncated to an int.
+	ContextTypeInt = "int"
+	// ContextTypeNumber accepts any numeric value.
+	ContextTypeNumber = "number"
+	// ContextTypeString accepts a string value.
+	ContextTypeString = "string"
+)
+
+// A ContextAttr describes a context attribute read by a condition type.
+type ContextAttr struct {
+	// Key is the context key read. If empty, the key is given by the
+	// condition's value at index KeyArg.
+	Key    string
+	KeyArg int
+
+	// Type is the expected type of the attribute, one of the
+	// ContextType constants.
+	Type string
+
+	// Optional marks an attribute that conditions do without, such as a
+	// "now" overriding the registry's clock. ValidateContext does not
+	// require it.
+	Optional bool
+}
+
+// A ConditionSchema describes the context that conditions of a type
+// read. A schema without Context declares that the type reads no context.
+type ConditionSchema struct {
+	Context []ContextAttr
+
+	// Args optionally describes the values of conditions of the type,
+	// for tools such as admin UIs building forms for conditions. See
+	// ConditionTypes.
+	Args []ConditionArg
+}
+
+// A ConditionArg describes a value of the conditions of a type.
+type ConditionArg struct {
+	Name string `json:"name"`
+
+	// Type is the expected type of the value, one of the ContextType
+	// constants.
+	Type string `json:"type"`
+
+	// Optional marks a value that may be omitted. Only trailing values
+	// may be optional.
+	Optional bool `json:"optional,omitempty"`
+
+	// Repeated marks a last value that may be given any number of times,
+	// at least once unless it is also optional.
+	Repeated bool `json:"repeated,omitempty"`
+}
+
+// A ConditionTypeInfo describes a registered condition type.
+type ConditionTypeInfo struct {
+	ID string `json:"id"`
+
+	// Args describes the values of the type's conditions, or is nil if
+	// the type was registered without describing them.
+	Args []ConditionArg `json:"args,omitempty"`
+}
+
+// ValueCount returns the least and greatest number of values conditions
+// of the type take, as described by its Args. max is -1 if there is no
+// greatest number, as when the last value is repeated or the values are
+// not described.
+func (i ConditionTypeInfo) ValueCount() (min, max int) {
+	if i.Args == nil {
+		return 0, -1
+	}
+	for _, a := range i.Args {
+		if !a.Optional {
+			min++
+		}
+		if a.Repeated {
+			return min, -1
+		}
+		max++
+	}
+	return min, max
+}
+
+// ConditionTypes returns a description of every condition type registered
+// with the DefaultRegistry.
+func ConditionTypes() []ConditionTypeInfo {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ConditionTypes()
+}
+
+// ConditionTypes returns a description of every condition type registered
+// with the receiver, ordered by ID, including the values its conditions
+// take if described by the type's schema. The built-in types describe
+// their values.
+func (r *Registry) ConditionTypes() []ConditionTypeInfo {
+	r.RLock()
+	defer r.RUnlock()
+	types := make([]ConditionTypeInfo, 0, len(r.conditionSpecs))
+	for id := range r.conditionSpecs {
+		types = append(types, ConditionTypeInfo{ID: id, Args: r.conditionArgs[id]})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].ID < types[j].ID
+	})
+	return types
+}
+
+// RegisterConditionTypeWithSchema registers a Condition type with the
+// given ID, schema, and evaluating function with the DefaultRegistry.
+func RegisterConditionTypeWithSchema(id string, schema ConditionSchema, fn func(...interface{}) func(interface{}) bool) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterConditionTypeWithSchema(id, schema, fn)
+}
+
+// ValidateContext checks context against the schemas of the conditions
+// that may modify the named flag within the DefaultRegistry.
+func ValidateContext(flagName string, context interface{}) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ValidateContext(flagName, context)
+}
+
+// RegisterConditionTypeWithSchema is like RegisterConditionType, but also
+// declares the context attributes that conditions of the type read, for
+// use by ValidateContext and introspection, and optionally the values
+// they take, for ConditionTypes.
+func (r *Registry) RegisterConditionTypeWithSchema(id string, schema ConditionSchema, fn func(...interface{}) func(interface{}) bool) error {
+	return r.registerSpec(id, &schema, ignoreErr(fn))
+}
+
+// ConditionSchema returns the schema declared for the condition type with
+// the given ID, and whether one was declared.
+func (r *Registry) ConditionSchema(id string) (ConditionSchema, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	schema, ok := r.conditionSchemas[strings.ToUpper(id)]
+	return schema, ok
+}
+
+// ValidateContext checks that context provides every attribute, of the
+// declared type, read by the conditions of the variants modifying the
+// named flag. Conditions whose type has no declared schema are not
+// checked. The returned error describes every problem found.
+func (r *Registry) ValidateContext(flagName string, context interface{}) error {
+	r.RLock()
+	defer r.RUnlock()
+	s := r.load()
+	context = s.mergeDefaultContext(context)
+	problems := []string{}
+	for _, v := range s.sortedVariants(s.flagToVariantIDMap[flagName]) {
+		for i, c := range v.allConditions() {
+			schema, ok := r.conditionSchemas[c.Type]
+			if !ok {
+				continue
+			}
+			for _, attr := range schema.Context {
+				if err := attr.validate(c.args(), context); err != nil {
+					problems = append(problems, fmt.Sprintf("condition %d (%s) of variant %q: %v", i, c.Type, v.ID, err))
+				}
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func (a ContextAttr) validate(args []interface{}, context interface{}) error {
+	key, err := a.key(args)
+	if err != nil {
+		return err
+	}
+	v, ok := contextValue(context, key)
+	if !ok && a.Optional {
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("context is missing key %q", key)
+	}
+	switch a.Type {
+	case ContextTypeInt:
+		_, ok = toInt(v)
+	case ContextTypeNumber:
+		_, ok = toFloat(v)
+	case ContextTypeString:
+		_, ok = v.(string)
+	}
+	if !ok {
+		return fmt.Errorf("context key %q has value %#v, expected type %s", key, v, a.Type)
+	}
+	return nil
+}
+
+// key returns the context key read, given the condition's values.
+func (a ContextAttr) key(args []interface{}) (string, error) {
+	if len(a.Key) > 0 {
+		return a.Key, nil
+	}
+	if a.KeyArg >= len(args) {
+		return "", fmt.Errorf("no value at index %d naming its context key", a.KeyArg)
+	}
+	key, ok := args[a.KeyArg].(string)
+	if !ok {
+		return "", fmt.Errorf("value at index %d naming its context key is not a string", a.KeyArg)
+	}
+	return key, nil
+}
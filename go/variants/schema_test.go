@@ -0,0 +1,93 @@
+package variants
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidateContext(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	if schema, ok := r.ConditionSchema("MOD_RANGE"); !ok || len(schema.Context) != 1 {
+		t.Errorf("ConditionSchema: expected MOD_RANGE to declare one context attribute, got %+v.", schema)
+	}
+	if schema, ok := r.ConditionSchema("RANDOM"); !ok || len(schema.Context) != 0 {
+		t.Errorf("ConditionSchema: expected RANDOM to declare no context attributes, got %+v.", schema)
+	}
+
+	testCases := []struct {
+		Context interface{}
+		Error   string
+	}{
+		{map[string]int{"user_id": 4}, ""},
+		{map[string]interface{}{"user_id": 4.0}, ""},
+		{map[string]interface{}{"user_id": "4"}, `context key "user_id" has value "4", expected type int`},
+		{nil, `context is missing key "user_id"`},
+	}
+	for _, tc := range testCases {
+		err := r.ValidateContext("mod_range", tc.Context)
+		if len(tc.Error) == 0 && err != nil {
+			t.Errorf("ValidateContext: expected no error for %v, got %q.", tc.Context, err.Error())
+		}
+		if len(tc.Error) > 0 && (err == nil || !strings.Contains(err.Error(), tc.Error)) {
+			t.Errorf("ValidateContext: expected error containing %q for %v, got %v.", tc.Error, tc.Context, err)
+		}
+	}
+	if err := r.ValidateContext("always_passes", nil); err != nil {
+		t.Errorf("ValidateContext: expected no error for a flag with context-free conditions, got %q.", err.Error())
+	}
+}
+
+func TestConditionTypes(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterConditionType("UNDESCRIBED", func(values ...interface{}) func(interface{}) bool {
+		return func(interface{}) bool { return true }
+	})
+	r.RegisterConditionTypeWithSchema("PLAN", ConditionSchema{
+		Context: []ContextAttr{{Key: "plan", Type: ContextTypeString}},
+		Args:    []ConditionArg{{Name: "plan", Type: ContextTypeString, Repeated: true}},
+	}, func(values ...interface{}) func(interface{}) bool {
+		return func(interface{}) bool { return true }
+	})
+
+	infos := map[string]ConditionTypeInfo{}
+	ids := []string{}
+	for _, info := range r.ConditionTypes() {
+		infos[info.ID] = info
+		ids = append(ids, info.ID)
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ConditionTypes: expected types ordered by ID, got %v.", ids)
+	}
+	for id := range NewRegistry().conditionSpecs {
+		if len(infos[id].Args) == 0 {
+			t.Errorf("ConditionTypes: expected built-in type %s to describe its values.", id)
+		}
+	}
+	if info, ok := infos["UNDESCRIBED"]; !ok || info.Args != nil {
+		t.Errorf("ConditionTypes: expected UNDESCRIBED without values, got %+v.", info)
+	}
+
+	testCases := []struct {
+		ID       string
+		Min, Max int
+	}{
+		{"RANDOM", 1, 1},
+		{"MOD_RANGE", 3, 3},
+		{"ID_SET", 2, 3},
+		{"WHITELIST", 2, -1},
+		{"LAUNCH", 2, -1},
+		{"HAS_KEY", 1, 1},
+		{"FLAG_EQUALS", 2, 2},
+		{"PLAN", 1, -1},
+		{"UNDESCRIBED", 0, -1},
+	}
+	for _, tc := range testCases {
+		if min, max := infos[tc.ID].ValueCount(); min != tc.Min || max != tc.Max {
+			t.Errorf("ValueCount: expected %s to take %d to %d values, got %d to %d.", tc.ID, tc.Min, tc.Max, min, max)
+		}
+	}
+}
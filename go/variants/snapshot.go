@@ -0,0 +1,250 @@
+package variants
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A snapshot is an immutable view of the flags and variants of a
+// Registry. Evaluations read the current snapshot without taking the
+// registry lock, while writers, serialized by the lock, modify a clone
+// and swap it in, so a reload is seen by an evaluation either entirely or
+// not at all.
+type snapshot struct {
+	// Currently registered variants mapped by ID.
+	variants map[string]Variant
+
+	// Registered variant flags mapped by name.
+	flags map[string]Flag
+
+	// Maps flag names to a set of variant IDs. Used to evaluate flag values.
+	flagToVariantIDMap map[string]map[string]struct{}
+
+	// Maps flag names to their variants in the order of sortedVariants,
+	// computed by index before the snapshot is stored so that
+	// evaluations neither sort nor allocate. Stale while the snapshot is
+	// being modified.
+	flagVariants map[string][]Variant
+
+	// Maps exclusion group names to the set of IDs of their variants.
+	exclusionGroups map[string]map[string]struct{}
+
+	// Attributes merged under every evaluation context.
+	defaultContext map[string]interface{}
+
+	// Enrollments in variants capped by MaxExposures.
+	exposures ExposureStore
+
+	// Whether map contexts are read with case-insensitive keys.
+	caseInsensitiveContext bool
+}
+
+// load returns the receiver's current snapshot, which must not be
+// modified.
+func (r *Registry) load() *snapshot {
+	return r.snap.Load().(*snapshot)
+}
+
+// update applies fn to a clone of the receiver's current snapshot and
+// swaps the clone in if fn succeeds. The receiver's lock must be held.
+func (r *Registry) update(fn func(s *snapshot) error) error {
+	s := r.load().clone()
+	if err := fn(s); err != nil {
+		return err
+	}
+	s.index()
+	r.snap.Store(s)
+	return nil
+}
+
+// clone returns a copy of the receiver that may be modified without
+// affecting it. Variants are copied by value, so their slices remain
+// shared and must not be modified.
+func (s *snapshot) clone() *snapshot {
+	c := &snapshot{
+		variants:           make(map[string]Variant, len(s.variants)),
+		flags:              make(map[string]Flag, len(s.flags)),
+		flagToVariantIDMap: make(map[string]map[string]struct{}, len(s.flagToVariantIDMap)),
+		exclusionGroups:    make(map[string]map[string]struct{}, len(s.exclusionGroups)),
+		defaultContext:     s.defaultContext,
+		exposures:          s.exposures,
+
+		caseInsensitiveContext: s.caseInsensitiveContext,
+	}
+	for id, v := range s.variants {
+		c.variants[id] = v
+	}
+	for name, f := range s.flags {
+		c.flags[name] = f
+	}
+	for name, ids := range s.flagToVariantIDMap {
+		c.flagToVariantIDMap[name] = copySet(ids)
+	}
+	for group, ids := range s.exclusionGroups {
+		c.exclusionGroups[group] = copySet(ids)
+	}
+	return c
+}
+
+func copySet(set map[string]struct{}) map[string]struct{} {
+	c := make(map[string]struct{}, len(set))
+	for k := range set {
+		c[k] = struct{}{}
+	}
+	return c
+}
+
+// sortedVariants returns the variants with the given IDs ordered by
+// descending priority, with ties broken by ascending ID.
+func (s *snapshot) sortedVariants(ids map[string]struct{}) []Variant {
+	vs := make([]Variant, 0, len(ids))
+	for id := range ids {
+		vs = append(vs, s.variants[id])
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		if vs[i].Priority != vs[j].Priority {
+			return vs[i].Priority > vs[j].Priority
+		}
+		return vs[i].ID < vs[j].ID
+	})
+	return vs
+}
+
+// index computes the receiver's flagVariants. The receiver must not be
+// modified afterwards.
+func (s *snapshot) index() {
+	s.flagVariants = make(map[string][]Variant, len(s.flagToVariantIDMap))
+	for name, ids := range s.flagToVariantIDMap {
+		if len(ids) > 0 {
+			s.flagVariants[name] = s.sortedVariants(ids)
+		}
+	}
+}
+
+// addFlag registers f with the receiver, returning an error if a flag
+// already exists with the same name, f is invalid, or it would close a
+// dependency cycle.
+func (s *snapshot) addFlag(f Flag) error {
+	if err := checkCombine(f); err != nil {
+		return err
+	}
+	if err := checkValueType(&f); err != nil {
+		return err
+	}
+	if _, present := s.flags[f.Name]; present {
+		return fmt.Errorf("Variant flag with the name %q is already registered.", f.Name)
+	}
+	if err := s.dependencyCycle(f); err != nil {
+		return err
+	}
+	s.flags[f.Name] = f
+	s.flagToVariantIDMap[f.Name] = map[string]struct{}{}
+	return nil
+}
+
+// addFlags registers each of fs as addFlag does, then checks that their
+// prerequisites are registered, with the receiver or among fs.
+func (s *snapshot) addFlags(fs []Flag) error {
+	for _, f := range fs {
+		if err := s.addFlag(f); err != nil {
+			return err
+		}
+	}
+	return s.checkDependencies(flagNames(fs), nil)
+}
+
+// addNewVariants registers each of vs as addNewVariant does.
+func (s *snapshot) addNewVariants(vs []Variant) error {
+	for _, v := range vs {
+		if err := s.addNewVariant(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addNewVariant registers v with the receiver, returning an error if a
+// variant already exists with the same ID, v is invalid, or its
+// conditions would close a dependency cycle.
+func (s *snapshot) addNewVariant(v Variant) error {
+	if err := checkOperators(&v); err != nil {
+		return err
+	}
+	if err := checkUnconditional(&v); err != nil {
+		return err
+	}
+	if err := checkArms(&v); err != nil {
+		return err
+	}
+	if _, found := s.variants[v.ID]; found {
+		return fmt.Errorf("Variant already registered with the ID %q", v.ID)
+	}
+	if err := s.addVariant(v); err != nil {
+		return err
+	}
+	if len(v.comparedFlags()) == 0 {
+		return nil
+	}
+	for _, m := range v.Mods {
+		if err := s.dependencyCycle(s.flags[m.FlagName]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addVariant registers v with the receiver, which must not have a
+// variant with the same ID.
+func (s *snapshot) addVariant(v Variant) error {
+	mods := make([]Mod, len(v.Mods))
+	for i, m := range v.Mods {
+		f, found := s.flags[m.FlagName]
+		if !found {
+			return fmt.Errorf("Flag with the name %q has not been registered.", m.FlagName)
+		}
+		val, ok := coerceValue(f.ValueType, m.Value)
+		if !ok {
+			return fmt.Errorf("Mod of flag %q in variant %q has value %v, not of type %q.", m.FlagName, v.ID, m.Value, f.ValueType)
+		}
+		mods[i] = m
+		mods[i].Value = val
+		if len(m.ArmValues) > 0 {
+			mods[i].ArmValues = make([]interface{}, len(m.ArmValues))
+		}
+		for j, armVal := range m.ArmValues {
+			val, ok := coerceValue(f.ValueType, armVal)
+			if !ok {
+				return fmt.Errorf("Mod of flag %q in variant %q has arm value %v, not of type %q.", m.FlagName, v.ID, armVal, f.ValueType)
+			}
+			mods[i].ArmValues[j] = val
+		}
+	}
+	v.Mods = mods
+	for _, m := range v.Mods {
+		s.flagToVariantIDMap[m.FlagName][v.ID] = struct{}{}
+	}
+	if len(v.ExclusionGroup) > 0 {
+		if s.exclusionGroups[v.ExclusionGroup] == nil {
+			s.exclusionGroups[v.ExclusionGroup] = map[string]struct{}{}
+		}
+		s.exclusionGroups[v.ExclusionGroup][v.ID] = struct{}{}
+	}
+	s.variants[v.ID] = v
+	return nil
+}
+
+// deleteVariant removes the variant with the given ID and its references
+// from the receiver.
+func (s *snapshot) deleteVariant(id string) {
+	v, found := s.variants[id]
+	if !found {
+		return
+	}
+	for _, m := range v.Mods {
+		delete(s.flagToVariantIDMap[m.FlagName], id)
+	}
+	if len(v.ExclusionGroup) > 0 {
+		delete(s.exclusionGroups[v.ExclusionGroup], id)
+	}
+	delete(s.variants, id)
+}
@@ -0,0 +1,126 @@
+package variants
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// versionedConfig returns a config whose two variants both append
+// version to the value of the flag "versions".
+func versionedConfig(version int) []byte {
+	return []byte(fmt.Sprintf(`{
+	  "flag_defs": [{"flag": "versions", "base_value": [], "combine": "append"}],
+	  "variants": [{
+	    "id": "A",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "versions", "value": %d}]
+	  }, {
+	    "id": "B",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "versions", "value": %d}]
+	  }]
+	}`, version, version))
+}
+
+func TestReloadIsAtomic(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadJSON(versionedConfig(0)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				vs, ok := r.FlagValue("versions").([]interface{})
+				if !ok || len(vs) != 2 || vs[0] != vs[1] {
+					t.Errorf("FlagValue: expected the values of one reload, got %v.", vs)
+					return
+				}
+			}
+		}()
+	}
+	for version := 1; version <= 200; version++ {
+		if err := r.ReloadJSON(versionedConfig(version)); err != nil {
+			t.Fatalf("ReloadJSON: expected no error, but got %q.", err.Error())
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+// BenchmarkFlagValueWithReloads measures evaluations made concurrently
+// with a stream of reloads, and, for comparison, without any.
+func BenchmarkFlagValueWithReloads(b *testing.B) {
+	for _, reloading := range []bool{false, true} {
+		name := "idle"
+		if reloading {
+			name = "reloading"
+		}
+		b.Run(name, func(b *testing.B) {
+			r := NewRegistry()
+			if err := r.LoadJSON(versionedConfig(0)); err != nil {
+				b.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+			}
+			done := make(chan struct{})
+			reloaded := make(chan struct{})
+			go func() {
+				defer close(reloaded)
+				for version := 1; reloading; version++ {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					r.ReloadJSON(versionedConfig(version))
+				}
+			}()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					r.FlagValue("versions")
+				}
+			})
+			b.StopTimer()
+			close(done)
+			<-reloaded
+		})
+	}
+}
+
+// BenchmarkFlagValueWithContext measures evaluating a flag modified by
+// one variant, the common case, and by several.
+func BenchmarkFlagValueWithContext(b *testing.B) {
+	for _, n := range []int{1, 4} {
+		b.Run(fmt.Sprintf("variants=%d", n), func(b *testing.B) {
+			r := NewRegistry()
+			if err := r.AddFlag(Flag{Name: "flag", BaseValue: false}); err != nil {
+				b.Fatalf("AddFlag: expected no error, but got %q.", err.Error())
+			}
+			for i := 0; i < n; i++ {
+				err := r.AddVariant(Variant{
+					ID:         fmt.Sprintf("V%d", i),
+					Conditions: []Condition{modRangeCondition("id")},
+					Mods:       []Mod{{FlagName: "flag", Value: true}},
+				})
+				if err != nil {
+					b.Fatalf("AddVariant: expected no error, but got %q.", err.Error())
+				}
+			}
+			context := map[string]int{"id": 50}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.FlagValueWithContext("flag", context)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package variants
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A ConditionSpec constructs the evaluators for a condition type. It is
+// an alternative to registering a function with RegisterConditionType for
+// condition types that carry their own state.
+type ConditionSpec interface {
+	// Evaluator returns the function evaluating a condition with the
+	// given values, or nil if the values are invalid.
+	Evaluator(values ...interface{}) func(context interface{}) bool
+}
+
+// An InitConditionSpec is a ConditionSpec that must complete some
+// potentially slow setup, such as loading a data set or opening a
+// connection, before its evaluators return correct results.
+type InitConditionSpec interface {
+	ConditionSpec
+
+	// Init prepares the spec. It is called once, in its own goroutine,
+	// after the spec is registered.
+	Init(ctx context.Context) error
+}
+
+// An InitPolicy determines how conditions of an InitConditionSpec
+// evaluate before the spec has finished initializing, or if its
+// initialization failed.
+type InitPolicy int
+
+const (
+	// FailClosed conditions evaluate to false until initialized.
+	FailClosed InitPolicy = iota
+	// FailOpen conditions evaluate to true until initialized.
+	FailOpen
+)
+
+type specInit struct {
+	id    string
+	ready int32
+	done  chan struct{}
+	err   error
+}
+
+// RegisterContextualConditionType registers a condition type whose
+// evaluators are given the DefaultRegistry.
+func RegisterContextualConditionType(id string, fn func(r *Registry, values ...interface{}) func(context interface{}) bool) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterContextualConditionType(id, fn)
+}
+
+// RegisterContextualConditionType is like RegisterConditionType for
+// condition types that need the registry evaluating them, for example to
+// resolve other flags. fn is called with the receiver, or, for a config
+// reloaded into the receiver, with the receiver rather than the registry
+// the config is first loaded into. Conditions must not resolve, directly
+// or through other flags, a flag their variant modifies.
+func (r *Registry) RegisterContextualConditionType(id string, fn func(r *Registry, values ...interface{}) func(context interface{}) bool) error {
+	err := r.RegisterConditionType(id, func(values ...interface{}) func(interface{}) bool {
+		return fn(r, values...)
+	})
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.contextualSpecs[strings.ToUpper(id)] = fn
+	return nil
+}
+
+// RegisterConditionTypeOnce registers a condition type whose evaluators
+// are memoized by their values with the DefaultRegistry.
+func RegisterConditionTypeOnce(id string, fn func(...interface{}) func(interface{}) bool) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterConditionTypeOnce(id, fn)
+}
+
+// RegisterConditionTypeOnce is like RegisterConditionType, but calls fn
+// only once for each distinct set of values, sharing the evaluator it
+// returns among every condition with those values, across loads and
+// reloads, so that expensive setup such as compiling a regular
+// expression or loading a database happens once. Values are told apart
+// by their JSON encoding; those that cannot be encoded are never
+// memoized. Up to maxMemoizedEvaluators evaluators are memoized per
+// type, evicting the least recently used, so that reloads cycling
+// through values do not grow the memo without bound; conditions keep
+// evaluators evicted meanwhile. fn is called without any lock of the memo
+// held, so that evaluators with different values are constructed
+// concurrently. A shared evaluator is called concurrently by every
+// condition using it, so it must be safe for concurrent use.
+func (r *Registry) RegisterConditionTypeOnce(id string, fn func(...interface{}) func(interface{}) bool) error {
+	memo := newEvaluatorMemo(maxMemoizedEvaluators)
+	return r.RegisterConditionType(id, func(values ...interface{}) func(interface{}) bool {
+		data, err := json.Marshal(values)
+		if err != nil {
+			return fn(values...)
+		}
+		return memo.get(string(data), func() func(interface{}) bool {
+			return fn(values...)
+		})
+	})
+}
+
+// maxMemoizedEvaluators bounds the evaluators memoized for each condition
+// type registered with RegisterConditionTypeOnce.
+const maxMemoizedEvaluators = 1024
+
+// An evaluatorMemo memoizes evaluators by key, evicting the least
+// recently used beyond its capacity.
+type evaluatorMemo struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	recent   *list.List // of *memoEntry, most recently used first
+}
+
+// A memoEntry is an evaluator constructed once for its key.
+type memoEntry struct {
+	key  string
+	once sync.Once
+	eval func(interface{}) bool
+}
+
+func newEvaluatorMemo(capacity int) *evaluatorMemo {
+	return &evaluatorMemo{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		recent:   list.New(),
+	}
+}
+
+// get returns the evaluator memoized under key, calling construct to
+// construct it if there is none. Callers asking for the same key at once
+// wait for a single call to construct. A nil evaluator is not memoized.
+func (m *evaluatorMemo) get(key string, construct func() func(interface{}) bool) func(interface{}) bool {
+	m.mu.Lock()
+	var e *memoEntry
+	if el, found := m.entries[key]; found {
+		m.recent.MoveToFront(el)
+		e = el.Value.(*memoEntry)
+	} else {
+		e = &memoEntry{key: key}
+		m.entries[key] = m.recent.PushFront(e)
+		if m.recent.Len() > m.capacity {
+			oldest := m.recent.Back()
+			m.recent.Remove(oldest)
+			delete(m.entries, oldest.Value.(*memoEntry).key)
+		}
+	}
+	m.mu.Unlock()
+
+	defer func() {
+		if e.eval == nil {
+			m.forget(e)
+		}
+	}()
+	e.once.Do(func() {
+		e.eval = construct()
+	})
+	return e.eval
+}
+
+// forget removes e, if still memoized.
+func (m *evaluatorMemo) forget(e *memoEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, found := m.entries[e.key]; found && el.Value == e {
+		m.recent.Remove(el)
+		delete(m.entries, e.key)
+	}
+}
+
+// RegisterConditionSpec registers spec under id with the DefaultRegistry.
+func RegisterConditionSpec(id string, spec ConditionSpec, policy InitPolicy) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterConditionSpec(id, spec, policy)
+}
+
+// Warm waits for the condition specs of the DefaultRegistry to initialize.
+func Warm(ctx context.Context) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.Warm(ctx)
+}
+
+// RegisterConditionSpec registers a condition type with the given ID whose
+// evaluators are constructed by spec. If spec is an InitConditionSpec,
+// its Init method is started immediately, and until it returns without
+// error the type's conditions evaluate according to policy.
+func (r *Registry) RegisterConditionSpec(id string, spec ConditionSpec, policy InitPolicy) error {
+	initSpec, ok := spec.(InitConditionSpec)
+	if !ok {
+		return r.RegisterConditionType(id, spec.Evaluator)
+	}
+
+	state := &specInit{id: id, done: make(chan struct{})}
+	err := r.RegisterConditionType(id, func(values ...interface{}) func(interface{}) bool {
+		eval := spec.Evaluator(values...)
+		if eval == nil {
+			return nil
+		}
+		return func(context interface{}) bool {
+			if atomic.LoadInt32(&state.ready) == 0 {
+				return policy == FailOpen
+			}
+			return eval(context)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.specInits = append(r.specInits, state)
+	r.Unlock()
+	go func() {
+		defer close(state.done)
+		if state.err = initSpec.Init(context.Background()); state.err == nil {
+			atomic.StoreInt32(&state.ready, 1)
+		}
+	}()
+	return nil
+}
+
+// Warm blocks until every InitConditionSpec registered with the receiver
+// has finished initializing or ctx is done. It returns the first
+// initialization error encountered, or ctx's error.
+func (r *Registry) Warm(ctx context.Context) error {
+	r.RLock()
+	inits := r.specInits
+	r.RUnlock()
+	for _, state := range inits {
+		select {
+		case <-state.done:
+			if state.err != nil {
+				return state.err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// A ConditionEvaluator evaluates conditions of a type given each
+// condition's values. Implementing it on a struct lets a condition's
+// dependencies be injected and tested directly.
+type ConditionEvaluator interface {
+	Evaluate(values []interface{}, context interface{}) bool
+}
+
+// RegisterConditionImpl registers impl for the condition type with the
+// given ID with the DefaultRegistry.
+func RegisterConditionImpl(id string, impl ConditionEvaluator) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.RegisterConditionImpl(id, impl)
+}
+
+// RegisterConditionImpl registers a condition type with the given ID whose
+// conditions are evaluated by impl, passing each condition's values.
+func (r *Registry) RegisterConditionImpl(id string, impl ConditionEvaluator) error {
+	return r.RegisterConditionType(id, func(values ...interface{}) func(interface{}) bool {
+		values = append([]interface{}(nil), values...)
+		return func(context interface{}) bool {
+			return impl.Evaluate(values, context)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package variants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type slowSpec struct {
+	release chan struct{}
+	err     error
+}
+
+func (s *slowSpec) Evaluator(values ...interface{}) func(interface{}) bool {
+	return func(interface{}) bool { return true }
+}
+
+func (s *slowSpec) Init(ctx context.Context) error {
+	<-s.release
+	return s.err
+}
+
+func TestConditionSpecInit(t *testing.T) {
+	config := `{
+	  "flag_defs": [{"flag": "warm", "base_value": false}],
+	  "variants": [{
+	    "id": "Warm",
+	    "conditions": [{"type": "SLOW", "value": null}],
+	    "mods": [{"flag": "warm", "value": true}]
+	  }]
+	}`
+	for _, policy := range []InitPolicy{FailClosed, FailOpen} {
+		r := NewRegistry()
+		spec := &slowSpec{release: make(chan struct{})}
+		if err := r.RegisterConditionSpec("SLOW", spec, policy); err != nil {
+			t.Fatalf("RegisterConditionSpec: expected no error, but got %q.", err.Error())
+		}
+		if err := r.LoadJSON([]byte(config)); err != nil {
+			t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+		}
+		if v := r.FlagValue("warm"); v != (policy == FailOpen) {
+			t.Errorf("FlagValue: expected uninitialized condition to return %t, got %v.", policy == FailOpen, v)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := r.Warm(ctx); err != context.Canceled {
+			t.Errorf("Warm: expected context.Canceled, got %v.", err)
+		}
+
+		close(spec.release)
+		if err := r.Warm(context.Background()); err != nil {
+			t.Fatalf("Warm: expected no error, but got %q.", err.Error())
+		}
+		if v := r.FlagValue("warm"); v != true {
+			t.Errorf("FlagValue: expected initialized condition to return true, got %v.", v)
+		}
+	}
+}
+
+func TestConditionSpecInitError(t *testing.T) {
+	r := NewRegistry()
+	spec := &slowSpec{release: make(chan struct{}), err: errors.New("no database")}
+	close(spec.release)
+	if err := r.RegisterConditionSpec("SLOW", spec, FailClosed); err != nil {
+		t.Fatalf("RegisterConditionSpec: expected no error, but got %q.", err.Error())
+	}
+	if err := r.Warm(context.Background()); err != spec.err {
+		t.Errorf("Warm: expected %q, got %v.", spec.err, err)
+	}
+}
+
+type planLookup interface {
+	Plan(userID string) string
+}
+
+type fakePlans map[string]string
+
+func (p fakePlans) Plan(userID string) string { return p[userID] }
+
+type planCondition struct {
+	plans planLookup
+}
+
+func (c planCondition) Evaluate(values []interface{}, context interface{}) bool {
+	ctx, ok := context.(map[string]string)
+	if !ok {
+		return false
+	}
+	plan := c.plans.Plan(ctx["user_id"])
+	for _, v := range values {
+		if v == plan {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRegisterConditionImpl(t *testing.T) {
+	r := NewRegistry()
+	impl := planCondition{plans: fakePlans{"andybons": "enterprise", "tessr": "free"}}
+	if err := r.RegisterConditionImpl("PLAN", impl); err != nil {
+		t.Fatalf("RegisterConditionImpl: expected no error, but got %q.", err.Error())
+	}
+	config := `{
+	  "flag_defs": [{"flag": "sso", "base_value": false}],
+	  "variants": [{
+	    "id": "SSO",
+	    "conditions": [{"type": "PLAN", "values": ["enterprise", "team"]}],
+	    "mods": [{"flag": "sso", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	testCases := map[string]bool{
+		"andybons": true,
+		"tessr":    false,
+	}
+	for userID, expected := range testCases {
+		if v := r.FlagValueWithContext("sso", map[string]string{"user_id": userID}); v != expected {
+			t.Errorf("FlagValueWithContext: expected sso for %s to return %t, got %v.", userID, expected, v)
+		}
+	}
+}
+
+func TestRegisterConditionTypeOnce(t *testing.T) {
+	r := NewRegistry()
+	var constructed, evaluated int32
+	err := r.RegisterConditionTypeOnce("PATTERN", func(values ...interface{}) func(interface{}) bool {
+		atomic.AddInt32(&constructed, 1)
+		re, err := regexp.Compile(values[0].(string))
+		if err != nil {
+			return nil
+		}
+		return func(context interface{}) bool {
+			atomic.AddInt32(&evaluated, 1)
+			s, _ := context.(string)
+			return re.MatchString(s)
+		}
+	})
+	if err != nil {
+		t.Fatalf("RegisterConditionTypeOnce: expected no error, but got %q.", err.Error())
+	}
+	config := `{
+	  "flag_defs": [{"flag": "a", "base_value": false}, {"flag": "b", "base_value": false}, {"flag": "c", "base_value": false}],
+	  "variants": [{
+	    "id": "A",
+	    "conditions": [{"type": "PATTERN", "value": "^admin-"}],
+	    "mods": [{"flag": "a", "value": true}]
+	  }, {
+	    "id": "B",
+	    "conditions": [{"type": "PATTERN", "value": "^admin-"}],
+	    "mods": [{"flag": "b", "value": true}]
+	  }, {
+	    "id": "C",
+	    "conditions": [{"type": "PATTERN", "value": "-test$"}],
+	    "mods": [{"flag": "c", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if err := r.ReloadJSON([]byte(config)); err != nil {
+		t.Fatalf("ReloadJSON: expected no error, but got %q.", err.Error())
+	}
+	if constructed != 2 {
+		t.Errorf("RegisterConditionTypeOnce: expected one evaluator per distinct value, got %d constructed.", constructed)
+	}
+
+	// Evaluate the shared evaluators from many goroutines at once, which
+	// the race detector checks.
+	const goroutines, calls = 32, 500
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < calls; j++ {
+				ctx := fmt.Sprintf("admin-%d", i)
+				if r.FlagValueWithContext("a", ctx) != true || r.FlagValueWithContext("c", ctx) != false {
+					t.Errorf("FlagValueWithContext: expected only a to be true for %q.", ctx)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if expected := int32(2 * goroutines * calls); evaluated != expected {
+		t.Errorf("FlagValueWithContext: expected %d evaluations, got %d.", expected, evaluated)
+	}
+
+	bad := `{"flag_defs": [{"flag": "d", "base_value": false}], "variants": [{"id": "D", "conditions": [{"type": "PATTERN", "value": "("}], "mods": [{"flag": "d", "value": true}]}]}`
+	if err := r.LoadJSON([]byte(bad)); err == nil {
+		t.Error("LoadJSON: expected an error for an invalid pattern, but got nil.")
+	}
+}
+
+func TestEvaluatorMemo(t *testing.T) {
+	memo := newEvaluatorMemo(2)
+	constructed := map[string]int{}
+	get := func(key string) func(interface{}) bool {
+		return memo.get(key, func() func(interface{}) bool {
+			constructed[key]++
+			if key == "invalid" {
+				return nil
+			}
+			return func(interface{}) bool { return true }
+		})
+	}
+	for _, key := range []string{"a", "b", "a", "c", "b", "a"} {
+		get(key)
+	}
+	if len(memo.entries) != 2 || memo.recent.Len() != 2 {
+		t.Errorf("get: expected 2 memoized evaluators, got %d.", len(memo.entries))
+	}
+	get("invalid")
+	get("invalid")
+	expected := map[string]int{"a": 2, "b": 2, "c": 1, "invalid": 2}
+	if fmt.Sprint(constructed) != fmt.Sprint(expected) {
+		t.Errorf("get: expected the least recently used evaluators to be evicted, got constructions %v.", constructed)
+	}
+
+	// A slow construction blocks only callers asking for the same key.
+	var slowConstructed int32
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := func() func(interface{}) bool {
+		atomic.AddInt32(&slowConstructed, 1)
+		close(started)
+		<-release
+		return func(interface{}) bool { return true }
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			memo.get("slow", slow)
+		}()
+	}
+	<-started
+	memo.get("fast", func() func(interface{}) bool {
+		return func(interface{}) bool { return false }
+	})
+	close(release)
+	wg.Wait()
+	if slowConstructed != 1 {
+		t.Errorf("get: expected concurrent callers to share one evaluator, got %d constructed.", slowConstructed)
+	}
+}
@@ -0,0 +1,117 @@
+package variants
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrNoTokenKey is returned when creating or applying an assignment
+// token with a Registry that has no token key set.
+var ErrNoTokenKey = errors.New("No assignment token key has been set.")
+
+// ErrInvalidToken is returned when an assignment token is malformed
+// or its signature does not match.
+var ErrInvalidToken = errors.New("Invalid assignment token.")
+
+// SetTokenKey sets the key used to sign assignment tokens with the DefaultRegistry.
+func SetTokenKey(key []byte) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetTokenKey(key)
+}
+
+// AssignmentToken returns a signed token of the variant assignments for
+// context within the DefaultRegistry.
+func AssignmentToken(context interface{}) (string, error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.AssignmentToken(context)
+}
+
+// ApplyAssignmentToken verifies token with the DefaultRegistry and returns
+// the forced variants it encodes.
+func ApplyAssignmentToken(token string) (map[string]bool, error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ApplyAssignmentToken(token)
+}
+
+// SetTokenKey sets the key used to sign and verify assignment tokens.
+// Every service exchanging tokens must use the same key.
+func (r *Registry) SetTokenKey(key []byte) {
+	r.Lock()
+	defer r.Unlock()
+	r.tokenKey = append([]byte(nil), key...)
+}
+
+// AssignmentToken evaluates every registered variant against context and
+// returns a compact, signed token recording which were active. Another
+// service can pass the token to ApplyAssignmentToken to honor the same
+// assignments, even for variants with nondeterministic conditions. A
+// variant is active as it is for ActiveVariants: it must win its
+// exclusion group and, if split into arms, the context must have its arm
+// key, and a capped variant is active only for a subject already
+// enrolled. No subject is enrolled by the call.
+func (r *Registry) AssignmentToken(context interface{}) (string, error) {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.tokenKey) == 0 {
+		return "", ErrNoTokenKey
+	}
+	s := r.load()
+	st := &evalState{
+		snap:    s,
+		context: s.mergeDefaultContext(context),
+		results: map[string]bool{},
+		dryRun:  true,
+	}
+	assignments := make(map[string]bool, len(s.variants))
+	for id, v := range s.variants {
+		assignments[id] = r.isActive(v, st)
+	}
+	payload, err := json.Marshal(assignments)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(r.sign(payload)), nil
+}
+
+// ApplyAssignmentToken verifies token and returns the assignments it
+// encodes as forced variants, suitable for passing to
+// FlagValueWithContextWithForcedVariants.
+func (r *Registry) ApplyAssignmentToken(token string) (map[string]bool, error) {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.tokenKey) == 0 {
+		return nil, ErrNoTokenKey
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, r.sign(payload)) {
+		return nil, ErrInvalidToken
+	}
+	assignments := map[string]bool{}
+	if err := json.Unmarshal(payload, &assignments); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return assignments, nil
+}
+
+func (r *Registry) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, r.tokenKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,93 @@
+package variants
+
+import "testing"
+
+func TestAssignmentToken(t *testing.T) {
+	serviceA, serviceB := NewRegistry(), NewRegistry()
+	for _, r := range []*Registry{serviceA, serviceB} {
+		if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+			t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+		}
+	}
+	if _, err := serviceA.AssignmentToken(nil); err != ErrNoTokenKey {
+		t.Errorf("AssignmentToken: expected ErrNoTokenKey, got %v.", err)
+	}
+	serviceA.SetTokenKey([]byte("secret"))
+	serviceB.SetTokenKey([]byte("secret"))
+
+	token, err := serviceA.AssignmentToken(nil)
+	if err != nil {
+		t.Fatalf("AssignmentToken: expected no error, but got %q.", err.Error())
+	}
+	forced, err := serviceB.ApplyAssignmentToken(token)
+	if err != nil {
+		t.Fatalf("ApplyAssignmentToken: expected no error, but got %q.", err.Error())
+	}
+	expected := forced["CoinFlipTest"]
+	for i := 0; i < 10; i++ {
+		if v := serviceB.FlagValueWithContextWithForcedVariants("coin_flip", nil, forced); v != expected {
+			t.Fatalf("FlagValueWithContextWithForcedVariants: expected coin_flip to be %v, got %v.", expected, v)
+		}
+	}
+
+	serviceB.SetTokenKey([]byte("other secret"))
+	if _, err := serviceB.ApplyAssignmentToken(token); err != ErrInvalidToken {
+		t.Errorf("ApplyAssignmentToken: expected ErrInvalidToken for a bad signature, got %v.", err)
+	}
+	if _, err := serviceB.ApplyAssignmentToken("garbage"); err != ErrInvalidToken {
+		t.Errorf("ApplyAssignmentToken: expected ErrInvalidToken for a malformed token, got %v.", err)
+	}
+}
+
+func TestAssignmentTokenActiveVariants(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [{"flag": "beta", "base_value": false}, {"flag": "layout", "base_value": "old"}],
+	  "variants": [{
+	    "id": "Beta",
+	    "unconditional": true,
+	    "mods": [{"flag": "beta", "value": true}],
+	    "max_exposures": 1,
+	    "exposure_key": "id"
+	  }, {
+	    "id": "LayoutA",
+	    "unconditional": true,
+	    "priority": 1,
+	    "exclusion_group": "layout",
+	    "mods": [{"flag": "layout", "value": "a"}]
+	  }, {
+	    "id": "LayoutB",
+	    "unconditional": true,
+	    "exclusion_group": "layout",
+	    "mods": [{"flag": "layout", "value": "b"}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	r.SetTokenKey([]byte("secret"))
+	if v := r.FlagValueWithContext("beta", map[string]string{"id": "a"}); v != true {
+		t.Fatalf("FlagValueWithContext: expected a to be enrolled in Beta, got %v.", v)
+	}
+
+	testCases := map[string]bool{"a": true, "b": false}
+	for id, beta := range testCases {
+		token, err := r.AssignmentToken(map[string]string{"id": id})
+		if err != nil {
+			t.Fatalf("AssignmentToken: expected no error, but got %q.", err.Error())
+		}
+		forced, err := r.ApplyAssignmentToken(token)
+		if err != nil {
+			t.Fatalf("ApplyAssignmentToken: expected no error, but got %q.", err.Error())
+		}
+		expected := map[string]bool{"Beta": beta, "LayoutA": true, "LayoutB": false}
+		for variantID, active := range expected {
+			if forced[variantID] != active {
+				t.Errorf("AssignmentToken: expected %s to be %t for %q, got %v.", variantID, active, id, forced)
+			}
+		}
+	}
+	if v := r.FlagValueWithContext("beta", map[string]string{"id": "b"}); v != false {
+		t.Errorf("FlagValueWithContext: expected the token not to enroll b, got %v.", v)
+	}
+}
@@ -0,0 +1,236 @@
+package variants
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Types of flag values, declared by Flag.ValueType.
+const (
+	// ValueTypeBool values are bools.
+	ValueTypeBool = "bool"
+	// ValueTypeInt values are integers, stored as ints.
+	ValueTypeInt = "int"
+	// ValueTypeString values are strings.
+	ValueTypeString = "string"
+	// ValueTypeJSON values are any JSON values, such as objects of
+	// structured config, typically read with JSONValue.
+	ValueTypeJSON = "json"
+)
+
+// checkValueType returns an error if f has an unknown value type or a
+// base value not of its type, converting the base value to the type's
+// representation otherwise.
+func checkValueType(f *Flag) error {
+	switch f.ValueType {
+	case "", ValueTypeBool, ValueTypeInt, ValueTypeString, ValueTypeJSON:
+	default:
+		return fmt.Errorf("Flag %q has unknown value type %q.", f.Name, f.ValueType)
+	}
+	val, ok := coerceValue(f.ValueType, f.BaseValue)
+	if !ok {
+		return fmt.Errorf("Base value of flag %q is %v, not of type %q.", f.Name, f.BaseValue, f.ValueType)
+	}
+	f.BaseValue = val
+	return nil
+}
+
+// coerceValue returns v converted to the representation of valueType,
+// and whether it is of that type. A nil value is of every type.
+func coerceValue(valueType string, v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, true
+	}
+	switch valueType {
+	case ValueTypeBool:
+		_, ok := v.(bool)
+		return v, ok
+	case ValueTypeInt:
+		f, ok := toFloat(v)
+		n, intOK := toInt(v)
+		if !ok || !intOK || float64(n) != f {
+			return nil, false
+		}
+		return n, true
+	case ValueTypeString:
+		_, ok := v.(string)
+		return v, ok
+	}
+	return v, true
+}
+
+// BoolValue returns the value of the named flag within the DefaultRegistry
+// as a bool.
+func BoolValue(name string) (bool, bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.BoolValue(name)
+}
+
+// IntValue returns the value of the named flag within the DefaultRegistry
+// as an int.
+func IntValue(name string) (int, bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.IntValue(name)
+}
+
+// Float64Value returns the value of the named flag within the
+// DefaultRegistry as a float64.
+func Float64Value(name string) (float64, bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.Float64Value(name)
+}
+
+// StringValue returns the value of the named flag within the
+// DefaultRegistry as a string.
+func StringValue(name string) (string, bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.StringValue(name)
+}
+
+// BoolValue returns the value of the named flag based on a nil context,
+// and whether it is a bool.
+func (r *Registry) BoolValue(name string) (bool, bool) {
+	b, ok := r.FlagValue(name).(bool)
+	return b, ok
+}
+
+// IntValue returns the value of the named flag based on a nil context,
+// and whether it is an integer. Since JSON numbers are loaded as float64,
+// a float64 with no fractional part is converted; any other float64 is
+// not an integer.
+func (r *Registry) IntValue(name string) (int, bool) {
+	v := r.FlagValue(name)
+	f, ok := toFloat(v)
+	if !ok {
+		return 0, false
+	}
+	n, ok := toInt(v)
+	if !ok || float64(n) != f {
+		return 0, false
+	}
+	return n, true
+}
+
+// Float64Value returns the value of the named flag based on a nil context,
+// and whether it is a number, converted to a float64.
+func (r *Registry) Float64Value(name string) (float64, bool) {
+	return toFloat(r.FlagValue(name))
+}
+
+// StringValue returns the value of the named flag based on a nil context,
+// and whether it is a string. Numbers are not converted.
+func (r *Registry) StringValue(name string) (string, bool) {
+	s, ok := r.FlagValue(name).(string)
+	return s, ok
+}
+
+// SetStrictTypes sets whether the DefaultRegistry checks the kinds of mod
+// values.
+func SetStrictTypes(strict bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	DefaultRegistry.SetStrictTypes(strict)
+}
+
+// SetStrictTypes sets whether the receiver checks, from now on, that mod
+// values are of the same kind as the base values of their flags, as
+// LoadOptions.StrictTypes does for a single load, when variants are
+// added with AddVariant, AddVariants, or AddBatch, and when configs are
+// loaded or reloaded. A reload also fails if it changes the kind of a
+// flag's base value from that of its variants' mods.
+func (r *Registry) SetStrictTypes(strict bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.strictTypes = strict
+}
+
+// checkStrictTypes returns an error if the receiver has strict types and
+// a mod of vs, or of every variant of s if vs is nil, is not of the same
+// kind as the base value of its flag. It must be called with the
+// receiver's lock held.
+func (r *Registry) checkStrictTypes(s *snapshot, vs []Variant) error {
+	if !r.strictTypes {
+		return nil
+	}
+	if vs == nil {
+		vs = s.variantList()
+	}
+	for i := range vs {
+		if err := s.checkModKinds(&vs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkModKinds returns an error if the value, or any arm value, of any
+// of v's mods is not of the same kind as the base value of its flag.
+func (s *snapshot) checkModKinds(v *Variant) error {
+	for _, m := range v.Mods {
+		f, found := s.flags[m.FlagName]
+		if !found || f.BaseValue == nil {
+			continue
+		}
+		for _, val := range append([]interface{}{m.Value}, m.ArmValues...) {
+			if val == nil {
+				continue
+			}
+			if want, got := valueKind(f.BaseValue), valueKind(val); got != want {
+				return fmt.Errorf("Mod of flag %q in variant %q has a value of kind %s, but the flag's base value is of kind %s.", m.FlagName, v.ID, got, want)
+			}
+		}
+	}
+	return nil
+}
+
+// valueKind returns the kind of v: "bool", "number", "string", "list",
+// or "object" for the kinds of JSON values, or v's type otherwise.
+func valueKind(v interface{}) string {
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// JSONValue stores the value of the named flag within the DefaultRegistry
+// in the value pointed to by out.
+func JSONValue(name string, out interface{}) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.JSONValue(name, out)
+}
+
+// JSONValue stores the value of the named flag based on a nil context in
+// the value pointed to by out, such as a struct, by encoding it as JSON
+// and decoding the encoding into out. This gives typed access to
+// structured values, which are otherwise loaded as
+// map[string]interface{}. An error is returned if the flag is not
+// registered or its value cannot be decoded into out.
+func (r *Registry) JSONValue(name string, out interface{}) error {
+	if _, found := r.load().flags[name]; !found {
+		return fmt.Errorf("Flag with the name %q has not been registered.", name)
+	}
+	data, err := json.Marshal(r.FlagValue(name))
+	if err != nil {
+		return fmt.Errorf("Value of flag %q: %v", name, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("Value of flag %q: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package variants
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTypedValues(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "enabled", "base_value": true},
+	    {"flag": "limit", "base_value": 25},
+	    {"flag": "ratio", "base_value": 0.25},
+	    {"flag": "title", "base_value": "Hello"}
+	  ]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+
+	if b, ok := r.BoolValue("enabled"); !b || !ok {
+		t.Errorf("BoolValue: expected true, got %v (%v).", b, ok)
+	}
+	if b, ok := r.BoolValue("title"); b || ok {
+		t.Errorf("BoolValue: expected no bool for a string flag, got %v (%v).", b, ok)
+	}
+	if n, ok := r.IntValue("limit"); n != 25 || !ok {
+		t.Errorf("IntValue: expected 25, got %v (%v).", n, ok)
+	}
+	if n, ok := r.IntValue("ratio"); n != 0 || ok {
+		t.Errorf("IntValue: expected no int for a fractional flag, got %v (%v).", n, ok)
+	}
+	if f, ok := r.Float64Value("ratio"); f != 0.25 || !ok {
+		t.Errorf("Float64Value: expected 0.25, got %v (%v).", f, ok)
+	}
+	if f, ok := r.Float64Value("limit"); f != 25 || !ok {
+		t.Errorf("Float64Value: expected 25, got %v (%v).", f, ok)
+	}
+	if s, ok := r.StringValue("title"); s != "Hello" || !ok {
+		t.Errorf("StringValue: expected Hello, got %q (%v).", s, ok)
+	}
+	if s, ok := r.StringValue("limit"); s != "" || ok {
+		t.Errorf("StringValue: expected no string for a number flag, got %q (%v).", s, ok)
+	}
+	if s, ok := r.StringValue("missing"); s != "" || ok {
+		t.Errorf("StringValue: expected no string for an unregistered flag, got %q (%v).", s, ok)
+	}
+}
+
+func TestValueType(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "flag_defs": [
+	    {"flag": "limit", "base_value": 25, "value_type": "int"},
+	    {"flag": "title", "base_value": "Hello", "value_type": "string"},
+	    {"flag": "timeouts", "base_value": {"connect_ms": 100, "read_ms": 500, "hosts": ["a"]}, "value_type": "json"}
+	  ],
+	  "variants": [{
+	    "id": "Slow",
+	    "conditions": [{"type": "RANDOM", "value": 1.0}],
+	    "mods": [{"flag": "limit", "value": 50}, {"flag": "timeouts", "value": {"connect_ms": 200, "read_ms": 1000}}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("limit"); v != 50 {
+		t.Errorf("FlagValue: expected the int 50, got %#v.", v)
+	}
+
+	var timeouts struct {
+		ConnectMS int      `json:"connect_ms"`
+		ReadMS    int      `json:"read_ms"`
+		Hosts     []string `json:"hosts"`
+	}
+	if err := r.JSONValue("timeouts", &timeouts); err != nil {
+		t.Fatalf("JSONValue: expected no error, but got %q.", err.Error())
+	}
+	if timeouts.ConnectMS != 200 || timeouts.ReadMS != 1000 || timeouts.Hosts != nil {
+		t.Errorf("JSONValue: expected the variant's timeouts, got %+v.", timeouts)
+	}
+	var title int
+	if err := r.JSONValue("title", &title); err == nil {
+		t.Error("JSONValue: expected an error decoding a string into an int, but got nil.")
+	}
+	expected := `Flag with the name "unknown" has not been registered.`
+	if err := r.JSONValue("unknown", &title); err == nil || err.Error() != expected {
+		t.Errorf("JSONValue: expected error %q, got %v.", expected, err)
+	}
+
+	testCases := map[string]string{
+		`{"flag_defs": [{"flag": "f", "base_value": 1, "value_type": "float"}]}`:                                                                                           `Flag "f" has unknown value type "float".`,
+		`{"flag_defs": [{"flag": "f", "base_value": 1.5, "value_type": "int"}]}`:                                                                                           `Base value of flag "f" is 1.5, not of type "int".`,
+		`{"flag_defs": [{"flag": "f", "base_value": "x", "value_type": "bool"}]}`:                                                                                          `Base value of flag "f" is x, not of type "bool".`,
+		`{"flag_defs": [{"flag": "f", "base_value": "x", "value_type": "string"}], "variants": [{"id": "V", "unconditional": true, "mods": [{"flag": "f", "value": 2}]}]}`: `Mod of flag "f" in variant "V" has value 2, not of type "string".`,
+	}
+	for config, expected := range testCases {
+		if err := NewRegistry().LoadJSON([]byte(config)); err == nil || err.Error() != expected {
+			t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+		}
+	}
+}
+
+func TestStrictTypes(t *testing.T) {
+	config := `{
+	  "flag_defs": [
+	    {"flag": "enabled", "base_value": false},
+	    {"flag": "limit", "base_value": 10},
+	    {"flag": "tags", "base_value": ["a"]},
+	    {"flag": "anything", "base_value": null}
+	  ],
+	  "variants": [{
+	    "id": "Mixed",
+	    "unconditional": true,
+	    "mods": [
+	      {"flag": "limit", "value": 2.5},
+	      {"flag": "tags", "value": []},
+	      {"flag": "anything", "value": "x"},
+	      {"flag": "enabled", "value": %s}
+	    ]
+	  }]
+	}`
+	opts := LoadOptions{StrictTypes: true}
+	if err := NewRegistry().LoadJSONWithOptions([]byte(fmt.Sprintf(config, "true")), opts); err != nil {
+		t.Errorf("LoadJSONWithOptions: expected no error, but got %q.", err.Error())
+	}
+	if err := NewRegistry().LoadJSONWithOptions([]byte(fmt.Sprintf(config, "null")), opts); err != nil {
+		t.Errorf("LoadJSONWithOptions: expected no error for a null value, but got %q.", err.Error())
+	}
+
+	expected := `Mod of flag "enabled" in variant "Mixed" has a value of kind string, but the flag's base value is of kind bool.`
+	err := NewRegistry().LoadJSONWithOptions([]byte(fmt.Sprintf(config, `"yes"`)), opts)
+	if err == nil || err.Error() != expected {
+		t.Errorf("LoadJSONWithOptions: expected error %q, got %v.", expected, err)
+	}
+	if err := NewRegistry().LoadJSON([]byte(fmt.Sprintf(config, `"yes"`))); err != nil {
+		t.Errorf("LoadJSON: expected mixed types to be allowed by default, but got %q.", err.Error())
+	}
+
+	// A registry with strict types checks added variants and reloads.
+	r := NewRegistry()
+	r.SetStrictTypes(true)
+	if err := r.LoadJSON([]byte(fmt.Sprintf(config, `"yes"`))); err == nil || err.Error() != expected {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expected, err)
+	}
+	if err := r.LoadJSON([]byte(fmt.Sprintf(config, "true"))); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	mixed := Variant{ID: "Yes", Unconditional: true, Mods: []Mod{{FlagName: "enabled", Value: "yes"}}}
+	expected = `Mod of flag "enabled" in variant "Yes" has a value of kind string, but the flag's base value is of kind bool.`
+	if err := r.AddVariant(mixed); err == nil || err.Error() != expected {
+		t.Errorf("AddVariant: expected error %q, got %v.", expected, err)
+	}
+	if err := r.AddVariants([]Variant{mixed}); err == nil || err.Error() != expected {
+		t.Errorf("AddVariants: expected error %q, got %v.", expected, err)
+	}
+	reload := `{"variants": [{"id": "Yes", "unconditional": true, "mods": [{"flag": "enabled", "value": "yes"}]}]}`
+	if err := r.ReloadVariants([]byte(reload)); err == nil || err.Error() != expected {
+		t.Errorf("ReloadVariants: expected error %q, got %v.", expected, err)
+	}
+	expected = `Mod of flag "enabled" in variant "Mixed" has a value of kind bool, but the flag's base value is of kind string.`
+	if err := r.ReloadFlags([]byte(`{"flag_defs": [{"flag": "enabled", "base_value": "off"}]}`)); err == nil || err.Error() != expected {
+		t.Errorf("ReloadFlags: expected error %q, got %v.", expected, err)
+	}
+	if v := r.FlagValue("enabled"); v != true {
+		t.Errorf("FlagValue: expected failed reloads to leave the registry alone, got %v.", v)
+	}
+	expected = `Mod of flag "enabled" in variant "Yes" has a value of kind string, but the flag's base value is of kind bool.`
+	if errs := r.ValidateJSON([]byte(reload)); len(errs) != 1 || errs[0].Error() != expected {
+		t.Errorf("ValidateJSON: expected error %q, got %v.", expected, errs)
+	}
+}
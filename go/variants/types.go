@@ -1,11 +1,46 @@
 package variants
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // A Flag defines a value that may change on a contextual basis
 // based on the Variants that refer to it.
 type Flag struct {
 	Name        string      `json:"flag"`
 	Description string      `json:"desc,omit_empty"`
 	BaseValue   interface{} `json:"base_value"`
+
+	// ClientSafe marks a flag whose value may be exposed to untrusted
+	// clients. Flags are server-only by default.
+	ClientSafe bool `json:"client_safe"`
+
+	// DependsOn names prerequisite flags. Unless every prerequisite
+	// resolves to a truthy value (not nil, false, zero, or the empty
+	// string) with the same context, the flag resolves to its base value.
+	// Every prerequisite must be registered.
+	DependsOn []string `json:"depends_on"`
+
+	// Combine is the strategy combining the values of the flag's active
+	// variants: CombineOverride (the default), CombineAppend, or
+	// CombineUnion. When appending, the values of every active variant
+	// are concatenated in priority order into a []interface{}; a value
+	// that is not a slice is added as a single element. The base value
+	// is used only when no variant is active.
+	Combine string `json:"combine"`
+
+	// ValueType, if set, is the type of the flag's values: ValueTypeBool,
+	// ValueTypeInt, ValueTypeString, or ValueTypeJSON. The base value and
+	// mod values are checked against it when added, and integers loaded
+	// from JSON are stored as ints.
+	ValueType string `json:"value_type"`
+
+	// The raw encoding of BaseValue when loaded from JSON, and the value
+	// it was decoded into, so that raw is only encoded while BaseValue is
+	// unchanged.
+	raw      json.RawMessage
+	rawValue interface{}
 }
 
 // A Mod defines how a flag changes. Variants contain Mods that
@@ -13,24 +48,111 @@ type Flag struct {
 type Mod struct {
 	FlagName string `json:"flag"`
 	Value    interface{}
+
+	// ValueFunc, when set, computes the value from the context in place
+	// of the static Value, for values such as a per-user discount. It
+	// may be called concurrently and must be safe for concurrent use.
+	ValueFunc func(context interface{}) interface{} `json:"-"`
+
+	// Type and Values name a registered mod type and its values, from
+	// which ValueFunc is constructed when the mod is loaded.
+	Type   string        `json:"type,omitempty"`
+	Values []interface{} `json:"values,omitempty"`
+
+	// ArmValues, for a variant with arms, holds the value of each arm,
+	// in place of the static Value.
+	ArmValues []interface{} `json:"arm_values,omitempty"`
+
+	// The raw encoding of Value when loaded from JSON, and the value it
+	// was decoded into, so that raw is only encoded while Value is
+	// unchanged.
+	raw      json.RawMessage
+	rawValue interface{}
 }
 
 // A Condition wraps a user-defined method used to evaluate
 // whether the owning Variant is “active.”
 type Condition struct {
-	Type      string
-	Value     interface{}
-	Values    []interface{}
-	Evaluator func(context interface{}) bool
+	Type   string
+	Value  interface{}
+	Values []interface{}
+
+	// Evaluator reports whether the condition is met by a context. It
+	// may be called concurrently and must be safe for concurrent use.
+	// The context may be nil, as it is for FlagValue, and Evaluator must
+	// not panic on one: conditions reading the context are not met
+	// without one, as is the case for the built-in condition types.
+	Evaluator func(context interface{}) bool `json:"-"`
+
+	// EvaluatorErr, when set, is used in place of Evaluator. It may
+	// report that the condition could not be evaluated, for example
+	// because the context is of the wrong type, as opposed to not met.
+	EvaluatorErr func(context interface{}) (bool, error) `json:"-"`
+
+	// Negate inverts the result of the condition's evaluator, so that,
+	// for example, a negated COHORT condition is met by everyone outside
+	// the cohort. A condition that cannot be evaluated is not met either
+	// way, nor is a negated condition reading the context without one,
+	// or a negated bucketing condition, such as PERCENTAGE, whose context
+	// lacks its key.
+	Negate bool `json:"negate,omitempty"`
+
+	// Whether the condition's type declares a schema reading no context.
+	// Set when the condition is loaded.
+	ignoresContext bool
+
+	// The flag compared by a FLAG_EQUALS condition and the value it is
+	// compared with. Set when the condition is loaded, so that the flag
+	// is resolved within the evaluation reading the condition.
+	comparedFlag  string
+	comparedValue interface{}
+
+	// Returns why the condition is met, for condition types that
+	// distinguish activation reasons. Set when the condition is loaded,
+	// so that its values are parsed once rather than per evaluation.
+	reasoner func(context interface{}, now time.Time) string
 }
 
 // Evaluate returns whether the condition has been met with
-// the given context.
+// the given context. A condition that cannot be evaluated is not met.
 func (c *Condition) Evaluate(context interface{}) bool {
-	if c.Evaluator == nil {
-		return false
+	met, err := c.EvaluateErr(context)
+	return err == nil && met
+}
+
+// EvaluateErr is like Evaluate, but also returns the error of an
+// EvaluatorErr that could not evaluate the condition, or ErrNoEvaluator
+// if the condition has no evaluator at all.
+func (c *Condition) EvaluateErr(context interface{}) (bool, error) {
+	var met bool
+	switch {
+	case c.EvaluatorErr != nil:
+		var err error
+		if met, err = c.EvaluatorErr(context); err != nil {
+			return false, err
+		}
+	case c.Evaluator != nil:
+		met = c.Evaluator(context)
+	default:
+		return false, ErrNoEvaluator
+	}
+	if c.Negate && context == nil && !c.ignoresContext {
+		return false, nil
 	}
-	return c.Evaluator(context)
+	return met != c.Negate, nil
+}
+
+// IgnoresContext reports whether the condition is met or not regardless
+// of the context, as are RANDOM conditions, because its type was
+// registered with a schema declaring that it reads no context. It is
+// false for conditions that were not loaded by a registry.
+func (c *Condition) IgnoresContext() bool {
+	return c.ignoresContext
+}
+
+// hasEvaluator reports whether the condition has either kind of evaluator.
+func (c *Condition) hasEvaluator() bool {
+	return c.Evaluator != nil || c.EvaluatorErr != nil
 }
 
 // A Variant contains a list of conditions and a set of mods.
@@ -42,39 +164,215 @@ type Variant struct {
 	Mods                []Mod
 	ConditionalOperator string `json:"condition_operator"`
 	Conditions          []Condition
+
+	// ConditionGroups nest conditions for expressions such as
+	// (A AND B) OR C. Each group is evaluated with its own operator,
+	// and the group results are combined with GroupOperator.
+	ConditionGroups []ConditionGroup `json:"condition_groups"`
+	GroupOperator   string           `json:"group_operator"`
+
+	// Priority orders variants modifying the same flag. The active
+	// variant with the highest priority supplies the flag's value.
+	Priority int `json:"priority"`
+
+	// ExclusionGroup names a set of mutually exclusive variants. At most
+	// one variant of a group, the highest-priority one whose conditions
+	// are met, is active for a given context.
+	ExclusionGroup string `json:"exclusion_group"`
+
+	// Enabled may be set to false to deactivate the variant, regardless
+	// of its conditions, without removing it. A nil Enabled means true.
+	Enabled *bool `json:"enabled"`
+
+	// MaxExposures caps the number of subjects, identified by the
+	// context value under ExposureKey, for which the variant activates,
+	// as for a beta limited to its first 1000 users. Subjects enrolled
+	// before the cap was reached stay enrolled. Zero means no cap.
+	MaxExposures int    `json:"max_exposures"`
+	ExposureKey  string `json:"exposure_key"`
+
+	// Salt is mixed into the hash of the variant's bucketing conditions,
+	// such as PERCENTAGE, so that variants rolled out to the same
+	// percentage bucket users independently. It defaults to the variant's
+	// ID. Changing it re-buckets every user.
+	Salt string `json:"salt"`
+
+	// Tags group variants for bulk operations such as
+	// SetVariantsEnabledByTag.
+	Tags []string `json:"tags"`
+
+	// Unconditional marks a variant without conditions, which is active
+	// for every context. Variants must have conditions unless they are
+	// unconditional, so that an always-on variant is never an accident.
+	Unconditional bool `json:"unconditional"`
+
+	// Arms split the contexts for which the variant is active between
+	// weighted arms, selected by the consistent hash of the context
+	// value under ArmKey. The weights sum to 100, and mods with
+	// ArmValues supply a value for each arm. The variant is not active
+	// for a context without the key.
+	Arms   []Arm  `json:"arms,omitempty"`
+	ArmKey string `json:"arm_key,omitempty"`
+
+	// Extends names a base variant whose conditions, conditional operator,
+	// and mods are inherited when the variant is loaded. The variant's own
+	// conditions and operator replace the base's when present, and its
+	// mods replace the base's mods of the same flag.
+	Extends string `json:"extends"`
 }
 
-// FlagValue returns the value of a modified flag for the receiver.
+// IsEnabled reports whether the receiver is enabled.
+func (v *Variant) IsEnabled() bool {
+	return v.Enabled == nil || *v.Enabled
+}
+
+// salt returns the salt of the receiver's bucketing conditions.
+func (v *Variant) salt() string {
+	if len(v.Salt) > 0 {
+		return v.Salt
+	}
+	return v.ID
+}
+
+// FlagValue returns the value of a modified flag for the receiver, with
+// a nil context for a mod whose value is computed.
 func (v *Variant) FlagValue(name string) interface{} {
+	return v.FlagValueWithContext(name, nil)
+}
+
+// FlagValueWithContext returns the value of a modified flag for the
+// receiver, computed from context by the mod's ValueFunc if it has one,
+// or taken from the mod's ArmValues for the arm context selects. It
+// returns nil if the receiver does not modify the flag, or context
+// selects no arm.
+func (v *Variant) FlagValueWithContext(name string, context interface{}) interface{} {
+	val, _ := v.modValue(name, context)
+	return val
+}
+
+// modValue is like FlagValueWithContext, but also reports whether the
+// receiver supplies a value, so that resolution falls back to other
+// variants or the base value when it does not.
+func (v *Variant) modValue(name string, context interface{}) (interface{}, bool) {
 	for _, m := range v.Mods {
 		if m.FlagName == name {
-			return m.Value
+			if m.ValueFunc != nil {
+				return m.ValueFunc(context), true
+			}
+			if len(m.ArmValues) > 0 {
+				if i, ok := v.arm(context); ok {
+					return m.ArmValues[i], true
+				}
+				return nil, false
+			}
+			return m.Value, true
 		}
 	}
-	return nil
+	return nil, false
 }
 
+// Conditional operators combining the conditions of a Variant.
 const (
-	conditionalOperatorAnd = "AND"
-	conditionalOperatorOr  = "OR"
+	// ConditionalOperatorAnd requires every condition to be met.
+	ConditionalOperatorAnd = "AND"
+	// ConditionalOperatorOr requires at least one condition to be met.
+	ConditionalOperatorOr = "OR"
+	// ConditionalOperatorNot requires no condition to be met.
+	ConditionalOperatorNot = "NOT"
 )
 
 // Evaluate returns the result of evaluating each condition of the
-// receiver given a context.
+// receiver given a context. When the receiver has condition groups, the
+// result of each group, and of its flat Conditions as one more group,
+// are combined with its GroupOperator. A group's operator thus binds its
+// conditions more tightly than the GroupOperator binds the groups, so
+// that groups combined with AND under an OR GroupOperator evaluate as
+// (A AND B) OR (C AND D). Conditions and groups are evaluated in order
+// only until the result is known.
 func (v *Variant) Evaluate(context interface{}) bool {
-	if len(v.Conditions) <= 1 || v.ConditionalOperator == conditionalOperatorAnd {
-		for _, c := range v.Conditions {
-			if !c.Evaluate(context) {
+	return v.evaluateWith(func(c *Condition) bool {
+		return c.Evaluate(context)
+	})
+}
+
+// EvaluateErr is like Evaluate, but also returns the first error met
+// while evaluating the receiver's conditions: a *ConditionError for a
+// condition that could not be evaluated, or an *UnknownConditionTypeError
+// for one without an evaluator. Such conditions count as not met, so the
+// result is still that of Evaluate.
+func (v *Variant) EvaluateErr(context interface{}) (bool, error) {
+	var err error
+	met := v.evaluateWith(func(c *Condition) bool {
+		ok, cerr := c.EvaluateErr(context)
+		if cerr != nil && err == nil {
+			err = conditionError(v.ID, c, cerr)
+		}
+		return cerr == nil && ok
+	})
+	return met, err
+}
+
+// evaluateWith is like Evaluate, using eval to evaluate each condition.
+func (v *Variant) evaluateWith(eval func(c *Condition) bool) bool {
+	if len(v.ConditionGroups) == 0 {
+		return evaluateConditions(v.ConditionalOperator, v.Conditions, eval)
+	}
+	groups := v.ConditionGroups
+	if len(v.Conditions) > 0 {
+		flat := ConditionGroup{ConditionalOperator: v.ConditionalOperator, Conditions: v.Conditions}
+		groups = append([]ConditionGroup{flat}, groups...)
+	}
+	return combine(v.GroupOperator, len(groups), func(i int) bool {
+		return groups[i].evaluateWith(eval)
+	})
+}
+
+// evaluateConditions combines the results of eval for conditions with op.
+func evaluateConditions(op string, conditions []Condition, eval func(c *Condition) bool) bool {
+	return combine(op, len(conditions), func(i int) bool {
+		return eval(&conditions[i])
+	})
+}
+
+// combine combines the results of eval for [0, n) with the conditional
+// operator op, calling eval only until the result is known. With at
+// most one operand, a missing operator behaves as AND.
+func combine(op string, n int, eval func(i int) bool) bool {
+	if op == ConditionalOperatorNot {
+		for i := 0; i < n; i++ {
+			if eval(i) {
+				return false
+			}
+		}
+		return true
+	}
+	if n <= 1 || op == ConditionalOperatorAnd {
+		for i := 0; i < n; i++ {
+			if !eval(i) {
 				return false
 			}
 		}
 		return true
-	} else if v.ConditionalOperator == conditionalOperatorOr {
-		for _, c := range v.Conditions {
-			if c.Evaluate(context) {
+	} else if op == ConditionalOperatorOr {
+		for i := 0; i < n; i++ {
+			if eval(i) {
 				return true
 			}
 		}
 	}
 	return false
 }
+
+// args returns the condition's values, or its single value when no
+// list of values was given. A WHITELIST condition given a single ID
+// reads it from the default whitelist key.
+func (c *Condition) args() []interface{} {
+	args := c.Values
+	if len(args) == 0 {
+		args = []interface{}{c.Value}
+	}
+	if c.Type == conditionTypeWhitelist && len(args) == 1 {
+		return []interface{}{defaultWhitelistKey, args[0]}
+	}
+	return args
+}
@@ -0,0 +1,52 @@
+package variants
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// ValidateJSON checks data, a JSON-encoded config, against the
+// DefaultRegistry without loading it.
+func ValidateJSON(data []byte) []error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ValidateJSON(data)
+}
+
+// ValidateConfig checks the JSON-encoded config in filename against the
+// DefaultRegistry without loading it.
+func ValidateConfig(filename string) []error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.ValidateConfig(filename)
+}
+
+// ValidateJSON runs the checks that LoadJSON would on data, such as for
+// variants without mods or conditional operators, mods of unregistered
+// flags, and conditions of unknown types or with invalid values, without
+// modifying the receiver. Rather than stopping at the first problem, it
+// returns every problem found, or nil if data would load. Flags and
+// variants already registered with the receiver are taken into account,
+// so a config that LoadJSON would reject as a duplicate is reported, as
+// are mods of the wrong kind if the receiver has strict types.
+func (r *Registry) ValidateJSON(data []byte) []error {
+	config := configFile{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return []error{err}
+	}
+	// Conditions are constructed by a scratch registry so that any state
+	// they create, such as ID sets, is not kept by the receiver.
+	other := r.scratch()
+	other.idSets = newIDSetCache()
+	other.snap.Store(r.load())
+	return other.stageConfig(r.load().clone(), &config, LoadOptions{StrictTypes: other.strictTypes}, true)
+}
+
+// ValidateConfig is like ValidateJSON for the config in filename.
+func (r *Registry) ValidateConfig(filename string) []error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return []error{err}
+	}
+	return r.ValidateJSON(data)
+}
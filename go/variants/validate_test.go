@@ -0,0 +1,57 @@
+package variants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateJSON(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadJSON([]byte(`{"flag_defs": [{"flag": "registered", "base_value": 0}]}`)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	config := `{
+	  "flag_defs": [
+	    {"flag": "beta", "base_value": false},
+	    {"flag": "registered", "base_value": 1},
+	    {"flag": "typed", "base_value": "yes", "value_type": "bool"}
+	  ],
+	  "variants": [
+	    {"id": "NoMods", "conditions": [{"type": "RANDOM", "value": 1.0}]},
+	    {"id": "NoOperator", "conditions": [{"type": "RANDOM", "value": 1.0}, {"type": "RANDOM", "value": 1.0}], "mods": [{"flag": "beta", "value": true}]},
+	    {"id": "UnknownType", "conditions": [{"type": "BOGUS"}], "mods": [{"flag": "beta", "value": true}]},
+	    {"id": "UnknownFlag", "unconditional": true, "mods": [{"flag": "missing", "value": true}]},
+	    {"id": "BadArgs", "conditions": [{"type": "RANDOM", "value": 2.0}], "mods": [{"flag": "beta", "value": true}]},
+	    {"id": "Valid", "conditions": [{"type": "RANDOM", "value": 0.5}], "mods": [{"flag": "beta", "value": true}, {"flag": "registered", "value": 2}]}
+	  ]
+	}`
+	var messages []string
+	for _, err := range r.ValidateJSON([]byte(config)) {
+		messages = append(messages, err.Error())
+	}
+	expected := []string{
+		`Variant flag with the name "registered" is already registered.`,
+		`Base value of flag "typed" is yes, not of type "bool".`,
+		`Variant with ID "NoMods" must have at least one mod.`,
+		`Variant with ID "NoOperator" has 2 conditions but no conditional operator specified.`,
+		`unknown condition type "BOGUS" in variant "UnknownType"`,
+		`Flag with the name "missing" has not been registered.`,
+		`Condition 0 (RANDOM) of variant "BadArgs" has invalid values [2]: RANDOM value must be a number in [0, 1], got 2.`,
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("ValidateJSON: expected errors\n%q\ngot\n%q", expected, messages)
+	}
+	if flags := r.Flags(); len(flags) != 1 || len(r.Variants()) != 0 {
+		t.Errorf("ValidateJSON: expected the registry to be unchanged, got flags %v and variants %v.", flags, r.Variants())
+	}
+
+	if errs := r.ValidateJSON([]byte(`{"flag_defs": [{"flag": "beta"}]}`)); errs != nil {
+		t.Errorf("ValidateJSON: expected a valid config to have no errors, got %v.", errs)
+	}
+	if errs := r.ValidateJSON([]byte(`{"flag_defs": `)); len(errs) != 1 {
+		t.Errorf("ValidateJSON: expected one error for malformed JSON, got %v.", errs)
+	}
+	if errs := r.ValidateConfig("testdata/testdata.json"); errs != nil {
+		t.Errorf("ValidateConfig: expected no errors, got %v.", errs)
+	}
+}
@@ -0,0 +1,180 @@
+package variants
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// variableRef matches a ${name} or ${name:-default} reference to a
+// variable.
+var variableRef = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// A variableScope resolves variable references to config variables, or
+// else, if env is set, to environment variables.
+type variableScope struct {
+	vars map[string]interface{}
+	env  bool
+}
+
+// lookup returns the value of the variable reference ref, the text
+// between ${ and }, and its text when the reference is within a longer
+// string. A config variable takes precedence over an environment variable
+// of the same name, and either over the default given after ":-". Values
+// of environment variables and defaults are decoded as JSON when they are
+// valid JSON, so that "0.5" is a number, and are strings otherwise.
+func (vars variableScope) lookup(ref string) (interface{}, string, error) {
+	name, def, hasDefault := ref, "", false
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		name, def, hasDefault = ref[:i], ref[i+2:], true
+	}
+	if v, found := vars.vars[name]; found {
+		return v, fmt.Sprint(v), nil
+	}
+	text, found := "", false
+	if vars.env {
+		text, found = os.LookupEnv(name)
+	}
+	if !found {
+		if !hasDefault {
+			return nil, "", fmt.Errorf("Undefined variable %q", name)
+		}
+		text = def
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		v = text
+	}
+	return v, text, nil
+}
+
+// substituteVariables replaces the variable references in the condition
+// values and mod values of config's variants. Only condition values read
+// the environment, so that a mod value, which may be served to clients,
+// cannot expose an environment variable such as a secret.
+func substituteVariables(config *configFile) error {
+	vars := variableScope{vars: config.Variables, env: true}
+	modVars := variableScope{vars: config.Variables}
+	for i := range config.Variants {
+		v := &config.Variants[i]
+		if err := substituteConditions(vars, v.Conditions, fmt.Sprintf("variant %q", v.ID)); err != nil {
+			return err
+		}
+		if err := substituteGroups(vars, v.ConditionGroups, fmt.Sprintf("variant %q", v.ID)); err != nil {
+			return err
+		}
+		for j := range v.Mods {
+			m := &v.Mods[j]
+			val, changed, err := substitute(modVars, m.Value)
+			if err != nil {
+				return fmt.Errorf("%v in mod of flag %q in variant %q.", err, m.FlagName, v.ID)
+			}
+			if changed {
+				m.Value, m.rawValue = val, val
+				if m.raw, err = json.Marshal(val); err != nil {
+					return err
+				}
+			}
+			for k, armVal := range m.ArmValues {
+				if m.ArmValues[k], _, err = substitute(modVars, armVal); err != nil {
+					return fmt.Errorf("%v in mod of flag %q in variant %q.", err, m.FlagName, v.ID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func substituteConditions(vars variableScope, conditions []Condition, location string) error {
+	for i := range conditions {
+		c := &conditions[i]
+		val, _, err := substitute(vars, c.Value)
+		if err != nil {
+			return fmt.Errorf("%v in condition %d (%s) of %s.", err, i, c.Type, location)
+		}
+		c.Value = val
+		if len(c.Values) == 0 {
+			continue
+		}
+		values, _, err := substitute(vars, c.Values)
+		if err != nil {
+			return fmt.Errorf("%v in condition %d (%s) of %s.", err, i, c.Type, location)
+		}
+		c.Values = values.([]interface{})
+	}
+	return nil
+}
+
+func substituteGroups(vars variableScope, groups []ConditionGroup, location string) error {
+	for i := range groups {
+		g := &groups[i]
+		groupLocation := fmt.Sprintf("condition group %d of %s", i, location)
+		if err := substituteConditions(vars, g.Conditions, groupLocation); err != nil {
+			return err
+		}
+		if err := substituteGroups(vars, g.Groups, groupLocation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// substitute returns v with its variable references replaced, and whether
+// any were. A string consisting of a single reference is replaced by the
+// variable's value, whatever its type; references within longer strings
+// are replaced by the variable's text. Slices and maps
+// are copied rather than modified.
+func substitute(vars variableScope, v interface{}) (interface{}, bool, error) {
+	switch v := v.(type) {
+	case string:
+		refs := variableRef.FindAllStringSubmatchIndex(v, -1)
+		if len(refs) == 0 {
+			return v, false, nil
+		}
+		if len(refs) == 1 && refs[0][0] == 0 && refs[0][1] == len(v) {
+			val, _, err := vars.lookup(v[refs[0][2]:refs[0][3]])
+			if err != nil {
+				return nil, false, err
+			}
+			return val, true, nil
+		}
+		var b strings.Builder
+		last := 0
+		for _, ref := range refs {
+			_, text, err := vars.lookup(v[ref[2]:ref[3]])
+			if err != nil {
+				return nil, false, err
+			}
+			b.WriteString(v[last:ref[0]])
+			b.WriteString(text)
+			last = ref[1]
+		}
+		b.WriteString(v[last:])
+		return b.String(), true, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		changed := false
+		for i, e := range v {
+			val, c, err := substitute(vars, e)
+			if err != nil {
+				return nil, false, err
+			}
+			result[i], changed = val, changed || c
+		}
+		return result, changed, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		changed := false
+		for k, e := range v {
+			val, c, err := substitute(vars, e)
+			if err != nil {
+				return nil, false, err
+			}
+			result[k], changed = val, changed || c
+		}
+		return result, changed, nil
+	}
+	return v, false, nil
+}
@@ -0,0 +1,149 @@
+package variants
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	r := NewRegistry()
+	config := `{
+	  "variables": {"ramp": 100, "launch": "2026-03-02T00:00:00Z", "name": "Mercury"},
+	  "flag_defs": [{"flag": "rollout", "base_value": false}, {"flag": "banner", "base_value": ""}],
+	  "variants": [{
+	    "id": "Rollout",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", "${ramp}"]}],
+	    "mods": [{"flag": "rollout", "value": true}]
+	  }, {
+	    "id": "Banner",
+	    "condition_groups": [{
+	      "conditions": [{"type": "LAUNCH", "values": ["user_id", "${launch}"]}]
+	    }],
+	    "mods": [{"flag": "banner", "value": {"text": "Welcome to ${name}!", "ramp": "${ramp}"}}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValueWithContext("rollout", map[string]int{"user_id": 1}); v != true {
+		t.Errorf("FlagValueWithContext: expected a 100%% rollout, got %v.", v)
+	}
+	expected := map[string]interface{}{"text": "Welcome to Mercury!", "ramp": 100.0}
+	if v := r.FlagValue("banner"); !reflect.DeepEqual(v, expected) {
+		t.Errorf("FlagValue: expected %v, got %v.", expected, v)
+	}
+
+	config = `{
+	  "variables": {"ramp": 100},
+	  "flag_defs": [{"flag": "rollout", "base_value": false}],
+	  "variants": [{
+	    "id": "Rollout",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", "${rmap}"]}],
+	    "mods": [{"flag": "rollout", "value": true}]
+	  }]
+	}`
+	expectedErr := `Undefined variable "rmap" in condition 0 (PERCENTAGE) of variant "Rollout".`
+	if err := NewRegistry().LoadJSON([]byte(config)); err == nil || err.Error() != expectedErr {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expectedErr, err)
+	}
+}
+
+func TestEnvironmentVariables(t *testing.T) {
+	os.Setenv("VARIANTS_TEST_RAMP", "100")
+	os.Setenv("VARIANTS_TEST_REGION", "us-west")
+	defer os.Unsetenv("VARIANTS_TEST_RAMP")
+	defer os.Unsetenv("VARIANTS_TEST_REGION")
+	os.Unsetenv("VARIANTS_TEST_UNSET")
+
+	r := NewRegistry()
+	config := `{
+	  "variables": {"VARIANTS_TEST_REGION": "eu"},
+	  "flag_defs": [{"flag": "rollout", "base_value": false}, {"flag": "regional", "base_value": false}],
+	  "variants": [{
+	    "id": "Rollout",
+	    "conditions": [
+	      {"type": "PERCENTAGE", "values": ["user_id", "${VARIANTS_TEST_RAMP}"]},
+	      {"type": "NUMERIC", "values": ["age", "GTE", "${VARIANTS_TEST_UNSET:-18}"]}
+	    ],
+	    "condition_operator": "AND",
+	    "mods": [{"flag": "rollout", "value": true}]
+	  }, {
+	    "id": "Regional",
+	    "conditions": [{"type": "GEO", "values": ["region", "${VARIANTS_TEST_REGION}"]}],
+	    "mods": [{"flag": "regional", "value": true}]
+	  }]
+	}`
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValueWithContext("rollout", map[string]interface{}{"user_id": 1, "age": 18}); v != true {
+		t.Errorf("FlagValueWithContext: expected a 100%% rollout from the environment, got %v.", v)
+	}
+	if v := r.FlagValueWithContext("rollout", map[string]interface{}{"user_id": 1, "age": 17}); v != false {
+		t.Errorf("FlagValueWithContext: expected the default threshold of 18, got %v.", v)
+	}
+	if v := r.FlagValueWithContext("regional", map[string]string{"region": "eu-west"}); v != true {
+		t.Errorf("FlagValueWithContext: expected the config variable to take precedence over the environment, got %v.", v)
+	}
+
+	testCases := map[string]interface{}{
+		"${VARIANTS_TEST_RAMP}":                100.0,
+		"${VARIANTS_TEST_REGION}":              "us-west",
+		"v${VARIANTS_TEST_RAMP}.0":             "v100.0",
+		"${VARIANTS_TEST_UNSET:-0.5}":          0.5,
+		"${VARIANTS_TEST_UNSET:-off}":          "off",
+		"${VARIANTS_TEST_UNSET:-}":             "",
+		"${VARIANTS_TEST_RAMP:-0}% of traffic": "100% of traffic",
+	}
+	for ref, expected := range testCases {
+		if v, _, err := substitute(variableScope{env: true}, ref); err != nil || v != expected {
+			t.Errorf("substitute: expected %q to expand to %#v, got %#v (%v).", ref, expected, v, err)
+		}
+	}
+
+	config = `{
+	  "flag_defs": [{"flag": "rollout", "base_value": false}],
+	  "variants": [{
+	    "id": "Rollout",
+	    "conditions": [{"type": "PERCENTAGE", "values": ["user_id", "${VARIANTS_TEST_UNSET}"]}],
+	    "mods": [{"flag": "rollout", "value": true}]
+	  }]
+	}`
+	expectedErr := `Undefined variable "VARIANTS_TEST_UNSET" in condition 0 (PERCENTAGE) of variant "Rollout".`
+	if err := NewRegistry().LoadJSON([]byte(config)); err == nil || err.Error() != expectedErr {
+		t.Errorf("LoadJSON: expected error %q, got %v.", expectedErr, err)
+	}
+
+	// Mod values and arm values do not read the environment.
+	for _, mod := range []string{
+		`"value": "${VARIANTS_TEST_REGION}"`,
+		`"arm_values": ["${VARIANTS_TEST_REGION}"]`,
+	} {
+		config = `{
+		  "flag_defs": [{"flag": "region", "base_value": ""}],
+		  "variants": [{
+		    "id": "Region",
+		    "unconditional": true,
+		    "arm_key": "user_id",
+		    "arms": [{"weight": 100}],
+		    "mods": [{"flag": "region", ` + mod + `}]
+		  }]
+		}`
+		expectedErr = `Undefined variable "VARIANTS_TEST_REGION" in mod of flag "region" in variant "Region".`
+		if err := NewRegistry().LoadJSON([]byte(config)); err == nil || err.Error() != expectedErr {
+			t.Errorf("LoadJSON: expected error %q for mod %s, got %v.", expectedErr, mod, err)
+		}
+	}
+	config = `{
+	  "flag_defs": [{"flag": "region", "base_value": ""}],
+	  "variants": [{"id": "Region", "unconditional": true, "mods": [{"flag": "region", "value": "${VARIANTS_TEST_REGION:-none}"}]}]
+	}`
+	r = NewRegistry()
+	if err := r.LoadJSON([]byte(config)); err != nil {
+		t.Fatalf("LoadJSON: expected no error, but got %q.", err.Error())
+	}
+	if v := r.FlagValue("region"); v != "none" {
+		t.Errorf("FlagValue: expected a mod value to use its default over the environment, got %v.", v)
+	}
+}
@@ -0,0 +1,173 @@
+package variants
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A version is a semantic version such as "2.3.0" or "3.0.0-beta.1".
+// Build metadata is ignored.
+type version struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// parseVersion parses a semantic version of the form
+// MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD], optionally prefixed with "v".
+// It returns false if s is not one.
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var v version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range v.prerelease {
+			if len(id) == 0 {
+				return version{}, false
+			}
+		}
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, false
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		if !isDigits(p) || (len(p) > 1 && p[0] == '0') {
+			return version{}, false
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return version{}, false
+		}
+		nums[i] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	return v, true
+}
+
+// compare returns -1, 0, or 1 as v precedes, equals, or follows w in
+// semantic version precedence. A prerelease precedes its release.
+func (v version) compare(w version) int {
+	for _, d := range [][2]int{{v.major, w.major}, {v.minor, w.minor}, {v.patch, w.patch}} {
+		if d[0] != d[1] {
+			return compareInts(d[0], d[1])
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(w.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(w.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(w.prerelease); i++ {
+		a, b := v.prerelease[i], w.prerelease[i]
+		if a == b {
+			continue
+		}
+		aNum, bNum := isDigits(a), isDigits(b)
+		switch {
+		case aNum && bNum:
+			x, _ := strconv.Atoi(a)
+			y, _ := strconv.Atoi(b)
+			return compareInts(x, y)
+		case aNum:
+			return -1
+		case bNum:
+			return 1
+		case a < b:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return compareInts(len(v.prerelease), len(w.prerelease))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Operators of version constraints, mapped to whether they accept each
+// result of comparing a version with the constraint's version.
+var versionOperators = map[string]func(cmp int) bool{
+	">=": func(cmp int) bool { return cmp >= 0 },
+	"<=": func(cmp int) bool { return cmp <= 0 },
+	"!=": func(cmp int) bool { return cmp != 0 },
+	">":  func(cmp int) bool { return cmp > 0 },
+	"<":  func(cmp int) bool { return cmp < 0 },
+	"=":  func(cmp int) bool { return cmp == 0 },
+}
+
+// A versionComparator is a single constraint such as ">=2.3.0".
+type versionComparator struct {
+	accepts func(cmp int) bool
+	version version
+}
+
+// A versionRange is a set of alternatives separated by "||", each of
+// which is met when all of its space-separated comparators are.
+type versionRange [][]versionComparator
+
+// parseVersionRange parses a constraint such as ">=2.3.0 <3.0.0" or
+// "<1.0.0 || >=2.0.0". A version without an operator must be equal. It
+// returns false if s is not a well-formed constraint.
+func parseVersionRange(s string) (versionRange, bool) {
+	var rng versionRange
+	for _, alt := range strings.Split(s, "||") {
+		fields := strings.Fields(alt)
+		if len(fields) == 0 {
+			return nil, false
+		}
+		comparators := make([]versionComparator, len(fields))
+		for i, f := range fields {
+			n := strings.IndexFunc(f, func(r rune) bool { return !strings.ContainsRune("<>=!", r) })
+			if n < 0 {
+				return nil, false
+			}
+			op := f[:n]
+			if len(op) == 0 {
+				op = "="
+			}
+			accepts, ok := versionOperators[op]
+			if !ok {
+				return nil, false
+			}
+			v, ok := parseVersion(f[n:])
+			if !ok {
+				return nil, false
+			}
+			comparators[i] = versionComparator{accepts: accepts, version: v}
+		}
+		rng = append(rng, comparators)
+	}
+	return rng, true
+}
+
+// contains reports whether v satisfies the range.
+func (rng versionRange) contains(v version) bool {
+	for _, comparators := range rng {
+		met := true
+		for _, c := range comparators {
+			if !c.accepts(v.compare(c.version)) {
+				met = false
+				break
+			}
+		}
+		if met {
+			return true
+		}
+	}
+	return false
+}
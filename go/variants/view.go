@@ -0,0 +1,101 @@
+package variants
+
+// A VariantView is a read-only view of a registered variant. Its accessors
+// return copies, so inspecting a variant through a view can never modify
+// the registry.
+type VariantView struct {
+	v Variant
+}
+
+// GetVariantView returns a read-only view of the variant with the given ID
+// registered within the DefaultRegistry, and whether it was found.
+func GetVariantView(id string) (VariantView, bool) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.GetVariantView(id)
+}
+
+// GetVariantView returns a read-only view of the variant with the given ID,
+// and whether it was found. Prefer it to Variants for introspection: the
+// variants returned by Variants share their slices with the registry and
+// must be treated as read-only.
+func (r *Registry) GetVariantView(id string) (VariantView, bool) {
+	v, found := r.load().variants[id]
+	return VariantView{v: v}, found
+}
+
+// ID returns the ID of the variant.
+func (vv VariantView) ID() string { return vv.v.ID }
+
+// Description returns the description of the variant.
+func (vv VariantView) Description() string { return vv.v.Description }
+
+// ConditionalOperator returns the operator combining the variant's
+// conditions.
+func (vv VariantView) ConditionalOperator() string { return vv.v.ConditionalOperator }
+
+// GroupOperator returns the operator combining the variant's condition
+// groups.
+func (vv VariantView) GroupOperator() string { return vv.v.GroupOperator }
+
+// Priority returns the priority of the variant.
+func (vv VariantView) Priority() int { return vv.v.Priority }
+
+// ExclusionGroup returns the exclusion group of the variant, if any.
+func (vv VariantView) ExclusionGroup() string { return vv.v.ExclusionGroup }
+
+// Enabled reports whether the variant is enabled.
+func (vv VariantView) Enabled() bool { return vv.v.IsEnabled() }
+
+// Extends returns the ID of the variant's base variant, if any.
+func (vv VariantView) Extends() string { return vv.v.Extends }
+
+// FlagValue returns the value the variant sets for the named flag.
+func (vv VariantView) FlagValue(name string) interface{} { return vv.v.FlagValue(name) }
+
+// Tags returns a copy of the variant's tags.
+func (vv VariantView) Tags() []string {
+	return append([]string(nil), vv.v.Tags...)
+}
+
+// Mods returns a copy of the variant's mods.
+func (vv VariantView) Mods() []Mod {
+	return append([]Mod(nil), vv.v.Mods...)
+}
+
+// Conditions returns a copy of the variant's conditions.
+func (vv VariantView) Conditions() []Condition {
+	return copyConditions(vv.v.Conditions)
+}
+
+// ConditionGroups returns a copy of the variant's condition groups.
+func (vv VariantView) ConditionGroups() []ConditionGroup {
+	return copyConditionGroups(vv.v.ConditionGroups)
+}
+
+func copyConditions(conditions []Condition) []Condition {
+	if conditions == nil {
+		return nil
+	}
+	result := make([]Condition, len(conditions))
+	for i, c := range conditions {
+		c.Values = append([]interface{}(nil), c.Values...)
+		result[i] = c
+	}
+	return result
+}
+
+func copyConditionGroups(groups []ConditionGroup) []ConditionGroup {
+	if groups == nil {
+		return nil
+	}
+	result := make([]ConditionGroup, len(groups))
+	for i, g := range groups {
+		result[i] = ConditionGroup{
+			ConditionalOperator: g.ConditionalOperator,
+			Conditions:          copyConditions(g.Conditions),
+			Groups:              copyConditionGroups(g.Groups),
+		}
+	}
+	return result
+}
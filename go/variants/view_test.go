@@ -0,0 +1,28 @@
+package variants
+
+import "testing"
+
+func TestGetVariantView(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadConfig("testdata/testdata.json"); err != nil {
+		t.Fatalf("LoadConfig: Expected no error, but got %q", err.Error())
+	}
+	if _, found := r.GetVariantView("Missing"); found {
+		t.Error("GetVariantView: expected no view of an unregistered variant.")
+	}
+	vv, found := r.GetVariantView("ModRangeTest")
+	if !found || vv.ID() != "ModRangeTest" || !vv.Enabled() {
+		t.Fatalf("GetVariantView: expected an enabled ModRangeTest view, got %+v (%v).", vv, found)
+	}
+
+	mods := vv.Mods()
+	mods[0].Value = "mutated"
+	conditions := vv.Conditions()
+	conditions[0].Values[0] = "mutated"
+	conditions[0].Type = "mutated"
+
+	v := r.load().variants["ModRangeTest"]
+	if v.Mods[0].Value == "mutated" || v.Conditions[0].Values[0] == "mutated" || v.Conditions[0].Type == "mutated" {
+		t.Error("GetVariantView: expected mutations of the view's slices not to reach the registry.")
+	}
+}
@@ -0,0 +1,74 @@
+package variants
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// How often watched config files are checked for changes.
+var watchInterval = time.Second
+
+// WatchConfig reloads the given filename config into the DefaultRegistry
+// whenever it changes.
+func WatchConfig(filename string) (stop func(), err error) {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.WatchConfig(filename)
+}
+
+// WatchConfig polls the modification time and size of the given filename
+// config and calls ReloadConfig with it whenever they change. A failed
+// reload, for example of a bad edit, is logged and leaves the current
+// config in place until the file changes again. A file that cannot be
+// read, for example while it is being replaced, is logged once until it
+// can be read again. Reloads are safe to run
+// while flags are evaluated. An error is returned if the file cannot be
+// read when watching starts. Calling stop ends the watch, waiting for any
+// reload in progress to finish.
+func (r *Registry) WatchConfig(filename string) (stop func(), err error) {
+	last, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	failing := false
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(filename)
+			if err != nil {
+				if !failing {
+					log.Printf("variants: watching config %q: %v", filename, err)
+					failing = true
+				}
+				continue
+			}
+			failing = false
+			if fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size() {
+				continue
+			}
+			last = fi
+			if err := r.ReloadConfig(filename); err != nil {
+				log.Printf("variants: reloading config %q: %v", filename, err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}, nil
+}
@@ -0,0 +1,87 @@
+package variants
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	defer func(d time.Duration) { watchInterval = d }(watchInterval)
+	watchInterval = 5 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "variants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "config.json")
+	write := func(value string) {
+		config := `{"flag_defs": [{"flag": "color", "base_value": ` + value + `}]}`
+		if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`"red"`)
+
+	r := NewRegistry()
+	if err := r.LoadConfig(filename); err != nil {
+		t.Fatalf("LoadConfig: expected no error, but got %q.", err.Error())
+	}
+	if _, err := r.WatchConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("WatchConfig: expected an error for a missing file.")
+	}
+	stop, err := r.WatchConfig(filename)
+	if err != nil {
+		t.Fatalf("WatchConfig: expected no error, but got %q.", err.Error())
+	}
+	defer stop()
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	waitFor := func(expected interface{}) {
+		deadline := time.Now().Add(5 * time.Second)
+		for r.FlagValue("color") != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("WatchConfig: expected color to become %v, got %v.", expected, r.FlagValue("color"))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	write(`"blue!"`)
+	waitFor("blue!")
+
+	// A bad edit keeps the current config.
+	write(`[`)
+	time.Sleep(50 * time.Millisecond)
+	if v := r.FlagValue("color"); v != "blue!" {
+		t.Errorf("WatchConfig: expected a bad edit to keep blue!, got %v.", v)
+	}
+	write(`"green"`)
+	waitFor("green")
+
+	// A missing file is logged once until it is written again.
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	write(`"purple!"`)
+	waitFor("purple!")
+
+	stop()
+	stop()
+	if n := strings.Count(logged.String(), "watching config"); n != 1 {
+		t.Errorf("WatchConfig: expected a missing file to be logged once, got %d times.", n)
+	}
+	write(`"yellow!"`)
+	time.Sleep(50 * time.Millisecond)
+	if v := r.FlagValue("color"); v != "purple!" {
+		t.Errorf("WatchConfig: expected no reload after stop, got %v.", v)
+	}
+}
@@ -0,0 +1,87 @@
+package variants
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LoadYAML loads data, a YAML-encoded set of Mods, Conditions, and
+// Variants, with the DefaultRegistry.
+func LoadYAML(data []byte) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.LoadYAML(data)
+}
+
+// LoadYAMLConfig loads filename, a YAML-encoded set of Mods, Conditions,
+// and Variants, with the DefaultRegistry.
+func LoadYAMLConfig(filename string) error {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return DefaultRegistry.LoadYAMLConfig(filename)
+}
+
+// LoadYAMLConfig reads the YAML config file filename and registers its
+// flags and variants with the receiver.
+func (r *Registry) LoadYAMLConfig(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return r.LoadYAML(data)
+}
+
+// LoadYAML reads a byte array of YAML containing flags and variants and
+// registers them with the receiver. The YAML document has the same
+// structure and keys as a JSON config, which it is converted to before
+// being loaded exactly as LoadJSON would.
+func (r *Registry) LoadYAML(data []byte) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	doc, err := yamlToJSON(doc)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return r.LoadJSON(jsonData)
+}
+
+// yamlToJSON converts the maps of a decoded YAML document, which may have
+// keys of any type, to maps with string keys that can be encoded as JSON.
+func yamlToJSON(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("YAML key %v is not a string", k)
+			}
+			val, err := yamlToJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = val
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			val, err := yamlToJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = val
+		}
+		return s, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,43 @@
+package variants
+
+import "testing"
+
+func TestLoadYAMLConfig(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadYAMLConfig("testdata/testdata.yaml"); err != nil {
+		t.Fatalf("LoadYAMLConfig: Expected no error, but got %q", err.Error())
+	}
+	if v := r.FlagValueWithContext("mod_range", map[string]int{"user_id": 3}); v != true {
+		t.Errorf("FlagValueWithContext: expected mod_range to be true, got %v.", v)
+	}
+	if v := r.FlagValueWithContext("mod_range", map[string]int{"user_id": 42}); v != false {
+		t.Errorf("FlagValueWithContext: expected mod_range to be false, got %v.", v)
+	}
+	if len(r.Variants()) != 2 {
+		t.Errorf("LoadYAMLConfig: expected 2 variants, got %d.", len(r.Variants()))
+	}
+}
+
+func TestLoadYAMLValidation(t *testing.T) {
+	config := `
+flag_defs:
+  - flag: beta
+    base_value: false
+variants:
+  - id: Beta
+    conditions:
+      - type: RANDOM
+        value: 1
+      - type: RANDOM
+        value: 1
+    mods:
+      - flag: beta
+        value: true
+`
+	if err := NewRegistry().LoadYAML([]byte(config)); err == nil {
+		t.Error("LoadYAML: expected an error for multiple conditions without an operator.")
+	}
+	if err := NewRegistry().LoadYAML([]byte("flag_defs: [")); err == nil {
+		t.Error("LoadYAML: expected an error for invalid YAML.")
+	}
+}